@@ -0,0 +1,131 @@
+// Package analysis defines the interface between a modular static analysis
+// of HLB modules and an analysis driver program. It is modeled on
+// golang.org/x/tools/go/analysis.
+//
+// An Analyzer describes an analysis function and its options, and the
+// analyzers it depends on. A driver runs the analyzers over a module,
+// providing each with a Pass holding the parsed module and the results of
+// its required analyzers, through which it reports diagnostics.
+package analysis
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+// An Analyzer describes an analysis function and its options.
+type Analyzer struct {
+	// Name of the analyzer, a valid identifier that is unique among the
+	// analyzers run together.
+	Name string
+
+	// Doc is the documentation for the analyzer. The first sentence is a
+	// summary.
+	Doc string
+
+	// Requires is a set of analyzers that must run successfully before this
+	// one on the same module. Their results are available in
+	// Pass.ResultOf.
+	Requires []*Analyzer
+
+	// Run applies the analyzer to a module. It returns an error if the
+	// analyzer failed, or a result for the analyzers that require it.
+	Run func(*Pass) (interface{}, error)
+
+	// ResultType is the type of the result returned by Run, if any.
+	ResultType reflect.Type
+}
+
+func (a *Analyzer) String() string { return a.Name }
+
+// A Pass provides information to the Run function that applies a specific
+// analyzer to a single module.
+type Pass struct {
+	Analyzer *Analyzer
+
+	// Filename and Source are the name and contents of the module file.
+	Filename string
+	Source   []byte
+
+	// Module is the parsed module.
+	Module *ast.Module
+
+	// ResultOf provides the results of the required analyzers.
+	ResultOf map[*Analyzer]interface{}
+
+	// Report reports a diagnostic.
+	Report func(Diagnostic)
+}
+
+// Reportf reports a diagnostic at a single position.
+func (pass *Pass) Reportf(pos lexer.Position, format string, args ...interface{}) {
+	pass.Report(Diagnostic{
+		Pos:     pos,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// ReportRangef reports a diagnostic spanning the source of node.
+func (pass *Pass) ReportRangef(node ast.Node, format string, args ...interface{}) {
+	pass.Report(Diagnostic{
+		Pos:     node.Position(),
+		End:     node.EndPosition(),
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Text returns the source of node.
+func (pass *Pass) Text(node ast.Node) []byte {
+	return pass.Source[node.Position().Offset:node.EndPosition().Offset]
+}
+
+// Validate reports an error if any of the analyzers are misconfigured:
+// missing names or run functions, duplicate names, or cycles in their
+// requirements.
+func Validate(analyzers []*Analyzer) error {
+	names := make(map[string]*Analyzer)
+
+	const (
+		white = iota
+		grey
+		black
+	)
+	color := make(map[*Analyzer]int)
+	var visit func(a *Analyzer) error
+	visit = func(a *Analyzer) error {
+		switch color[a] {
+		case grey:
+			return fmt.Errorf("cycle detected involving analyzer %s", a)
+		case black:
+			return nil
+		}
+		if a.Name == "" {
+			return fmt.Errorf("analyzer has no name")
+		}
+		if a.Run == nil {
+			return fmt.Errorf("analyzer %s has no Run function", a)
+		}
+		if prev, ok := names[a.Name]; ok && prev != a {
+			return fmt.Errorf("duplicate analyzer name %s", a)
+		}
+		names[a.Name] = a
+
+		color[a] = grey
+		for _, req := range a.Requires {
+			if err := visit(req); err != nil {
+				return err
+			}
+		}
+		color[a] = black
+		return nil
+	}
+	for _, a := range analyzers {
+		if err := visit(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
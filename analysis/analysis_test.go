@@ -0,0 +1,63 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	run := func(*Pass) (interface{}, error) { return nil, nil }
+	a := &Analyzer{Name: "a", Run: run}
+	b := &Analyzer{Name: "b", Run: run, Requires: []*Analyzer{a}}
+	c := &Analyzer{Name: "c", Run: run, Requires: []*Analyzer{a, b}}
+
+	x := &Analyzer{Name: "x", Run: run}
+	y := &Analyzer{Name: "y", Run: run, Requires: []*Analyzer{x}}
+	x.Requires = []*Analyzer{y}
+
+	self := &Analyzer{Name: "self", Run: run}
+	self.Requires = []*Analyzer{self}
+
+	for _, tc := range []struct {
+		name      string
+		analyzers []*Analyzer
+		err       string
+	}{{
+		name:      "shared requirement",
+		analyzers: []*Analyzer{b, c, a},
+	}, {
+		name:      "cycle",
+		analyzers: []*Analyzer{a, y},
+		err:       "cycle detected involving analyzer y",
+	}, {
+		name:      "self cycle",
+		analyzers: []*Analyzer{self},
+		err:       "cycle detected involving analyzer self",
+	}, {
+		name:      "duplicate name",
+		analyzers: []*Analyzer{c, {Name: "a", Run: run}},
+		err:       "duplicate analyzer name a",
+	}, {
+		name:      "duplicate required name",
+		analyzers: []*Analyzer{{Name: "b", Run: run}, c},
+		err:       "duplicate analyzer name b",
+	}, {
+		name:      "no name",
+		analyzers: []*Analyzer{{Run: run}},
+		err:       "analyzer has no name",
+	}, {
+		name:      "no run",
+		analyzers: []*Analyzer{{Name: "a", Requires: []*Analyzer{a}}},
+		err:       "analyzer a has no Run function",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate(tc.analyzers)
+			switch {
+			case tc.err == "" && err != nil:
+				t.Fatal(err)
+			case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+				t.Fatalf("got error %v, want %s", err, tc.err)
+			}
+		})
+	}
+}
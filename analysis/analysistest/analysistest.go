@@ -0,0 +1,151 @@
+// Package analysistest runs analyzers over modules in testdata and checks
+// their diagnostics and suggested fixes against expectations written in the
+// modules. It is modeled on golang.org/x/tools/go/analysis/analysistest.
+package analysistest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/analysis/checker"
+	"github.com/hinshun/hlb-parser/fix"
+	"github.com/hinshun/hlb-parser/internal/diff"
+)
+
+// Run runs the analyzer over each of the files in dir and checks that the
+// diagnostics it reports are the ones expected by the comments of the files.
+//
+// A comment of the form
+//
+//	# want "regexp" "regexp"...
+//
+// expects a diagnostic matching each of the regular expressions, which are
+// Go string literals, on the line of the comment. Diagnostics on lines
+// without expectations, and expectations no diagnostic matches, are
+// reported as test errors.
+func Run(t *testing.T, dir string, a *analysis.Analyzer, files ...string) []*checker.Result {
+	t.Helper()
+	var results []*checker.Result
+	for _, file := range files {
+		filename := filepath.Join(dir, file)
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := checker.Analyze(filename, src, []*analysis.Analyzer{a})
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, res)
+		results = append(results, res)
+	}
+	return results
+}
+
+// RunWithSuggestedFixes behaves like Run, and also applies the first
+// suggested fix of each diagnostic to each file and checks that the result
+// matches the contents of the file with the suffix ".golden".
+func RunWithSuggestedFixes(t *testing.T, dir string, a *analysis.Analyzer, files ...string) []*checker.Result {
+	t.Helper()
+	results := Run(t, dir, a, files...)
+	for _, res := range results {
+		var set fix.Set
+		for _, d := range res.Diagnostics {
+			if len(d.SuggestedFixes) == 0 {
+				continue
+			}
+			if err := set.Add(d.SuggestedFixes[0].TextEdits...); err != nil {
+				t.Errorf("%s: %s", d, err)
+			}
+		}
+		got, err := fix.Fix(res.Filename, res.Source, set.Edits())
+		if err != nil {
+			t.Errorf("%s: %s", res.Filename, err)
+			continue
+		}
+
+		golden := res.Filename + ".golden"
+		want, err := os.ReadFile(golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := diff.Unified(golden, "got", want, got); d != "" {
+			t.Errorf("suggested fixes of %s don't match %s:\n%s", res.Filename, golden, d)
+		}
+	}
+	return results
+}
+
+var (
+	wantRE   = regexp.MustCompile(`#\s*want\s+(.*)$`)
+	stringRE = regexp.MustCompile("\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`")
+)
+
+// expectation is a diagnostic expected on a line.
+type expectation struct {
+	line    int
+	re      *regexp.Regexp
+	matched bool
+}
+
+// check reports the diagnostics of res that don't match the expectations of
+// its source, and the expectations that no diagnostic matched.
+func check(t *testing.T, res *checker.Result) {
+	t.Helper()
+	wants, err := expectations(res.Source)
+	if err != nil {
+		t.Fatalf("%s: %s", res.Filename, err)
+	}
+
+	for _, d := range res.Diagnostics {
+		found := false
+		for _, want := range wants {
+			if !want.matched && want.line == d.Pos.Line && want.re.MatchString(d.Message) {
+				want.matched = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("%s: unexpected diagnostic: %s", d.Pos, d.Message)
+		}
+	}
+	for _, want := range wants {
+		if !want.matched {
+			t.Errorf("%s:%d: no diagnostic was reported matching %q", res.Filename, want.line, want.re)
+		}
+	}
+}
+
+// expectations parses the want comments of src.
+func expectations(src []byte) ([]*expectation, error) {
+	var wants []*expectation
+	for i, line := range strings.Split(string(src), "\n") {
+		m := wantRE.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		lits := stringRE.FindAllString(m[1], -1)
+		if len(lits) == 0 {
+			return nil, fmt.Errorf("line %d: want comment without expectations", i+1)
+		}
+		for _, lit := range lits {
+			pattern, err := strconv.Unquote(lit)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			wants = append(wants, &expectation{line: i + 1, re: re})
+		}
+	}
+	return wants, nil
+}
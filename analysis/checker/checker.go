@@ -0,0 +1,123 @@
+// Package checker runs analyzers over modules, running each required
+// analyzer once per module and collecting the diagnostics of the analyzers
+// that were asked for.
+package checker
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+// Diagnostic is a diagnostic reported by an analyzer.
+type Diagnostic struct {
+	analysis.Diagnostic
+	Analyzer *analysis.Analyzer
+}
+
+func (d *Diagnostic) String() string {
+	if d.Code != "" {
+		return fmt.Sprintf("%s: %s [%s] (%s)", d.Pos, d.Message, d.Code, d.Analyzer.Name)
+	}
+	return fmt.Sprintf("%s: %s (%s)", d.Pos, d.Message, d.Analyzer.Name)
+}
+
+// Result is the outcome of analyzing a module.
+type Result struct {
+	Filename string
+	Source   []byte
+	Module   *ast.Module
+
+	// Diagnostics reported by the requested analyzers, sorted by position.
+	Diagnostics []*Diagnostic
+}
+
+// Analyze parses src and runs the analyzers and their requirements over it.
+func Analyze(filename string, src []byte, analyzers []*analysis.Analyzer) (*Result, error) {
+	if err := analysis.Validate(analyzers); err != nil {
+		return nil, err
+	}
+
+	f := ast.ParseSource(filename, src, ast.Options{Comments: true, Features: ast.AllFeatures})
+	if err := f.Err(); err != nil {
+		return nil, err
+	}
+	return AnalyzeModule(filename, src, f.Module, analyzers)
+}
+
+// AnalyzeModule runs the analyzers and their requirements over a module that
+// has already been parsed from src.
+func AnalyzeModule(filename string, src []byte, mod *ast.Module, analyzers []*analysis.Analyzer) (*Result, error) {
+	a := &action{
+		filename: filename,
+		src:      src,
+		mod:      mod,
+		results:  make(map[*analysis.Analyzer]interface{}),
+		diags:    make(map[*analysis.Analyzer][]analysis.Diagnostic),
+	}
+	res := &Result{
+		Filename: filename,
+		Source:   src,
+		Module:   mod,
+	}
+	for _, analyzer := range analyzers {
+		diags, err := a.run(analyzer)
+		if err != nil {
+			return nil, err
+		}
+		for _, diag := range diags {
+			res.Diagnostics = append(res.Diagnostics, &Diagnostic{
+				Diagnostic: diag,
+				Analyzer:   analyzer,
+			})
+		}
+	}
+	sort.SliceStable(res.Diagnostics, func(i, j int) bool {
+		return res.Diagnostics[i].Pos.Offset < res.Diagnostics[j].Pos.Offset
+	})
+	return res, nil
+}
+
+// action memoizes the results of analyzers run over one module.
+type action struct {
+	filename string
+	src      []byte
+	mod      *ast.Module
+	results  map[*analysis.Analyzer]interface{}
+	diags    map[*analysis.Analyzer][]analysis.Diagnostic
+}
+
+func (a *action) run(analyzer *analysis.Analyzer) ([]analysis.Diagnostic, error) {
+	if diags, ok := a.diags[analyzer]; ok {
+		return diags, nil
+	}
+
+	resultOf := make(map[*analysis.Analyzer]interface{})
+	for _, req := range analyzer.Requires {
+		if _, err := a.run(req); err != nil {
+			return nil, err
+		}
+		resultOf[req] = a.results[req]
+	}
+
+	var diags []analysis.Diagnostic
+	pass := &analysis.Pass{
+		Analyzer: analyzer,
+		Filename: a.filename,
+		Source:   a.src,
+		Module:   a.mod,
+		ResultOf: resultOf,
+		Report: func(d analysis.Diagnostic) {
+			diags = append(diags, d)
+		},
+	}
+	result, err := analyzer.Run(pass)
+	if err != nil {
+		return nil, fmt.Errorf("analyzer %s failed on %s: %w", analyzer, a.filename, err)
+	}
+	a.results[analyzer] = result
+	a.diags[analyzer] = diags
+	return diags, nil
+}
@@ -0,0 +1,97 @@
+package checker
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+// TestRequires checks that an analyzer required by several others runs once
+// per module, and that they all see its result.
+func TestRequires(t *testing.T) {
+	runs := 0
+	shared := &analysis.Analyzer{
+		Name: "shared",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			runs++
+			pass.Reportf(pass.Module.Pos, "shared")
+			return len(pass.Module.Decls), nil
+		},
+	}
+	var got []interface{}
+	user := func(name string) *analysis.Analyzer {
+		return &analysis.Analyzer{
+			Name:     name,
+			Requires: []*analysis.Analyzer{shared},
+			Run: func(pass *analysis.Pass) (interface{}, error) {
+				got = append(got, pass.ResultOf[shared])
+				pass.Reportf(pass.Module.Pos, name)
+				return nil, nil
+			},
+		}
+	}
+	a, b := user("a"), user("b")
+
+	res, err := Analyze("test.hlb", []byte("fun a() fs {}\nfun b() fs {}\n"), []*analysis.Analyzer{a, b, shared})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runs != 1 {
+		t.Errorf("shared ran %d times, want once", runs)
+	}
+	if len(got) != 2 || got[0] != 2 || got[1] != 2 {
+		t.Errorf("got results %v, want the result of shared for a and b", got)
+	}
+
+	var reports []string
+	for _, d := range res.Diagnostics {
+		reports = append(reports, d.Analyzer.Name+":"+d.Message)
+	}
+	if len(reports) != 3 || reports[0] != "a:a" || reports[1] != "b:b" || reports[2] != "shared:shared" {
+		t.Errorf("got diagnostics %v, want one of each analyzer", reports)
+	}
+}
+
+// TestRequiresOnly checks that diagnostics of required analyzers that weren't
+// asked for aren't reported.
+func TestRequiresOnly(t *testing.T) {
+	shared := &analysis.Analyzer{
+		Name: "shared",
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			pass.Reportf(pass.Module.Pos, "shared")
+			return nil, nil
+		},
+	}
+	user := &analysis.Analyzer{
+		Name:     "user",
+		Requires: []*analysis.Analyzer{shared},
+		Run:      func(*analysis.Pass) (interface{}, error) { return nil, nil },
+	}
+	mod := &ast.Module{}
+	res, err := AnalyzeModule("test.hlb", nil, mod, []*analysis.Analyzer{user})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Diagnostics) != 0 {
+		t.Errorf("got diagnostics %v, want none", res.Diagnostics)
+	}
+}
+
+// TestAnalyzeSyntax checks that the experimental syntax of the repo's own
+// build.hlb parses, and that syntax errors are returned as diagnostics.
+func TestAnalyzeSyntax(t *testing.T) {
+	src, err := os.ReadFile("../../build.hlb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Analyze("build.hlb", src, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Analyze("test.hlb", []byte("fun a() fs {\n"), nil)
+	if _, ok := err.(*ast.Diagnostic); !ok {
+		t.Errorf("got error %v, want a diagnostic", err)
+	}
+}
@@ -0,0 +1,37 @@
+package analysis
+
+import "github.com/alecthomas/participle/v2/lexer"
+
+// A Diagnostic is a message associated with a source range. End is the zero
+// position if the diagnostic has no range.
+type Diagnostic struct {
+	Pos lexer.Position
+	End lexer.Position
+
+	// Category optionally classifies the diagnostic within the analyzer.
+	Category string
+
+	// Code identifies the kind of diagnostic in the catalog of package
+	// codes, or is empty.
+	Code string
+
+	Message string
+
+	// SuggestedFixes are alternative ways of fixing the problem.
+	SuggestedFixes []SuggestedFix
+}
+
+// A SuggestedFix is a change that resolves a diagnostic. Its edits must not
+// overlap.
+type SuggestedFix struct {
+	Message   string
+	TextEdits []TextEdit
+}
+
+// A TextEdit replaces the source between Pos and End with NewText. An
+// insertion has Pos equal to End.
+type TextEdit struct {
+	Pos     lexer.Position
+	End     lexer.Position
+	NewText []byte
+}
@@ -0,0 +1,33 @@
+// Package names defines an Analyzer that reports identifiers that don't
+// resolve and conflicting declarations.
+package names
+
+import (
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/analysis/passes/resolver"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "names",
+	Doc:      "report undefined and redeclared names\n\nCalls to undefined functions, unknown parameters or effects, and functions that aren't public in an imported module are reported.",
+	Requires: []*analysis.Analyzer{resolver.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	info := pass.ResultOf[resolver.Analyzer].(*resolve.Info)
+	for _, err := range info.Errors {
+		// Errors in imported modules are reported when they are analyzed.
+		if err.Pos.Filename != pass.Filename {
+			continue
+		}
+		pass.Report(analysis.Diagnostic{
+			Pos:     err.Pos,
+			End:     err.End,
+			Code:    err.Code,
+			Message: err.Msg,
+		})
+	}
+	return nil, nil
+}
@@ -0,0 +1,11 @@
+package names
+
+import (
+	"testing"
+
+	"github.com/hinshun/hlb-parser/analysis/analysistest"
+)
+
+func Test(t *testing.T) {
+	analysistest.Run(t, "testdata", Analyzer, "names.hlb", "lib.hlb")
+}
@@ -0,0 +1,16 @@
+// Package passes lists the analyzers shipped with the analysis framework.
+package passes
+
+import (
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/analysis/passes/names"
+	"github.com/hinshun/hlb-parser/analysis/passes/shadow"
+	"github.com/hinshun/hlb-parser/analysis/passes/simplifywith"
+)
+
+// Analyzers are the analyzers run by default.
+var Analyzers = []*analysis.Analyzer{
+	names.Analyzer,
+	shadow.Analyzer,
+	simplifywith.Analyzer,
+}
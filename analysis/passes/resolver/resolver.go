@@ -0,0 +1,21 @@
+// Package resolver defines an Analyzer that provides the resolution of
+// identifiers as a result for other analyzers.
+package resolver
+
+import (
+	"reflect"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name:       "resolver",
+	Doc:        "resolve identifiers to their declarations\n\nThe result is a *resolve.Info, loading modules imported from local paths.",
+	Run:        run,
+	ResultType: reflect.TypeOf(new(resolve.Info)),
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	return resolve.Resolve(pass.Filename, pass.Module, resolve.FileImporter{}), nil
+}
@@ -0,0 +1,45 @@
+package resolver
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/analysis/analysistest"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+func Test(t *testing.T) {
+	analysistest.Run(t, "testdata", Analyzer, "resolver.hlb")
+}
+
+// TestResult checks the result is a *resolve.Info holding the imported
+// modules, for the analyzers that require it.
+func TestResult(t *testing.T) {
+	var info *resolve.Info
+	user := &analysis.Analyzer{
+		Name:     "user",
+		Requires: []*analysis.Analyzer{Analyzer},
+		Run: func(pass *analysis.Pass) (interface{}, error) {
+			result := pass.ResultOf[Analyzer]
+			if got := reflect.TypeOf(result); got != Analyzer.ResultType {
+				t.Fatalf("got result of type %s, want %s", got, Analyzer.ResultType)
+			}
+			info = result.(*resolve.Info)
+			return nil, nil
+		},
+	}
+	analysistest.Run(t, "testdata", user, "resolver.hlb")
+
+	if len(info.Errors) > 0 {
+		t.Fatal(info.Errors[0])
+	}
+	lib := info.ModuleOf(filepath.Join("testdata", "lib.hlb"))
+	if lib == nil {
+		t.Fatal("lib.hlb isn't loaded")
+	}
+	if obj := lib.Scope.Lookup("base"); obj == nil || obj.Kind != resolve.Func {
+		t.Errorf("got base %v in lib.hlb, want a function", obj)
+	}
+}
@@ -0,0 +1,43 @@
+// Package shadow defines an Analyzer that reports parameters, effects and
+// loop variables that mask a function of the module.
+package shadow
+
+import (
+	"fmt"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/analysis/passes/resolver"
+	"github.com/hinshun/hlb-parser/codes"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "shadow",
+	Doc:      "report local names that mask functions\n\nA parameter named after a function makes the function unreachable from the body, which is easy to miss when reading calls.",
+	Requires: []*analysis.Analyzer{resolver.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	info := pass.ResultOf[resolver.Analyzer].(*resolve.Info)
+	mod := info.ModuleOf(pass.Filename)
+	for _, scope := range info.Scopes {
+		if scope.Parent == nil || scope.Node == pass.Module {
+			continue
+		}
+		for name, obj := range scope.Objects {
+			if obj.Module != mod {
+				continue
+			}
+			if masked := mod.Scope.Lookup(name); masked != nil && masked.Kind == resolve.Func {
+				pass.Report(analysis.Diagnostic{
+					Pos:     obj.Ident.Pos,
+					End:     obj.Ident.EndPos,
+					Code:    codes.ShadowedFunction,
+					Message: fmt.Sprintf("%s %s masks function declared at %s", obj.Kind, name, masked.Ident.Pos),
+				})
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,11 @@
+package shadow
+
+import (
+	"testing"
+
+	"github.com/hinshun/hlb-parser/analysis/analysistest"
+)
+
+func Test(t *testing.T) {
+	analysistest.Run(t, "testdata", Analyzer, "shadow.hlb")
+}
@@ -0,0 +1,85 @@
+// Package simplifywith defines an Analyzer that simplifies with clauses
+// holding a block of a single option.
+package simplifywith
+
+import (
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/codes"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "simplifywith",
+	Doc:  "simplify with clauses of a single option\n\nA single expression is allowed as a single element block, so `with { readonly }` can be written as `with readonly`.",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	ast.Inspect(pass.Module, func(node ast.Node) bool {
+		with, ok := node.(*ast.WithClause)
+		if !ok {
+			return true
+		}
+		block := blockLit(with.Expr)
+		if block == nil || block.Type != nil {
+			return true
+		}
+
+		var (
+			exprs []*ast.Expr
+			other bool
+		)
+		for _, stmt := range block.Block.Stmts {
+			switch {
+			case stmt.Expr != nil:
+				exprs = append(exprs, stmt.Expr)
+			case stmt.Newline != nil:
+			default:
+				other = true
+			}
+		}
+		if other || len(exprs) != 1 || hasClause(exprs[0]) {
+			return true
+		}
+
+		pass.Report(analysis.Diagnostic{
+			Pos:     with.Expr.Pos,
+			End:     with.Expr.EndPos,
+			Code:    codes.SimplifiableWith,
+			Message: "with clause of a single option can be simplified",
+			SuggestedFixes: []analysis.SuggestedFix{{
+				Message: "Remove braces",
+				TextEdits: []analysis.TextEdit{{
+					Pos:     with.Expr.Pos,
+					End:     with.Expr.EndPos,
+					NewText: pass.Text(exprs[0]),
+				}},
+			}},
+		})
+		return true
+	})
+	return nil, nil
+}
+
+func blockLit(expr *ast.Expr) *ast.BlockLit {
+	if expr.Unary == nil || expr.Unary.Op != ast.OpNone || expr.Unary.Ref.Next != nil {
+		return nil
+	}
+	if lit := expr.Unary.Ref.Terminal.Lit; lit != nil {
+		return lit.Block
+	}
+	return nil
+}
+
+// hasClause reports whether expr has with or as clauses, which would read as
+// applying to the outer call once the braces are removed.
+func hasClause(expr *ast.Expr) bool {
+	found := false
+	ast.Inspect(expr, func(node ast.Node) bool {
+		if call, ok := node.(*ast.Call); ok && (call.With != nil || call.As != nil) {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
@@ -0,0 +1,11 @@
+package simplifywith
+
+import (
+	"testing"
+
+	"github.com/hinshun/hlb-parser/analysis/analysistest"
+)
+
+func Test(t *testing.T) {
+	analysistest.RunWithSuggestedFixes(t, "testdata", Analyzer, "simplifywith.hlb")
+}
@@ -10,82 +10,87 @@ import (
 var (
 	Lexer = lexer.MustStateful(lexer.Rules{
 		"Root": {
-			{"whitespace", `[\r\t ]+`, nil},
-			{"Modifier", `\b(pub)\b`, nil},
-			{"Keyword", `\b(if|else|for|in|with|as|import|fun)\b`, nil},
-			{"Numeric", `\b(0(b|B|o|O|x|X)[a-fA-F0-9]+)\b`, nil},
-			{"Decimal", `\b(0|[1-9][0-9]*)\b`, nil},
-			{"Bool", `\b(true|false)\b`, nil},
-			{"String", `"`, lexer.Push("String")},
-			{"RawString", "`", lexer.Push("RawString")},
-			{"Heredoc", `<<[-~]?(\w+)`, lexer.Push("Heredoc")},
-			{"RawHeredoc", "<<[-~]?`(\\w+)`", lexer.Push("RawHeredoc")},
-			{"Brace", `{`, lexer.Push("Brace")},
-			{"Paren", `\(`, lexer.Push("Paren")},
-			{"Bracket", `\[`, lexer.Push("Bracket")},
-			{"Ident", `\b([[:alpha:]_]\w*)\b`, nil},
-			{"Operator", `(>=|<=|&&|\|\||==|!=|[-+=*/%<>^!|&])`, nil},
-			{"Punct", `[@:;?.,]`, nil},
-			{"Newline", `\n`, nil},
-			{"Comment", `#`, lexer.Push("Comment")},
+			{Name: "Whitespace", Pattern: `[\r\t ]+`},
+			{Name: "Modifier", Pattern: `\b(pub)\b`},
+			{Name: "Keyword", Pattern: `\b(if|else|for|in|with|as|import|fun)\b`},
+			{Name: "Numeric", Pattern: `\b(0(b|B|o|O|x|X)[a-fA-F0-9]+)\b`},
+			{Name: "Decimal", Pattern: `\b(0|[1-9][0-9]*)\b`},
+			{Name: "Bool", Pattern: `\b(true|false)\b`},
+			{Name: "String", Pattern: `"`, Action: lexer.Push("String")},
+			{Name: "RawString", Pattern: "`", Action: lexer.Push("RawString")},
+			{Name: "Heredoc", Pattern: `<<[-~]?(\w+)`, Action: lexer.Push("Heredoc")},
+			{Name: "RawHeredoc", Pattern: "<<[-~]?`(\\w+)`", Action: lexer.Push("RawHeredoc")},
+			{Name: "Brace", Pattern: `{`, Action: lexer.Push("Brace")},
+			{Name: "Paren", Pattern: `\(`, Action: lexer.Push("Paren")},
+			{Name: "Bracket", Pattern: `\[`, Action: lexer.Push("Bracket")},
+			{Name: "Ident", Pattern: `\b([[:alpha:]_]\w*)\b`},
+			{Name: "Operator", Pattern: `(>=|<=|&&|\|\||==|!=|[-+=*/%<>^!|&])`},
+			{Name: "Punct", Pattern: `[@:;?.,]`},
+			{Name: "Newline", Pattern: `\n`},
+			{Name: "Comment", Pattern: `#`, Action: lexer.Push("Comment")},
 		},
 		"String": {
-			{"StringEnd", `"`, lexer.Pop()},
-			{"Escaped", `\\.`, nil},
-			{"Interpolated", `\${`, lexer.Push("Interpolated")},
-			{"Char", `\$|[^"$\\]+`, nil},
+			{Name: "StringEnd", Pattern: `"`, Action: lexer.Pop()},
+			{Name: "Escaped", Pattern: `\\.`},
+			{Name: "Interpolated", Pattern: `\${`, Action: lexer.Push("Interpolated")},
+			{Name: "Char", Pattern: `\$|[^"$\\]+`},
 		},
 		"RawString": {
-			{"RawStringEnd", "`", lexer.Pop()},
-			{"RawChar", "[^`]+", nil},
+			{Name: "RawStringEnd", Pattern: "`", Action: lexer.Pop()},
+			{Name: "RawChar", Pattern: "[^`]+"},
 		},
 		"Heredoc": {
-			{"HeredocEnd", `\b\1\b`, lexer.Pop()},
-			{"Spaces", `\s+`, nil},
-			{"Escaped", `\\.`, nil},
-			{"Interpolated", `\${`, lexer.Push("Interpolated")},
-			{"Text", `\$|[^\s$]+`, nil},
+			{Name: "HeredocEnd", Pattern: `\b\1\b`, Action: lexer.Pop()},
+			{Name: "Spaces", Pattern: `\s+`},
+			{Name: "Escaped", Pattern: `\\.`},
+			{Name: "Interpolated", Pattern: `\${`, Action: lexer.Push("Interpolated")},
+			{Name: "Text", Pattern: `\$|[^\s$]+`},
 		},
 		"RawHeredoc": {
-			{"RawHeredocEnd", `\b\1\b`, lexer.Pop()},
-			{"Whitespace", `\s+`, nil},
-			{"RawText", `[^\s]+`, nil},
+			{Name: "RawHeredocEnd", Pattern: `\b\1\b`, Action: lexer.Pop()},
+			{Name: "Spaces", Pattern: `\s+`},
+			{Name: "RawText", Pattern: `[^\s]+`},
 		},
 		"Interpolated": {
-			{"BraceEnd", `}`, lexer.Pop()},
+			{Name: "BraceEnd", Pattern: `}`, Action: lexer.Pop()},
 			lexer.Include("Root"),
 		},
 		"Brace": {
-			{"BraceEnd", `}`, lexer.Pop()},
+			{Name: "BraceEnd", Pattern: `}`, Action: lexer.Pop()},
 			lexer.Include("Root"),
 		},
 		"Paren": {
-			{"ParenEnd", `\)`, lexer.Pop()},
+			{Name: "ParenEnd", Pattern: `\)`, Action: lexer.Pop()},
 			lexer.Include("Root"),
 		},
 		"Bracket": {
-			{"BracketEnd", `\]`, lexer.Pop()},
+			{Name: "BracketEnd", Pattern: `\]`, Action: lexer.Pop()},
 			lexer.Include("Root"),
 		},
 		"Comment": {
-			{"CommentEnd", `\n`, lexer.Pop()},
-			{"CommentText", `[^\n]`, nil},
+			{Name: "CommentEnd", Pattern: `\n`, Action: lexer.Pop()},
+			{Name: "CommentText", Pattern: `[^\n]`},
 		},
 	})
 
-	Parser = participle.MustBuild(
+	moduleParser = participle.MustBuild(
 		&Module{},
 		participle.Lexer(&semicolonLexerDefinition{}),
+		participle.Elide("Whitespace"),
 	)
 )
 
 type Module struct {
+	Mixin
 	Comments *Comments `parser:"@@?"`
 
-	Decls []*Decl `parser:"@@*"`
+	// The end of the file is matched so that an empty module, having no
+	// other tokens, parses.
+	Decls []*Decl `parser:"@@* EOF?"`
 }
 
 type Decl struct {
+	Mixin
 	Import   *ImportDecl `parser:"( @@ ';'?"`
 	Func     *FuncDecl   `parser:"| @@ ';'?"`
 	Newline  *Newline    `parser:"| @@"`
@@ -93,6 +98,7 @@ type Decl struct {
 }
 
 type ImportDecl struct {
+	Mixin
 	Import *Import `parser:"@@"`
 	Name   *Ident  `parser:"@@"`
 	From   *From   `parser:"@@"`
@@ -100,14 +106,17 @@ type ImportDecl struct {
 }
 
 type Import struct {
+	Mixin
 	Text string `parser:"@'import'"`
 }
 
 type From struct {
+	Mixin
 	Text string `parser:"@'from'"`
 }
 
 type FuncDecl struct {
+	Mixin
 	Modifiers []*Modifier `parser:"@@*"`
 	Func      *Func       `parser:"@@"`
 	Name      *Ident      `parser:"@@"`
@@ -118,30 +127,36 @@ type FuncDecl struct {
 }
 
 type Modifier struct {
-	Public *Public `@@`
+	Mixin
+	Public *Public `parser:"@@"`
 }
 
 type Public struct {
+	Mixin
 	Text string `parser:"@'pub'"`
 }
 
 type Func struct {
+	Mixin
 	Text string `parser:"@'fun'"`
 }
 
 type FieldList struct {
+	Mixin
 	OpenParen  *OpenParen   `parser:"@@"`
 	Fields     []*FieldStmt `parser:"@@*"`
 	CloseParen *CloseParen  `parser:"@@"`
 }
 
 type FieldStmt struct {
+	Mixin
 	Field    *Field    `parser:"( @@ ','?"`
 	Newline  *Newline  `parser:"| @@"`
 	Comments *Comments `parser:"| @@ )"`
 }
 
 type Field struct {
+	Mixin
 	Type     *Type         `parser:"@@"`
 	Variadic *string       `parser:"@( '.' '.' '.' )?"`
 	Name     *Ident        `parser:"@@"`
@@ -149,28 +164,33 @@ type Field struct {
 }
 
 type FieldDefault struct {
+	Mixin
 	Assign string `parser:"@'='"`
 	Unary  *Unary `parser:"@@"`
 }
 
 type Type struct {
+	Mixin
 	Scalar      *Ident       `parser:"( @@"`
 	Array       *Type        `parser:"| '[' ']' @@ )"`
 	Association *Association `parser:"@@?"`
 }
 
 type Association struct {
+	Mixin
 	Symbol string `parser:"@( ':' ':' )"`
 	Ident  *Ident `parser:"@@"`
 }
 
 type StmtList struct {
+	Mixin
 	OpenBrace  *OpenBrace  `parser:"@@"`
 	Stmts      []*Stmt     `parser:"@@*"`
 	CloseBrace *CloseBrace `parser:"@@"`
 }
 
 type Stmt struct {
+	Mixin
 	If       *IfStmt   `parser:"( @@ ';'?"`
 	For      *ForStmt  `parser:"| @@ ';'?"`
 	Entry    *Entry    `parser:"| @@ ';'?"`
@@ -180,6 +200,7 @@ type Stmt struct {
 }
 
 type IfStmt struct {
+	Mixin
 	If        *If           `parser:"@@"`
 	Condition *Condition    `parser:"@@"`
 	Body      *StmtList     `parser:"@@"`
@@ -188,12 +209,14 @@ type IfStmt struct {
 }
 
 type Condition struct {
+	Mixin
 	OpenParen  *OpenParen  `parser:"@@"`
 	Expr       *Expr       `parser:"@@"`
 	CloseParen *CloseParen `parser:"@@"`
 }
 
 type ElseIfStmt struct {
+	Mixin
 	Else      *Else      `parser:"@@"`
 	If        *If        `parser:"@@"`
 	Condition *Condition `parser:"@@"`
@@ -201,29 +224,35 @@ type ElseIfStmt struct {
 }
 
 type ElseStmt struct {
+	Mixin
 	Else *Else     `parser:"@@"`
 	Body *StmtList `parser:"@@"`
 }
 
 type If struct {
+	Mixin
 	Text string `parser:"@'if'"`
 }
 
 type Else struct {
+	Mixin
 	Text string `parser:"@'else'"`
 }
 
 type ForStmt struct {
+	Mixin
 	For    *For       `parser:"@@"`
 	Header *ForHeader `parser:"@@"`
 	Body   *StmtList  `parser:"@@"`
 }
 
 type For struct {
+	Mixin
 	Text string `parser:"@'for'"`
 }
 
 type ForHeader struct {
+	Mixin
 	OpenParen  *OpenParen  `parser:"@@"`
 	Counter    *Ident      `parser:"( @@ ',' )?"`
 	Var        *Ident      `parser:"@@"`
@@ -233,57 +262,67 @@ type ForHeader struct {
 }
 
 type In struct {
+	Mixin
 	Text string `parser:"@'in'"`
 }
 
 type Unary struct {
+	Mixin
 	Op  Op   `parser:"@( '!' | '-' )?"`
 	Ref *Ref `parser:"@@"`
 }
 
 type Ref struct {
+	Mixin
 	Terminal *Terminal `parser:"@@"`
 	Next     *RefNext  `parser:"@@?"`
 }
 
 type Terminal struct {
+	Mixin
 	Group *Group   `parser:"( @@"`
 	Lit   *Literal `parser:"| @@"`
 	Ident *Ident   `parser:"| @@ )"`
 }
 
 type Group struct {
+	Mixin
 	OpenParen  *OpenParen  `parser:"@@"`
 	Expr       *Expr       `parser:"@@"`
 	CloseParen *CloseParen `parser:"@@"`
 }
 
 type RefNext struct {
+	Mixin
 	Subscript *Subscript `parser:"( @@"`
 	Selector  *Selector  `parser:"| @@"`
 	Call      *Call      `parser:"| @@"`
 	Splat     *Splat     `parser:"| @@ )"`
-	Next      *RefNext   `@@?`
+	Next      *RefNext   `parser:"@@?"`
 }
 
 type Splat struct {
+	Mixin
 	Text string `parser:"@('.' '.' '.')"`
 }
 
 type Subscript struct {
+	Mixin
 	OpenBracket  *OpenBracket  `parser:"@@"`
 	LeftExpr     *Expr         `parser:"( @@?"`
-	Colon        *string       `parser:"@':'?"`
-	RightExpr    *Expr         `parser:"@@? )!"`
+	Colon        *string       `parser:"( @':'"`
+	RightExpr    *Expr         `parser:"@@? )? )!"`
 	CloseBracket *CloseBracket `parser:"@@"`
 }
 
 type Selector struct {
+	Mixin
 	Dot   string `parser:"@'.'"`
 	Ident *Ident `parser:"@@"`
 }
 
 type Literal struct {
+	Mixin
 	Block   *BlockLit   `parser:"( @@"`
 	Decimal *int        `parser:"| @Decimal"`
 	Numeric *NumericLit `parser:"| @Numeric"`
@@ -292,16 +331,19 @@ type Literal struct {
 }
 
 type Entry struct {
+	Mixin
 	Keys  []*Ident `parser:"(@@ ':')+"`
 	Value *Expr    `parser:"@@"`
 }
 
 type BlockLit struct {
+	Mixin
 	Type  *Type     `parser:"@@?"`
 	Block *StmtList `parser:"@@"`
 }
 
 type Call struct {
+	Mixin
 	Args *ExprList   `parser:"@@?"`
 	At   *AtClause   `parser:"@@?"`
 	With *WithClause `parser:"@@?"`
@@ -309,12 +351,14 @@ type Call struct {
 }
 
 type ExprList struct {
+	Mixin
 	OpenParen  *OpenParen  `parser:"@@"`
 	Exprs      []*ExprStmt `parser:"@@*"`
 	CloseParen *CloseParen `parser:"@@"`
 }
 
 type ExprStmt struct {
+	Mixin
 	Entry    *Entry    `parser:"( @@ ','?"`
 	Expr     *Expr     `parser:"| @@ ','?"`
 	Newline  *Newline  `parser:"| @@"`
@@ -322,30 +366,36 @@ type ExprStmt struct {
 }
 
 type AtClause struct {
+	Mixin
 	At     *At    `parser:"@@"`
 	Effect *Ident `parser:"@@"`
 }
 
 type At struct {
+	Mixin
 	Text string `parser:"@'@'"`
 }
 
 type WithClause struct {
+	Mixin
 	With    *With `parser:"@@"`
 	Expr    *Expr `parser:"@@"`
 	Closure *FuncDecl
 }
 
 type With struct {
+	Mixin
 	Text string `parser:"@'with'"`
 }
 
 type AsClause struct {
+	Mixin
 	As     *As  `parser:"@@"`
 	Effect *Ref `parser:"@@"`
 }
 
 type As struct {
+	Mixin
 	Text string `parser:"@'as'"`
 }
 
@@ -376,6 +426,7 @@ func (l *NumericLit) Capture(tokens []string) error {
 }
 
 type StringLit struct {
+	Mixin
 	String     *String     `parser:"( @@"`
 	RawString  *RawString  `parser:"| @@"`
 	Heredoc    *Heredoc    `parser:"| @@"`
@@ -383,32 +434,38 @@ type StringLit struct {
 }
 
 type String struct {
+	Mixin
 	Start     *Quote            `parser:"@@"`
 	Fragments []*StringFragment `parser:"@@*"`
 	End       *Quote            `parser:"@@"`
 }
 
 type Quote struct {
+	Mixin
 	Text string `parser:"@(String | StringEnd)"`
 }
 
 type StringFragment struct {
+	Mixin
 	Escaped      *string       `parser:"( @Escaped"`
 	Interpolated *Interpolated `parser:"| @@"`
 	Text         *string       `parser:"| @Char )"`
 }
 
 type RawString struct {
+	Mixin
 	Start *Backtick `parser:"@@"`
 	Text  string    `parser:"@RawChar"`
 	End   *Backtick `parser:"@@"`
 }
 
 type Backtick struct {
+	Mixin
 	Text string `parser:"@(RawString | RawStringEnd)"`
 }
 
 type Heredoc struct {
+	Mixin
 	Value     string
 	Start     string             `parser:"@Heredoc"`
 	Fragments []*HeredocFragment `parser:"@@*"`
@@ -416,6 +473,7 @@ type Heredoc struct {
 }
 
 type HeredocFragment struct {
+	Mixin
 	Spaces       *string       `parser:"( @Spaces"`
 	Escaped      *string       `parser:"| @Escaped"`
 	Interpolated *Interpolated `parser:"| @@"`
@@ -423,61 +481,75 @@ type HeredocFragment struct {
 }
 
 type HeredocEnd struct {
+	Mixin
 	Text string `parser:"@(HeredocEnd | RawHeredocEnd)"`
 }
 
 type RawHeredoc struct {
+	Mixin
 	Start     string             `parser:"@RawHeredoc"`
 	Fragments []*HeredocFragment `parser:"@@*"`
 	End       *HeredocEnd        `parser:"@@"`
 }
 
 type Interpolated struct {
+	Mixin
 	Start *OpenInterpolated `parser:"@@"`
 	Expr  *Expr             `parser:"@@?"`
 	End   *CloseBrace       `parser:"@@"`
 }
 
 type OpenInterpolated struct {
+	Mixin
 	Text string `parser:"@Interpolated"`
 }
 
 type Ident struct {
+	Mixin
 	Text string `parser:"@Ident"`
 }
 
 type Newline struct {
+	Mixin
 	Text string `parser:"@Newline"`
 }
 
 type Comments struct {
+	Mixin
 	Comments []*Comment `parser:"@@+"`
 }
 
 type Comment struct {
+	Mixin
 	Text string `parser:"Comment @(CommentText*) CommentEnd"`
 }
 
 type OpenBrace struct {
+	Mixin
 	Text string `parser:"@Brace"`
 }
 
 type CloseBrace struct {
+	Mixin
 	Text string `parser:"@BraceEnd"`
 }
 
 type OpenParen struct {
+	Mixin
 	Text string `parser:"@Paren"`
 }
 
 type CloseParen struct {
+	Mixin
 	Text string `parser:"@ParenEnd"`
 }
 
 type OpenBracket struct {
+	Mixin
 	Text string `parser:"@Bracket"`
 }
 
 type CloseBracket struct {
+	Mixin
 	Text string `parser:"@BracketEnd"`
 }
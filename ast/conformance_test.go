@@ -0,0 +1,125 @@
+package ast
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the expected outputs of the conformance tests")
+
+// TestConformance parses each source in testdata/conformance and compares
+// the result with the expected output next to it: the module as written by
+// Dump in a .ast file if it parses, and its diagnostics in a .err file
+// otherwise. Only sources named error_ are expected to fail. Run with -update
+// to rewrite the expected outputs.
+func TestConformance(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "conformance", "*.hlb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no conformance tests")
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".hlb")
+		t.Run(name, func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ext, got := conformance(filepath.Base(path), src)
+			if wantErr := strings.HasPrefix(name, "error_"); wantErr != (ext == ".err") {
+				// Outputs aren't updated either, so that a source parsed
+				// wrongly isn't recorded as expected.
+				t.Fatalf("got %s output, but only error_ sources are expected to fail:\n%s", ext, got)
+			}
+
+			base := strings.TrimSuffix(path, ".hlb")
+			if *update {
+				for _, stale := range []string{".ast", ".err"} {
+					os.Remove(base + stale)
+				}
+				if err := os.WriteFile(base+ext, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(base + ext)
+			if os.IsNotExist(err) {
+				t.Fatalf("got %s output, want the output of the other kind:\n%s", ext, got)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// TestConformanceCoverage checks that the conformance tests cover every
+// alternative of the statements, literals, references and strings. Newline
+// statements aren't produced, as the lexer turns every newline into a
+// semicolon or whitespace.
+func TestConformanceCoverage(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "conformance", "*.hlb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	covered := map[string]bool{}
+	for _, path := range paths {
+		f, err := ParseFile(path, Options{Comments: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Module == nil {
+			continue
+		}
+		Inspect(f.Module, func(node Node) bool {
+			switch node.(type) {
+			case *Stmt, *Literal, *RefNext, *StringLit:
+				v := reflect.ValueOf(node).Elem()
+				for i := 0; i < v.NumField(); i++ {
+					if !v.Type().Field(i).Anonymous && !v.Field(i).IsZero() {
+						covered[v.Type().Name()+"."+v.Type().Field(i).Name] = true
+					}
+				}
+			}
+			return true
+		})
+	}
+	for _, node := range []interface{}{Stmt{}, Literal{}, RefNext{}, StringLit{}} {
+		typ := reflect.TypeOf(node)
+		for i := 0; i < typ.NumField(); i++ {
+			name := typ.Name() + "." + typ.Field(i).Name
+			if !typ.Field(i).Anonymous && typ.Field(i).Name != "Newline" && !covered[name] {
+				t.Errorf("%s isn't covered", name)
+			}
+		}
+	}
+}
+
+// conformance parses src with every feature enabled and returns the
+// extension and content of its expected output.
+func conformance(filename string, src []byte) (ext, out string) {
+	f := ParseSource(filename, src, Options{
+		Comments: true,
+		Features: ForLoops | Splats | Subscripts,
+	})
+	var sb strings.Builder
+	if len(f.Diagnostics) > 0 {
+		for _, d := range f.Diagnostics {
+			fmt.Fprintln(&sb, d)
+		}
+		return ".err", sb.String()
+	}
+	if err := Dump(&sb, f.Module, DumpOptions{}); err != nil {
+		panic(err)
+	}
+	return ".ast", sb.String()
+}
@@ -0,0 +1,33 @@
+package ast
+
+import "strings"
+
+// Doc returns the text of the comments on the lines immediately preceding
+// fun, with the comment markers removed. Comments separated from fun by a
+// blank line are not part of its documentation.
+func (m *Module) Doc(fun *FuncDecl) string {
+	preceding := m.Comments
+	for i, decl := range m.Decls {
+		if decl.Func == fun {
+			if i > 0 {
+				preceding = m.Decls[i-1].Comments
+			}
+			break
+		}
+	}
+	if preceding == nil {
+		return ""
+	}
+
+	var lines []string
+	line := fun.Pos.Line - 1
+	for i := len(preceding.Comments) - 1; i >= 0; i-- {
+		c := preceding.Comments[i]
+		if c.Pos.Line != line {
+			break
+		}
+		lines = append([]string{strings.TrimPrefix(c.Text, " ")}, lines...)
+		line--
+	}
+	return strings.Join(lines, "\n")
+}
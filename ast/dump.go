@@ -0,0 +1,286 @@
+package ast
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DumpOptions configures Dump.
+type DumpOptions struct {
+	// MaxDepth is the depth of the deepest nodes written, with node at depth
+	// 1, or 0 to write all of them. Nodes with children left out are
+	// followed by "...".
+	MaxDepth int
+
+	// Kinds are the kinds of nodes written, like "FuncDecl", or empty to
+	// write nodes of every kind. The nodes written are indented under the
+	// closest ancestor that is written.
+	Kinds []string
+}
+
+// tokenKinds are the kinds of nodes holding a keyword or punctuation, which
+// Dump leaves out as their parent tells them apart.
+var tokenKinds = map[string]bool{
+	"As":               true,
+	"At":               true,
+	"Backtick":         true,
+	"CloseBrace":       true,
+	"CloseBracket":     true,
+	"CloseParen":       true,
+	"Else":             true,
+	"For":              true,
+	"From":             true,
+	"Func":             true,
+	"HeredocEnd":       true,
+	"If":               true,
+	"Import":           true,
+	"In":               true,
+	"Newline":          true,
+	"OpenBrace":        true,
+	"OpenBracket":      true,
+	"OpenInterpolated": true,
+	"OpenParen":        true,
+	"Public":           true,
+	"Quote":            true,
+	"With":             true,
+}
+
+// wrapperKinds are the kinds of nodes holding one of several alternatives,
+// or an operand without its operator. Dump leaves them out when they hold a
+// single node.
+var wrapperKinds = map[string]bool{
+	"Decl":      true,
+	"Expr":      true,
+	"ExprStmt":  true,
+	"FieldStmt": true,
+	"Literal":   true,
+	"Ref":       true,
+	"RefNext":   true,
+	"Stmt":      true,
+	"Terminal":  true,
+	"Unary":     true,
+}
+
+// Dump writes node to w as an indented tree, one node per line with its kind,
+// its value if any and its source range:
+//
+//	FuncDecl 1:1-3:2
+//	  Name: Ident "build" 1:5-1:10
+//	  Params: FieldList 1:10-1:12
+//	  Type fs 1:13-1:15
+//	  Body: StmtList 1:16-3:2
+//	    Ref 2:2-2:17
+//	      Terminal: Ident "image" 2:2-2:7
+//	      Next: Call 2:7-2:17
+//	        Args: ExprList 2:7-2:17
+//	          StringLit "alpine" 2:8-2:16
+//
+// Nodes are labeled with the field of their parent holding them, unless it's
+// named after their kind or they're in a list. Fields that aren't set, nodes
+// holding a keyword or punctuation, and wrappers holding a single node are
+// left out. Types and strings without interpolations are written as their
+// value, without children.
+func Dump(w io.Writer, node Node, opts DumpOptions) error {
+	d := &dumper{w: bufio.NewWriter(w), opts: opts}
+	if len(opts.Kinds) > 0 {
+		d.kinds = make(map[string]bool)
+		for _, kind := range opts.Kinds {
+			d.kinds[kind] = true
+		}
+	}
+	d.node(reflect.ValueOf(node), "", 0)
+	return d.w.Flush()
+}
+
+type dumper struct {
+	w     *bufio.Writer
+	opts  DumpOptions
+	kinds map[string]bool
+}
+
+// node writes v, held by the field label of its parent, and its children.
+// depth is the number of ancestors written.
+func (d *dumper) node(v reflect.Value, label string, depth int) {
+	n, ok := v.Interface().(Node)
+	if !ok || v.IsNil() {
+		return
+	}
+	kind := v.Elem().Type().Name()
+	if tokenKinds[kind] {
+		return
+	}
+	value, leaf := dumpValue(n)
+
+	switch {
+	case d.kinds != nil:
+		// Labels would name fields of nodes that aren't written.
+		if !d.kinds[kind] {
+			d.children(v, false, "", depth)
+			return
+		}
+		label = ""
+	case wrapperKinds[kind] && value == "" && len(children(v)) == 1:
+		d.children(v, true, label, depth)
+		return
+	}
+
+	if d.opts.MaxDepth > 0 && depth >= d.opts.MaxDepth {
+		return
+	}
+	line := strings.Repeat("  ", depth)
+	if label != "" && label != kind {
+		line += label + ": "
+	}
+	line += kind
+	if value != "" {
+		line += " " + value
+	}
+	if r := dumpRange(n); r != "" {
+		line += " " + r
+	}
+	if !leaf && d.opts.MaxDepth > 0 && depth+1 >= d.opts.MaxDepth && d.hasChildren(v) {
+		line += " ..."
+	}
+	fmt.Fprintln(d.w, line)
+	if !leaf {
+		d.children(v, false, "", depth+1)
+	}
+}
+
+// children writes the children of the node v. If v is elided, they're
+// labeled with label in its place, and otherwise with the fields holding
+// them.
+func (d *dumper) children(v reflect.Value, elided bool, label string, depth int) {
+	s := v.Elem()
+	for i := 0; i < s.NumField(); i++ {
+		field := s.Type().Field(i)
+		if field.Anonymous || field.PkgPath != "" {
+			continue
+		}
+		childLabel := label
+		if !elided && d.kinds == nil {
+			childLabel = field.Name
+		}
+		fv := s.Field(i)
+		switch fv.Kind() {
+		case reflect.Ptr:
+			d.node(fv, childLabel, depth)
+		case reflect.Slice:
+			for j := 0; j < fv.Len(); j++ {
+				d.node(fv.Index(j), label, depth)
+			}
+		}
+	}
+}
+
+// hasChildren reports whether the node v has children that would be written
+// without a depth limit.
+func (d *dumper) hasChildren(v reflect.Value) bool {
+	found := false
+	Inspect(v.Interface().(Node), func(n Node) bool {
+		if n == nil || found {
+			return false
+		}
+		if n != v.Interface().(Node) {
+			kind := reflect.TypeOf(n).Elem().Name()
+			found = !tokenKinds[kind] && (d.kinds == nil || d.kinds[kind])
+		}
+		return !found
+	})
+	return found
+}
+
+// children returns the children of the node v that Dump may write.
+func children(v reflect.Value) []Node {
+	var nodes []Node
+	Walk(inspector(func(n Node) bool {
+		if n == nil || n == v.Interface().(Node) {
+			return n != nil
+		}
+		if !tokenKinds[reflect.TypeOf(n).Elem().Name()] {
+			nodes = append(nodes, n)
+		}
+		return false
+	}), v.Interface().(Node))
+	return nodes
+}
+
+// dumpValue returns the value written for n, and whether its children are
+// left out as the value describes them.
+func dumpValue(n Node) (value string, leaf bool) {
+	switch n := n.(type) {
+	case *Ident:
+		return strconv.Quote(n.Text), true
+	case *Comment:
+		return strconv.Quote(n.Text), true
+	case *Modifier:
+		if n.Public != nil {
+			return n.Public.Text, true
+		}
+	case *Type:
+		return n.String(), true
+	case *Splat:
+		return n.Text, true
+	case *Field:
+		if n.Variadic != nil {
+			return *n.Variadic, false
+		}
+	case *Expr:
+		return n.Op.String(), false
+	case *Unary:
+		return n.Op.String(), false
+	case *Literal:
+		switch {
+		case n.Decimal != nil:
+			return strconv.Itoa(*n.Decimal), true
+		case n.Numeric != nil:
+			return numericString(n.Numeric), true
+		case n.Bool != nil:
+			return strconv.FormatBool(*n.Bool), true
+		}
+	case *StringLit:
+		if s, ok := n.Unquoted(); ok {
+			return strconv.Quote(s), true
+		}
+	case *Heredoc:
+		return n.Start, false
+	case *RawHeredoc:
+		return n.Start, false
+	case *StringFragment:
+		switch {
+		case n.Escaped != nil:
+			return strconv.Quote(*n.Escaped), true
+		case n.Text != nil:
+			return strconv.Quote(*n.Text), true
+		}
+	case *HeredocFragment:
+		switch {
+		case n.Spaces != nil:
+			return strconv.Quote(*n.Spaces), true
+		case n.Escaped != nil:
+			return strconv.Quote(*n.Escaped), true
+		case n.Text != nil:
+			return strconv.Quote(*n.Text), true
+		}
+	}
+	return "", false
+}
+
+func numericString(n *NumericLit) string {
+	prefix := map[int]string{2: "0b", 8: "0o", 16: "0x"}[n.Base]
+	return prefix + strconv.FormatInt(n.Value, n.Base)
+}
+
+// dumpRange returns the source range of n as line:col-line:col, or an empty
+// string if n has no position, like nodes built rather than parsed.
+func dumpRange(n Node) string {
+	pos, end := n.Position(), n.EndPosition()
+	if pos.Line == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d:%d-%d:%d", pos.Line, pos.Column, end.Line, end.Column)
+}
@@ -0,0 +1,112 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	src := `# Builds the image.
+pub fun build(string... tags) fs {
+	image("alpine") with { platform: "linux/" + "amd64" }
+	for (tag in tags) {
+		run(<<EOF
+			echo ${tag}
+		EOF)
+	}
+}
+`
+	for _, tc := range []struct {
+		name string
+		opts DumpOptions
+		want string
+	}{{
+		name: "all",
+		want: `Module 1:1-10:1
+  Comments 1:1-2:1
+    Comment " Builds the image." 1:1-2:1
+  FuncDecl 2:1-9:2
+    Modifier pub 2:1-2:4
+    Name: Ident "build" 2:9-2:14
+    Params: FieldList 2:14-2:30
+      Field ... 2:15-2:29
+        Type string 2:15-2:21
+        Name: Ident "tags" 2:25-2:29
+    Type fs 2:31-2:33
+    Body: StmtList 2:34-9:2
+      Ref 3:2-3:55
+        Terminal: Ident "image" 3:2-3:7
+        Next: Call 3:7-3:55
+          Args: ExprList 3:7-3:17
+            StringLit "alpine" 3:8-3:16
+          With: WithClause 3:18-3:55
+            Expr: BlockLit 3:23-3:55
+              Block: StmtList 3:23-3:55
+                Entry 3:25-3:53
+                  Ident "platform" 3:25-3:33
+                  Value: Expr + 3:35-3:53
+                    Left: StringLit "linux/" 3:35-3:43
+                    Right: StringLit "amd64" 3:46-3:53
+      ForStmt 4:2-8:3
+        Header: ForHeader 4:6-4:19
+          Var: Ident "tag" 4:7-4:10
+          Iterable: Ident "tags" 4:14-4:18
+        Body: StmtList 4:20-8:3
+          Ref 5:3-7:7
+            Terminal: Ident "run" 5:3-5:6
+            Next: Call 5:6-7:7
+              Args: ExprList 5:6-7:7
+                StringLit 5:7-7:6
+                  Heredoc <<EOF 5:7-7:6
+                    HeredocFragment "\n\t\t\t" 5:12-6:4
+                    HeredocFragment "echo" 6:4-6:8
+                    HeredocFragment " " 6:8-6:9
+                    HeredocFragment 6:9-6:15
+                      Interpolated 6:9-6:15
+                        Expr: Ident "tag" 6:11-6:14
+                    HeredocFragment "\n\t\t" 6:15-7:3
+`,
+	}, {
+		name: "max depth",
+		opts: DumpOptions{MaxDepth: 3},
+		want: `Module 1:1-10:1
+  Comments 1:1-2:1
+    Comment " Builds the image." 1:1-2:1
+  FuncDecl 2:1-9:2
+    Modifier pub 2:1-2:4
+    Name: Ident "build" 2:9-2:14
+    Params: FieldList 2:14-2:30 ...
+    Type fs 2:31-2:33
+    Body: StmtList 2:34-9:2 ...
+`,
+	}, {
+		name: "kinds",
+		opts: DumpOptions{Kinds: []string{"FuncDecl", "Call", "Interpolated"}},
+		want: `FuncDecl 2:1-9:2
+  Call 3:7-3:55
+  Call 5:6-7:7
+    Interpolated 6:9-6:15
+`,
+	}, {
+		name: "kinds with max depth",
+		opts: DumpOptions{Kinds: []string{"FuncDecl", "Call", "Interpolated"}, MaxDepth: 2},
+		want: `FuncDecl 2:1-9:2
+  Call 3:7-3:55
+  Call 5:6-7:7 ...
+`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := ParseSource("test.hlb", []byte(src), Options{Comments: true, Features: ForLoops})
+			if err := f.Err(); err != nil {
+				t.Fatal(err)
+			}
+			var sb strings.Builder
+			if err := Dump(&sb, f.Module, tc.opts); err != nil {
+				t.Fatal(err)
+			}
+			if got := sb.String(); got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
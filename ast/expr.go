@@ -10,13 +10,15 @@ import (
 var (
 	unaryParser = participle.MustBuild(
 		&Unary{},
-		participle.Lexer(Lexer),
+		participle.Lexer(Scanner),
 	)
 
 	operatorToken = Lexer.Symbols()["Operator"]
 )
 
 type Expr struct {
+	Mixin
+
 	Unary *Unary
 
 	Left  *Expr
@@ -26,6 +28,9 @@ type Expr struct {
 
 // Parse expressions with a precedence climbing implementation.
 func (e *Expr) Parse(lex *lexer.PeekingLexer) error {
+	if err := checkParsing(lex); err != nil {
+		return err
+	}
 	ex, err := parseExpr(lex, 0)
 	if err != nil {
 		return err
@@ -96,6 +101,29 @@ func (o *Op) Capture(values []string) error {
 	return nil
 }
 
+var opStrings = map[Op]string{
+	OpGe:  ">=",
+	OpLe:  "<=",
+	OpAnd: "&&",
+	OpOr:  "||",
+	OpEq:  "==",
+	OpNe:  "!=",
+	OpSub: "-",
+	OpAdd: "+",
+	OpMul: "*",
+	OpDiv: "/",
+	OpLt:  "<",
+	OpGt:  ">",
+	OpMod: "%",
+	OpPow: "^",
+	OpNot: "!",
+	OpMrg: "&",
+}
+
+func (o Op) String() string {
+	return opStrings[o]
+}
+
 type opInfo struct {
 	RightAssociative bool
 	Priority         int
@@ -150,6 +178,8 @@ func parseExpr(lex *lexer.PeekingLexer, minPrec int) (*Expr, error) {
 
 		expr.Left = lhs
 		expr.Right = rhs
+		expr.Pos = lhs.Pos
+		expr.EndPos = rhs.EndPos
 		lhs = expr
 	}
 
@@ -162,5 +192,8 @@ func parseOperand(lex *lexer.PeekingLexer) (*Expr, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Expr{Unary: u}, nil
+	return &Expr{
+		Mixin: Mixin{Pos: u.Pos, EndPos: u.EndPos},
+		Unary: u,
+	}, nil
 }
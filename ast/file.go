@@ -0,0 +1,437 @@
+package ast
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	participle "github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/codes"
+)
+
+// Features is a set of experimental syntax. Modules using syntax that isn't
+// enabled are reported.
+type Features uint
+
+const (
+	// ForLoops are for statements iterating over arrays.
+	ForLoops Features = 1 << iota
+
+	// Splats are arrays spread over variadic parameters, like `args...`.
+	Splats
+
+	// Subscripts are indices and slices of arrays, like `args[0]`.
+	Subscripts
+
+	// AllFeatures is the set of all experimental syntax.
+	AllFeatures = ForLoops | Splats | Subscripts
+)
+
+var featureNames = map[Features]string{
+	ForLoops:   "for loops",
+	Splats:     "splats",
+	Subscripts: "subscripts",
+}
+
+// Options configures parsing.
+type Options struct {
+	// Comments keeps the comments in the module. Otherwise they are dropped
+	// from it, but not from the tokens of the file.
+	Comments bool
+
+	// Trace writes a trace of the grammar rules tried by the parser when not
+	// nil. Traced parses are run one at a time.
+	Trace io.Writer
+
+	// Recover continues parsing at the next top-level declaration after a
+	// syntax error, so that every declaration with errors is reported and
+	// the others are kept in the module.
+	Recover bool
+
+	// Context cancels parsing when done. It's checked while lexing, before
+	// parsing each expression, and between the declarations parsed when
+	// recovering.
+	Context context.Context
+
+	// MaxSize limits the size of the source in bytes, or is zero for no
+	// limit.
+	MaxSize int
+
+	// MaxTokens limits the number of tokens of the source, not counting
+	// whitespace, or is zero for no limit.
+	MaxTokens int
+
+	// MaxDepth limits how deeply blocks, brackets, strings and interpolations
+	// can be nested, or is zero for no limit.
+	MaxDepth int
+
+	// MaxErrors limits the number of syntax errors reported when recovering,
+	// or is zero for no limit. Each error costs a pass over the source.
+	MaxErrors int
+
+	// Features enables experimental syntax. None is enabled by default,
+	// because experimental syntax may still change and modules using it may
+	// stop parsing; the hlb command, the language server and the checker
+	// opt in with AllFeatures.
+	Features Features
+}
+
+// File is a parsed source file.
+type File struct {
+	Filename string
+
+	// Module is nil if the source can't be parsed and errors aren't
+	// recovered from.
+	Module *Module
+
+	// Tokens are all the tokens of the source, including the whitespace and
+	// comments the parser skips.
+	Tokens []lexer.Token
+
+	Diagnostics []*Diagnostic
+
+	src []byte
+}
+
+// Err returns the first diagnostic of f, or nil if there are none.
+func (f *File) Err() error {
+	if len(f.Diagnostics) == 0 {
+		return nil
+	}
+	return f.Diagnostics[0]
+}
+
+// Diagnostic is a problem found while parsing.
+type Diagnostic struct {
+	Pos     lexer.Position
+	End     lexer.Position
+	Message string
+
+	// Code identifies the kind of error in the codes catalog, and is empty
+	// for errors that have no entry.
+	Code string
+
+	// Hint suggests a fix for common mistakes, or is empty.
+	Hint string
+
+	// Source is the line of the source Pos is on.
+	Source string
+}
+
+func (d *Diagnostic) Error() string {
+	if d.Code != "" {
+		return fmt.Sprintf("%s: %s [%s]", d.Pos, d.Message, d.Code)
+	}
+	return fmt.Sprintf("%s: %s", d.Pos, d.Message)
+}
+
+// Report returns d on several lines: the error, then the source line with a
+// caret under the column of d, then the hint if any.
+func (d *Diagnostic) Report() string {
+	var sb strings.Builder
+	sb.WriteString(d.Error())
+	if d.Source != "" && d.Pos.Column > 0 {
+		// Keep the tabs before the column so the caret lines up.
+		var indent []rune
+		for i, r := range []rune(d.Source) {
+			if i >= d.Pos.Column-1 {
+				break
+			}
+			if r != '\t' {
+				r = ' '
+			}
+			indent = append(indent, r)
+		}
+		fmt.Fprintf(&sb, "\n\t%s\n\t%s^", d.Source, string(indent))
+	}
+	if d.Hint != "" {
+		fmt.Fprintf(&sb, "\n\thint: %s", d.Hint)
+	}
+	return sb.String()
+}
+
+// ParseFile reads and parses the file filename. Only reading the file fails;
+// syntax errors are diagnostics of the file.
+func ParseFile(filename string, opts Options) (*File, error) {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSource(filename, src, opts), nil
+}
+
+// ParseDir parses the files with the .hlb extension in dir, sorted by name.
+func ParseDir(dir string, opts Options) ([]*File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []*File
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".hlb" {
+			continue
+		}
+		f, err := ParseFile(filepath.Join(dir, entry.Name()), opts)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Filename < files[j].Filename
+	})
+	return files, nil
+}
+
+// ParseSource parses src, the source of the file filename.
+func ParseSource(filename string, src []byte, opts Options) *File {
+	f := &File{Filename: filename, src: src}
+	parser := moduleParser
+	if opts.Trace != nil {
+		traced.Lock()
+		defer traced.Unlock()
+		traced.w = opts.Trace
+		parser = tracedParser()
+	}
+
+	mod := &Module{}
+	tokens, err := lex(filename, src, opts)
+	if err == nil {
+		f.Tokens = tokens
+		err = parseContext(opts.Context, parser, replay(tokens), mod)
+	}
+	if _, ok := err.(*limitError); ok {
+		f.addError(err)
+		return f
+	}
+	switch {
+	case err == nil:
+		f.Module = mod
+	case opts.Recover:
+		f.Module = f.recover(opts, parser, src, err)
+	default:
+		f.addError(err)
+		return f
+	}
+
+	f.checkFeatures(opts.Features)
+	if !opts.Comments {
+		dropComments(f.Module)
+	}
+	return f
+}
+
+// addError adds a diagnostic for a lexing or parsing error.
+func (f *File) addError(err error) {
+	f.Diagnostics = append(f.Diagnostics, SyntaxDiagnostic(f.src, err))
+}
+
+// recover parses the declarations of src around syntax errors, starting with
+// err, the error parsing all of it. Parsing resumes at the next line starting
+// a declaration after each error, until the context of opts is done or there
+// are more errors than it allows.
+func (f *File) recover(opts Options, parser *participle.Parser, src []byte, err error) *Module {
+	// Declarations start with a keyword at the start of a line, or with the
+	// comment lines right above it.
+	var (
+		starts   []lexer.Position
+		comments *lexer.Position
+	)
+	pos := lexer.Position{Filename: f.Filename, Line: 1, Column: 1}
+	for _, line := range strings.SplitAfter(string(src), "\n") {
+		switch {
+		case strings.HasPrefix(line, "#"):
+			if comments == nil {
+				start := pos
+				comments = &start
+			}
+		case strings.HasPrefix(line, "fun "), strings.HasPrefix(line, "pub "), strings.HasPrefix(line, "import "):
+			if comments != nil {
+				starts = append(starts, *comments)
+			} else {
+				starts = append(starts, pos)
+			}
+			comments = nil
+		default:
+			comments = nil
+		}
+		pos.Offset += len(line)
+		pos.Line++
+	}
+
+	mod := &Module{}
+	add := func(region *Module) {
+		if len(mod.Decls) == 0 && mod.Comments == nil {
+			mod.Comments = region.Comments
+		} else if region.Comments != nil {
+			mod.Decls = append(mod.Decls, &Decl{Mixin: region.Comments.Mixin, Comments: region.Comments})
+		}
+		mod.Decls = append(mod.Decls, region.Decls...)
+	}
+	start := lexer.Position{Filename: f.Filename, Line: 1, Column: 1}
+	for errors := 1; ; errors++ {
+		f.addError(err)
+		errPos := f.Diagnostics[len(f.Diagnostics)-1].Pos
+		if _, ok := err.(*limitError); ok {
+			break
+		}
+		if err := checkContext(opts.Context, errPos); err != nil {
+			f.addError(err)
+			break
+		}
+		if opts.MaxErrors > 0 && errors >= opts.MaxErrors {
+			f.addError(&limitError{errPos, fmt.Sprintf("too many errors, stopped after %d", errors)})
+			break
+		}
+
+		// Keep the declarations before the one with the error.
+		i := sort.Search(len(starts), func(i int) bool {
+			return starts[i].Line > errPos.Line
+		})
+		if i > 0 && starts[i-1].Offset > start.Offset {
+			if region, err := parseRegion(opts.Context, parser, src[:starts[i-1].Offset], start); err == nil {
+				add(region)
+			}
+		}
+		if i == len(starts) || starts[i].Offset <= start.Offset {
+			break
+		}
+
+		start = starts[i]
+		var region *Module
+		region, err = parseRegion(opts.Context, parser, src, start)
+		if err == nil {
+			add(region)
+			break
+		}
+	}
+	return mod
+}
+
+// checkFeatures adds a diagnostic for each use of experimental syntax that
+// isn't enabled.
+func (f *File) checkFeatures(enabled Features) {
+	if f.Module == nil {
+		return
+	}
+	Inspect(f.Module, func(node Node) bool {
+		var feature Features
+		switch node.(type) {
+		case *ForStmt:
+			feature = ForLoops
+		case *Splat:
+			feature = Splats
+		case *Subscript:
+			feature = Subscripts
+		}
+		if feature != 0 && enabled&feature == 0 {
+			f.Diagnostics = append(f.Diagnostics, &Diagnostic{
+				Pos:     node.Position(),
+				End:     node.EndPosition(),
+				Message: fmt.Sprintf("%s are experimental and not enabled", featureNames[feature]),
+				Code:    codes.ExperimentalSyntax,
+			})
+		}
+		return true
+	})
+	sort.SliceStable(f.Diagnostics, func(i, j int) bool {
+		return f.Diagnostics[i].Pos.Offset < f.Diagnostics[j].Pos.Offset
+	})
+}
+
+// dropComments removes the comments of mod.
+func dropComments(mod *Module) {
+	if mod == nil {
+		return
+	}
+	mod.Comments = nil
+	Inspect(mod, func(node Node) bool {
+		switch n := node.(type) {
+		case *Module:
+			var decls []*Decl
+			for _, decl := range n.Decls {
+				if decl.Comments == nil {
+					decls = append(decls, decl)
+				}
+			}
+			n.Decls = decls
+		case *StmtList:
+			var stmts []*Stmt
+			for _, stmt := range n.Stmts {
+				if stmt.Comments == nil {
+					stmts = append(stmts, stmt)
+				}
+			}
+			n.Stmts = stmts
+		case *FieldList:
+			var fields []*FieldStmt
+			for _, field := range n.Fields {
+				if field.Comments == nil {
+					fields = append(fields, field)
+				}
+			}
+			n.Fields = fields
+		case *ExprList:
+			var exprs []*ExprStmt
+			for _, expr := range n.Exprs {
+				if expr.Comments == nil {
+					exprs = append(exprs, expr)
+				}
+			}
+			n.Exprs = exprs
+		}
+		return true
+	})
+}
+
+// traced is the parser tracing to the writer of the parse holding the lock.
+// Tracing is an option of the parser rather than of a parse.
+var traced struct {
+	sync.Mutex
+	once   sync.Once
+	parser *participle.Parser
+	w      io.Writer
+}
+
+func tracedParser() *participle.Parser {
+	traced.once.Do(func() {
+		traced.parser = participle.MustBuild(
+			&Module{},
+			participle.Lexer(&semicolonLexerDefinition{}),
+			participle.Elide("Whitespace"),
+			participle.Trace(traceWriter{}),
+		)
+	})
+	return traced.parser
+}
+
+type traceWriter struct{}
+
+func (traceWriter) Write(p []byte) (int, error) {
+	return traced.w.Write(p)
+}
+
+// replay returns a lexer replaying tokens for the parser, skipping
+// whitespace.
+func replay(tokens []lexer.Token) *lexer.PeekingLexer {
+	peeker, _ := lexer.Upgrade(&tokenLexer{tokens: tokens}, whitespaceToken)
+	return peeker
+}
+
+type tokenLexer struct {
+	tokens []lexer.Token
+}
+
+func (l *tokenLexer) Next() (lexer.Token, error) {
+	token := l.tokens[0]
+	if len(l.tokens) > 1 {
+		l.tokens = l.tokens[1:]
+	}
+	return token, nil
+}
@@ -0,0 +1,343 @@
+package ast
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hinshun/hlb-parser/codes"
+)
+
+const recoverSource = `# Module comment.
+fun a() fs {
+	image("alpine"
+}
+
+fun b() fs {
+	scratch
+}
+
+fun c() fs {
+	run(]
+}
+
+# Trailing comment.
+fun d(string... args) fs {
+	for (arg in args) {
+		run(arg)
+	}
+}
+`
+
+func TestParseSource(t *testing.T) {
+	f := ParseSource("test.hlb", []byte(recoverSource), Options{Recover: true, Comments: true})
+	if len(f.Diagnostics) != 3 {
+		t.Fatalf("got diagnostics %v, want errors in a and c and a for loop", f.Diagnostics)
+	}
+	if got := f.Diagnostics[0].Pos.Line; got != 4 {
+		t.Errorf("got first error on line %d, want 4", got)
+	}
+	if got := f.Diagnostics[1].Pos.Line; got != 11 {
+		t.Errorf("got second error on line %d, want 11", got)
+	}
+	if msg := f.Diagnostics[2].Message; msg != "for loops are experimental and not enabled" {
+		t.Errorf("got message %q", msg)
+	}
+	var names []string
+	for _, decl := range f.Module.Decls {
+		if decl.Func != nil {
+			names = append(names, decl.Func.Name.Text)
+		}
+	}
+	if strings.Join(names, " ") != "b d" {
+		t.Errorf("got functions %v, want b and d", names)
+	}
+	if len(f.Module.Decls) != 3 || f.Module.Decls[1].Comments == nil {
+		t.Errorf("comments weren't kept")
+	}
+
+	f = ParseSource("test.hlb", []byte(recoverSource), Options{})
+	if f.Module != nil || len(f.Diagnostics) != 1 {
+		t.Errorf("got module and diagnostics %v without recovering", f.Diagnostics)
+	}
+
+	src := []byte("# Comment.\nfun d(string... args) fs {\n\t# Comment.\n\tfor (arg in args) {\n\t\trun(arg)\n\t}\n}\n")
+	f = ParseSource("test.hlb", src, Options{Features: ForLoops})
+	if f.Err() != nil {
+		t.Fatal(f.Err())
+	}
+	if f.Module.Comments != nil || len(f.Module.Decls[0].Func.Body.Stmts) != 1 {
+		t.Errorf("comments weren't dropped")
+	}
+	if last := f.Tokens[len(f.Tokens)-1]; !last.EOF() || last.Pos.Offset != len(src) {
+		t.Errorf("got last token %v, want EOF", last)
+	}
+
+	var trace bytes.Buffer
+	ParseSource("test.hlb", src, Options{Trace: &trace})
+	if trace.Len() == 0 {
+		t.Error("got no trace")
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	for _, src := range []string{"", "\n", "\n\t\n"} {
+		f := ParseSource("test.hlb", []byte(src), Options{})
+		if err := f.Err(); err != nil {
+			t.Errorf("%q: %s", src, err)
+			continue
+		}
+		if f.Module == nil || f.Module.Comments != nil || len(f.Module.Decls) != 0 {
+			t.Errorf("%q: got module %v, want an empty module", src, f.Module)
+		}
+	}
+}
+
+// TestParseFeatures checks that experimental syntax is reported unless it's
+// enabled, as it is by the tools parsing the repo's own build.hlb.
+func TestParseFeatures(t *testing.T) {
+	f, err := ParseFile("../build.hlb", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Diagnostics) == 0 {
+		t.Fatal("got no diagnostics without features")
+	}
+	for _, d := range f.Diagnostics {
+		if d.Code != codes.ExperimentalSyntax {
+			t.Errorf("got %s, want only experimental syntax", d.Report())
+		}
+	}
+
+	f, err = ParseFile("../build.hlb", Options{Features: AllFeatures})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Err(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	src := "# Comment.\nfun d(string... args) fs {\n\t# Comment.\n\tfor (arg in args) {\n\t\trun(arg)\n\t}\n}\n"
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, tc := range []struct {
+		name string
+		src  string
+		opts Options
+		line int
+		msg  string
+	}{{
+		name: "depth",
+		src:  src,
+		opts: Options{MaxDepth: 2, Recover: true},
+		line: 5,
+		msg:  "nesting exceeds the maximum depth of 2",
+	}, {
+		name: "size",
+		src:  src,
+		opts: Options{MaxSize: 16, Recover: true},
+		line: 1,
+		msg:  "source exceeds the maximum size of 16 bytes",
+	}, {
+		name: "tokens",
+		src:  src,
+		opts: Options{MaxTokens: 20, Recover: true},
+		line: 2,
+		msg:  "source exceeds the maximum of 20 tokens",
+	}, {
+		name: "canceled",
+		src:  src,
+		opts: Options{Context: canceled, Recover: true},
+		line: 1,
+		msg:  "parsing canceled: context canceled",
+	}, {
+		name: "nested parens",
+		src:  "fun a() fs {\n\tf" + strings.Repeat("(", 100000) + "\n}\n",
+		opts: Untrusted,
+		line: 2,
+		msg:  "nesting exceeds the maximum depth of 64",
+	}, {
+		name: "unterminated heredoc",
+		src:  "fun a() fs {\n\trun(<<EOF\n" + strings.Repeat("a\n", 1<<20),
+		opts: Untrusted,
+		line: 1,
+		msg:  "source exceeds the maximum size of 1048576 bytes",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := ParseSource("test.hlb", []byte(tc.src), tc.opts)
+			if f.Module != nil || len(f.Diagnostics) != 1 {
+				t.Fatalf("got diagnostics %v, want only a limit exceeded", f.Diagnostics)
+			}
+			if d := f.Diagnostics[0]; d.Pos.Line != tc.line || d.Message != tc.msg {
+				t.Errorf("got %v, want %q on line %d", d, tc.msg, tc.line)
+			}
+			if d := CheckLimits("test.hlb", []byte(tc.src), tc.opts); d == nil || d.Message != tc.msg {
+				t.Errorf("got limits diagnostic %v, want %q", d, tc.msg)
+			}
+		})
+	}
+
+	if d := CheckLimits("test.hlb", []byte(src), Untrusted); d != nil {
+		t.Errorf("got diagnostic %v within the limits", d)
+	}
+}
+
+// countdown is a context that is done once Err has been called n times.
+type countdown struct {
+	context.Context
+	n int
+}
+
+func (c *countdown) Err() error {
+	if c.n--; c.n < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestParseCanceled(t *testing.T) {
+	src := "fun a() fs {\n\timage(\"alpine\")\n}\n\nfun b() fs {\n\timage(\"busybox\")\n\trun(\"make\")\n}\n"
+	for _, recover := range []bool{false, true} {
+		// Lexing checks the context once, then parsing before each
+		// expression.
+		ctx := &countdown{Context: context.Background(), n: 4}
+		f := ParseSource("test.hlb", []byte(src), Options{Context: ctx, Recover: recover})
+		if f.Module != nil || len(f.Diagnostics) != 1 {
+			t.Fatalf("recover %t: got diagnostics %v, want only parsing canceled", recover, f.Diagnostics)
+		}
+		if d := f.Diagnostics[0]; d.Pos.Line != 6 || d.Message != "parsing canceled: context canceled" {
+			t.Errorf("recover %t: got %v, want parsing canceled on line 6", recover, d)
+		}
+		if ctx.n >= 0 {
+			t.Errorf("recover %t: parsing never checked the context", recover)
+		}
+	}
+}
+
+func TestParseMaxErrors(t *testing.T) {
+	src := strings.Repeat("fun a() fs {\n\trun(\"make\") +\n}\n\n", 5)
+	f := ParseSource("test.hlb", []byte(src), Options{MaxErrors: 3, Recover: true})
+	var got []string
+	for _, d := range f.Diagnostics {
+		got = append(got, fmt.Sprintf("%d: %s", d.Pos.Line, d.Message))
+	}
+	want := []string{
+		`3: unexpected "}" in body of fun a, expected expression after "+"`,
+		`7: unexpected "}" in body of fun a, expected expression after "+"`,
+		`11: unexpected "}" in body of fun a, expected expression after "+"`,
+		"11: too many errors, stopped after 3",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got diagnostics:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// maxFuzzSize is the size of the largest fragments fuzzed by
+// FuzzParseSource. Larger sources are built by repeating them.
+const maxFuzzSize = 1 << 10
+
+// maxFuzzRepeat is the size of the largest sources built by repeating a
+// fragment past the token limit. Fragments with fewer tokens, mostly
+// whitespace, only reach the size limit, which is checked before lexing.
+const maxFuzzRepeat = 1 << 16
+
+// FuzzParseSource checks that parsing any source with the untrusted limits
+// neither panics nor hangs, and either returns a module or reports why not.
+// Each fragment fuzzed is also repeated past the token limit, which reaches
+// the depth limit when it nests, and past the size limit.
+func FuzzParseSource(f *testing.F) {
+	data, err := os.ReadFile("../build.hlb")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, src := range append(scannerCases, string(data), recoverSource) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		// Mutating and minimizing large sources takes long enough to stall
+		// the fuzzer, so they're only reached by repetition.
+		if len(src) > maxFuzzSize {
+			src = src[:maxFuzzSize]
+		}
+		fuzzParse(t, src)
+		if n := fuzzTokens(src); n > 0 && len(src)*(Untrusted.MaxTokens/n+1) <= maxFuzzRepeat {
+			fuzzParse(t, strings.Repeat(src, Untrusted.MaxTokens/n+1))
+		}
+		if len(src) > 0 {
+			fuzzParse(t, strings.Repeat(src, Untrusted.MaxSize/len(src)+1))
+		}
+	})
+}
+
+// fuzzTokens returns the number of tokens counted against the token limit
+// in src, or 0 if it doesn't lex.
+func fuzzTokens(src string) int {
+	tokens, err := lex("fuzz.hlb", []byte(src), Options{})
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, token := range tokens {
+		if token.Type != whitespaceToken && !token.EOF() {
+			n++
+		}
+	}
+	return n
+}
+
+// fuzzParse parses src with the untrusted limits and checks the result.
+func fuzzParse(t *testing.T, src string) {
+	t.Helper()
+	opts := Untrusted
+	opts.Recover = true
+	opts.Comments = true
+	done := make(chan *File)
+	go func() {
+		done <- ParseSource("fuzz.hlb", []byte(src), opts)
+	}()
+	var file *File
+	select {
+	case file = <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("parsing %d bytes starting with %.64q hangs", len(src), src)
+	}
+	if file.Module == nil && len(file.Diagnostics) == 0 {
+		t.Errorf("got neither a module nor diagnostics")
+	}
+	if file.Err() == nil && file.Module == nil {
+		t.Errorf("got no module without errors")
+	}
+	if file.Module == nil {
+		return
+	}
+	if d := CheckLimits("fuzz.hlb", []byte(src), opts); d != nil {
+		t.Errorf("got a module of %d bytes exceeding a limit: %s", len(src), d)
+	}
+}
+
+func TestParseDir(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"b.hlb":     "fun b() fs {\n\tscratch\n}\n",
+		"a.hlb":     "fun a() fs {\n",
+		"notes.txt": "not a module",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := ParseDir(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || filepath.Base(files[0].Filename) != "a.hlb" || files[0].Err() == nil || files[1].Err() != nil {
+		t.Errorf("unexpected files %+v", files)
+	}
+}
@@ -0,0 +1,151 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+//go:generate go test -run ^TestEBNF$ -update
+
+// EBNF returns the grammar of modules checked in as hlb.ebnf: the productions
+// of GrammarEBNF followed by the rules of each state of Lexer, with the
+// pattern of a rule written as a special sequence.
+func EBNF() string {
+	var sb strings.Builder
+	sb.WriteString(`(* The grammar of HLB modules, generated from the parser by go generate ./ast.
+
+   Tokens are written as <token>, and the productions of Expr climb the
+   operator precedences from the loosest to the tightest. Newlines ending a
+   statement are lexed as ";", as described in ast/lexer.go. *)
+
+`)
+	sb.WriteString(GrammarEBNF())
+	sb.WriteString(`
+(* The lexer starts in the state Root and matches the rules of its current
+   state in order. Matching a rule marked "push" continues in the state it
+   names, and matching one marked "pop" returns to the previous state. *)
+`)
+	rules := Lexer.Rules()
+	for _, state := range lexerStates(rules) {
+		fmt.Fprintf(&sb, "\n(* state %s *)\n", state)
+		own, included := rules[state], false
+		if state != "Root" {
+			own, included = trimRules(own, rules["Root"])
+		}
+		for _, rule := range own {
+			sb.WriteString(ruleEBNF(rule))
+		}
+		if included {
+			sb.WriteString("(* the rules of Root *)\n")
+		}
+	}
+	return sb.String()
+}
+
+// GrammarEBNF returns the productions of the grammar, one per line, in the
+// EBNF parsed by the participle ebnf package. It's the grammar of modules
+// with the productions of Expr, which parses by precedence climbing rather
+// than from struct tags, added before Unary.
+func GrammarEBNF() string {
+	var sb strings.Builder
+	for _, line := range strings.Split(strings.TrimSpace(moduleParser.String()), "\n") {
+		if strings.HasPrefix(line, "Unary = ") {
+			sb.WriteString(exprEBNF())
+		}
+		sb.WriteString(line + "\n")
+	}
+	return sb.String()
+}
+
+// exprEBNF returns a production for each level of opTable, from the loosest
+// to the tightest, whose operands are the productions of the next level and
+// those of the last level Unary. Operators missing from opTable have the
+// priority 0.
+func exprEBNF() string {
+	levels := map[int][]Op{}
+	for op := range opStrings {
+		priority := opTable[op].Priority
+		levels[priority] = append(levels[priority], op)
+	}
+	var priorities []int
+	for priority, ops := range levels {
+		priorities = append(priorities, priority)
+		sort.Slice(ops, func(i, j int) bool { return ops[i] < ops[j] })
+	}
+	sort.Ints(priorities)
+
+	var sb strings.Builder
+	for i, priority := range priorities {
+		name, operand := exprLevel(i), "Unary"
+		if i+1 < len(priorities) {
+			operand = exprLevel(i + 1)
+		}
+		var ops []string
+		for _, op := range levels[priority] {
+			ops = append(ops, fmt.Sprintf("%q", op))
+		}
+		choice := strings.Join(ops, " | ")
+		if len(ops) > 1 {
+			choice = "(" + choice + ")"
+		}
+		// Operators of a level share their associativity.
+		if opTable[levels[priority][0]].RightAssociative {
+			fmt.Fprintf(&sb, "%s = %s (%s %s)? .\n", name, operand, choice, name)
+		} else {
+			fmt.Fprintf(&sb, "%s = %s (%s %s)* .\n", name, operand, choice, operand)
+		}
+	}
+	return sb.String()
+}
+
+// exprLevel returns the name of the production of the ith loosest level.
+func exprLevel(i int) string {
+	if i == 0 {
+		return "Expr"
+	}
+	return fmt.Sprintf("Expr%d", i)
+}
+
+// lexerStates returns the states of rules, Root first and the others sorted.
+func lexerStates(rules lexer.Rules) []string {
+	states := []string{"Root"}
+	for state := range rules {
+		if state != "Root" {
+			states = append(states, state)
+		}
+	}
+	sort.Strings(states[1:])
+	return states
+}
+
+// trimRules returns rules without the rules of suffix they end with, which
+// is how Lexer.Rules returns the rules of a state including Root, and
+// whether they did.
+func trimRules(rules, suffix []lexer.Rule) ([]lexer.Rule, bool) {
+	n := len(rules) - len(suffix)
+	if n < 0 {
+		return rules, false
+	}
+	for i, rule := range suffix {
+		if rules[n+i] != rule {
+			return rules, false
+		}
+	}
+	return rules[:n], true
+}
+
+// ruleEBNF returns rule as a line of EBNF, named after the token it produces
+// as in productions.
+func ruleEBNF(rule lexer.Rule) string {
+	switch action := rule.Action.(type) {
+	case lexer.ActionPush:
+		return fmt.Sprintf("%s = ? /%s/ ? . (* push %s *)\n", strings.ToLower(rule.Name), rule.Pattern, action.State)
+	case lexer.ActionPop:
+		return fmt.Sprintf("%s = ? /%s/ ? . (* pop *)\n", strings.ToLower(rule.Name), rule.Pattern)
+	default:
+		return fmt.Sprintf("%s = ? /%s/ ? .\n", strings.ToLower(rule.Name), rule.Pattern)
+	}
+}
@@ -0,0 +1,65 @@
+package ast
+
+import (
+	"os"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/ebnf"
+)
+
+// TestEBNF checks that hlb.ebnf is the grammar of the current struct tags,
+// operators and lexer rules. Run with -update, or go generate, to rewrite it.
+func TestEBNF(t *testing.T) {
+	got := EBNF()
+	if *update {
+		if err := os.WriteFile("hlb.ebnf", []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile("hlb.ebnf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("hlb.ebnf is out of date, run go generate ./ast")
+	}
+}
+
+// TestGrammarEBNF checks that the productions parse, and that every
+// production referenced is defined, including those of Expr.
+func TestGrammarEBNF(t *testing.T) {
+	g, err := ebnf.ParseString(GrammarEBNF())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defined := map[string]bool{}
+	for _, p := range g.Productions {
+		defined[p.Production] = true
+	}
+	var check func(n ebnf.Node)
+	check = func(n ebnf.Node) {
+		switch n := n.(type) {
+		case *ebnf.Expression:
+			for _, seq := range n.Alternatives {
+				check(seq)
+			}
+		case *ebnf.SubExpression:
+			check(n.Expr)
+		case *ebnf.Sequence:
+			for _, term := range n.Terms {
+				check(term)
+			}
+		case *ebnf.Term:
+			if n.Name != "" && !defined[n.Name] {
+				t.Errorf("production %s isn't defined", n.Name)
+			}
+			if n.Group != nil {
+				check(n.Group)
+			}
+		}
+	}
+	for _, p := range g.Productions {
+		check(p.Expression)
+	}
+}
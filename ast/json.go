@@ -0,0 +1,266 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+//go:generate go test -run ^TestJSONSchema$ -update
+
+// JSONVersion is the version of the JSON encoding of modules described by
+// ast.schema.json. It's incremented whenever a change to the AST changes the
+// encoding, so tools consuming it can tell which nodes to expect.
+const JSONVersion = 1
+
+// MarshalJSON returns the JSON encoding of mod, the module parsed from the
+// file filename:
+//
+//	{"version": 1, "filename": "build.hlb", "module": {"kind": "Module", ...}}
+//
+// Each node is an object with its type name as "kind", its source range as
+// "pos" and "endPos", and its fields set in lower camel case. Fields that
+// aren't set are left out, so only the alternative a node holds is written.
+// Positions leave out the filename of the file. Operators are written as in
+// source and numeric literals as their value and base.
+func MarshalJSON(filename string, mod *Module) ([]byte, error) {
+	e := &jsonEncoder{filename: filename}
+	fmt.Fprintf(&e.buf, `{"version":%d,"filename":`, JSONVersion)
+	e.value(reflect.ValueOf(filename))
+	e.buf.WriteString(`,"module":`)
+	e.value(reflect.ValueOf(mod))
+	e.buf.WriteString("}")
+	if e.err != nil {
+		return nil, e.err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes the JSON encoding of a module written by MarshalJSON,
+// returning the filename of the module and a module equal to the one
+// encoded. It fails for encodings of other versions.
+func UnmarshalJSON(data []byte) (filename string, mod *Module, err error) {
+	var doc struct {
+		Version  int
+		Filename string
+		Module   json.RawMessage
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return "", nil, err
+	}
+	if doc.Version != JSONVersion {
+		return "", nil, fmt.Errorf("unsupported AST JSON version %d, expected %d", doc.Version, JSONVersion)
+	}
+	mod = &Module{}
+	d := &jsonDecoder{filename: doc.Filename}
+	if err := d.value(doc.Module, reflect.ValueOf(mod).Elem(), "module"); err != nil {
+		return "", nil, err
+	}
+	return doc.Filename, mod, nil
+}
+
+var (
+	opType         = reflect.TypeOf(OpNone)
+	numericLitType = reflect.TypeOf(NumericLit{})
+)
+
+// jsonName returns the name of the struct field name in JSON.
+func jsonName(name string) string {
+	r := []rune(name)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
+
+// jsonFields returns the fields of the struct type t written in JSON, which
+// are all but the embedded Mixin.
+func jsonFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); !f.Anonymous && f.PkgPath == "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+type jsonEncoder struct {
+	buf      bytes.Buffer
+	filename string
+	err      error
+}
+
+func (e *jsonEncoder) value(v reflect.Value) {
+	switch {
+	case v.Kind() == reflect.Ptr && v.IsNil():
+		e.buf.WriteString("null")
+	case v.Kind() == reflect.Ptr:
+		e.value(v.Elem())
+	case v.Type() == opType:
+		e.value(reflect.ValueOf(Op(v.Int()).String()))
+	case v.Type() == numericLitType:
+		fmt.Fprintf(&e.buf, `{"value":%d,"base":%d}`, v.Field(0).Int(), v.Field(1).Int())
+	case v.Kind() == reflect.Struct:
+		e.node(v)
+	case v.Kind() == reflect.Slice:
+		e.buf.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				e.buf.WriteString(",")
+			}
+			e.value(v.Index(i))
+		}
+		e.buf.WriteString("]")
+	default:
+		data, err := json.Marshal(v.Interface())
+		if err != nil && e.err == nil {
+			e.err = err
+		}
+		e.buf.Write(data)
+	}
+}
+
+func (e *jsonEncoder) node(v reflect.Value) {
+	fmt.Fprintf(&e.buf, `{"kind":%q`, v.Type().Name())
+	if m, ok := v.Addr().Interface().(Node); ok {
+		e.position("pos", m.Position())
+		e.position("endPos", m.EndPosition())
+	}
+	for _, f := range jsonFields(v.Type()) {
+		fv := v.FieldByIndex(f.Index)
+		if fv.IsZero() || (fv.Kind() == reflect.Slice && fv.Len() == 0) {
+			continue
+		}
+		fmt.Fprintf(&e.buf, `,%q:`, jsonName(f.Name))
+		e.value(fv)
+	}
+	e.buf.WriteString("}")
+}
+
+// position writes pos unless it's the zero position, with its filename only
+// if it isn't the one of the module.
+func (e *jsonEncoder) position(key string, pos lexer.Position) {
+	if pos == (lexer.Position{}) {
+		return
+	}
+	fmt.Fprintf(&e.buf, `,%q:{`, key)
+	if pos.Filename != e.filename {
+		e.buf.WriteString(`"filename":`)
+		e.value(reflect.ValueOf(pos.Filename))
+		e.buf.WriteString(",")
+	}
+	fmt.Fprintf(&e.buf, `"offset":%d,"line":%d,"column":%d}`, pos.Offset, pos.Line, pos.Column)
+}
+
+type jsonDecoder struct {
+	filename string
+}
+
+// value decodes data into v, described by path in errors.
+func (d *jsonDecoder) value(data json.RawMessage, v reflect.Value, path string) error {
+	switch {
+	case v.Kind() == reflect.Ptr && string(data) == "null":
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	case v.Kind() == reflect.Ptr:
+		v.Set(reflect.New(v.Type().Elem()))
+		return d.value(data, v.Elem(), path)
+	case v.Type() == opType:
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		var op Op
+		if err := op.Capture([]string{s}); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v.SetInt(int64(op))
+		return nil
+	case v.Type() == numericLitType:
+		return d.strict(data, v.Addr().Interface(), path)
+	case v.Kind() == reflect.Struct:
+		return d.node(data, v, path)
+	case v.Kind() == reflect.Slice:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(data, &elems); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		v.Set(reflect.MakeSlice(v.Type(), len(elems), len(elems)))
+		for i, elem := range elems {
+			if err := d.value(elem, v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if err := json.Unmarshal(data, v.Addr().Interface()); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	}
+}
+
+func (d *jsonDecoder) node(data json.RawMessage, v reflect.Value, path string) error {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	var kind string
+	if err := json.Unmarshal(obj["kind"], &kind); err != nil || kind != v.Type().Name() {
+		return fmt.Errorf("%s: expected kind %s, got %s", path, v.Type().Name(), obj["kind"])
+	}
+	delete(obj, "kind")
+
+	if _, ok := v.Addr().Interface().(Node); ok {
+		for key, field := range map[string]string{"pos": "Pos", "endPos": "EndPos"} {
+			if data, ok := obj[key]; ok {
+				if err := d.position(data, v.FieldByName(field), path+"."+key); err != nil {
+					return err
+				}
+				delete(obj, key)
+			}
+		}
+	}
+	for _, f := range jsonFields(v.Type()) {
+		name := jsonName(f.Name)
+		if data, ok := obj[name]; ok {
+			if err := d.value(data, v.FieldByIndex(f.Index), path+"."+name); err != nil {
+				return err
+			}
+			delete(obj, name)
+		}
+	}
+	if len(obj) > 0 {
+		var keys []string
+		for key := range obj {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return fmt.Errorf("%s: unknown fields of %s: %s", path, kind, strings.Join(keys, ", "))
+	}
+	return nil
+}
+
+func (d *jsonDecoder) position(data json.RawMessage, v reflect.Value, path string) error {
+	pos := lexer.Position{Filename: d.filename}
+	if err := d.strict(data, &pos, path); err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(pos))
+	return nil
+}
+
+// strict decodes data into v, failing on fields v doesn't have.
+func (d *jsonDecoder) strict(data json.RawMessage, v interface{}, path string) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,257 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestJSONRoundTrip checks that the modules of the conformance tests and the
+// example modules decode to the modules encoded, and that their encoding only
+// has kinds and fields of the schema.
+func TestJSONRoundTrip(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "conformance", "*.hlb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths = append(paths, "../bar.hlb", "../build.hlb", "../foo.hlb")
+
+	schema := map[string]interface{}{}
+	data, err := os.ReadFile("ast.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+	definitions := schema["definitions"].(map[string]interface{})
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := ParseSource(path, src, Options{
+				Comments: true,
+				Features: ForLoops | Splats | Subscripts,
+			})
+			if f.Module == nil || len(f.Diagnostics) > 0 {
+				t.Skip("source doesn't parse")
+			}
+
+			data, err := MarshalJSON(path, f.Module)
+			if err != nil {
+				t.Fatal(err)
+			}
+			filename, mod, err := UnmarshalJSON(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filename != path {
+				t.Errorf("got filename %q, want %q", filename, path)
+			}
+			if !reflect.DeepEqual(mod, f.Module) {
+				t.Errorf("decoded module differs from the module encoded")
+			}
+			again, err := MarshalJSON(filename, mod)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(again, data) {
+				t.Errorf("encoding isn't stable:\n%s\n%s", data, again)
+			}
+
+			var doc map[string]interface{}
+			if err := json.Unmarshal(data, &doc); err != nil {
+				t.Fatal(err)
+			}
+			checkSchema(t, definitions, doc["module"])
+		})
+	}
+}
+
+// checkSchema checks that the nodes in v have the kinds and fields of the
+// definitions of the schema.
+func checkSchema(t *testing.T, definitions map[string]interface{}, v interface{}) {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, elem := range v {
+			checkSchema(t, definitions, elem)
+		}
+	case map[string]interface{}:
+		kind, ok := v["kind"].(string)
+		if !ok {
+			return
+		}
+		def, ok := definitions[kind].(map[string]interface{})
+		if !ok {
+			t.Errorf("kind %s isn't defined", kind)
+			return
+		}
+		properties := def["properties"].(map[string]interface{})
+		for key, value := range v {
+			if _, ok := properties[key]; !ok {
+				t.Errorf("%s has no property %s", kind, key)
+			}
+			checkSchema(t, definitions, value)
+		}
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		data string
+		want string
+	}{{
+		name: "version",
+		data: `{"version":2,"filename":"a.hlb","module":{"kind":"Module"}}`,
+		want: "unsupported AST JSON version 2, expected 1",
+	}, {
+		name: "kind",
+		data: `{"version":1,"filename":"a.hlb","module":{"kind":"Module","decls":[{"kind":"FuncDecl"}]}}`,
+		want: `module.decls[0]: expected kind Decl, got "FuncDecl"`,
+	}, {
+		name: "unknown field",
+		data: `{"version":1,"filename":"a.hlb","module":{"kind":"Module","imports":[]}}`,
+		want: "module: unknown fields of Module: imports",
+	}, {
+		name: "operator",
+		data: `{"version":1,"filename":"a.hlb","module":{"kind":"Module","decls":[{"kind":"Decl","import":{"kind":"ImportDecl","expr":{"kind":"Expr","op":"=~"}}}]}}`,
+		want: `module.decls[0].import.expr.op: invalid expression operator "=~"`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := UnmarshalJSON([]byte(tc.data))
+			if err == nil || err.Error() != tc.want {
+				t.Errorf("got error %v, want %s", err, tc.want)
+			}
+		})
+	}
+}
+
+// TestJSONSchema checks that ast.schema.json describes the encoding of the
+// current nodes. Run with -update, or go generate, to rewrite it.
+func TestJSONSchema(t *testing.T) {
+	got, err := jsonSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *update {
+		if err := os.WriteFile("ast.schema.json", got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile("ast.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("ast.schema.json is out of date, run go generate ./ast")
+	}
+}
+
+// jsonSchema returns the JSON Schema of the encoding written by MarshalJSON.
+func jsonSchema() ([]byte, error) {
+	var ops []string
+	for _, s := range opStrings {
+		ops = append(ops, s)
+	}
+	sort.Strings(ops)
+
+	definitions := map[string]interface{}{
+		"Position": map[string]interface{}{
+			"description": "A position in the source. The filename is left out if it's the one of the module.",
+			"type":        "object",
+			"properties": map[string]interface{}{
+				"filename": map[string]interface{}{"type": "string"},
+				"offset":   map[string]interface{}{"type": "integer", "minimum": 0},
+				"line":     map[string]interface{}{"type": "integer", "minimum": 1},
+				"column":   map[string]interface{}{"type": "integer", "minimum": 1},
+			},
+			"required":             []string{"offset", "line", "column"},
+			"additionalProperties": false,
+		},
+		"Op": map[string]interface{}{
+			"description": "An operator as written in the source.",
+			"enum":        ops,
+		},
+		"NumericLit": map[string]interface{}{
+			"description": "A numeric literal written in base 2, 8 or 16.",
+			"type":        "object",
+			"properties": map[string]interface{}{
+				"value": map[string]interface{}{"type": "integer"},
+				"base":  map[string]interface{}{"enum": []int{2, 8, 16}},
+			},
+			"required":             []string{"value", "base"},
+			"additionalProperties": false,
+		},
+	}
+
+	var schemaOf func(t reflect.Type) map[string]interface{}
+	schemaOf = func(t reflect.Type) map[string]interface{} {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch {
+		case t == opType:
+			return map[string]interface{}{"$ref": "#/definitions/Op"}
+		case t == numericLitType:
+			return map[string]interface{}{"$ref": "#/definitions/NumericLit"}
+		case t.Kind() == reflect.Slice:
+			return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+		case t.Kind() == reflect.String:
+			return map[string]interface{}{"type": "string"}
+		case t.Kind() == reflect.Int:
+			return map[string]interface{}{"type": "integer"}
+		case t.Kind() == reflect.Bool:
+			return map[string]interface{}{"type": "boolean"}
+		}
+
+		ref := map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+		if _, ok := definitions[t.Name()]; ok {
+			return ref
+		}
+		properties := map[string]interface{}{
+			"kind":   map[string]interface{}{"const": t.Name()},
+			"pos":    map[string]interface{}{"$ref": "#/definitions/Position"},
+			"endPos": map[string]interface{}{"$ref": "#/definitions/Position"},
+		}
+		def := map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"required":             []string{"kind"},
+			"additionalProperties": false,
+		}
+		definitions[t.Name()] = def
+		for _, f := range jsonFields(t) {
+			properties[jsonName(f.Name)] = schemaOf(f.Type)
+		}
+		return ref
+	}
+
+	schema := map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "HLB module",
+		"description": "The JSON encoding of a module written by ast.MarshalJSON. Fields that aren't set are left out, so a node holding one of several alternatives only has the one it holds.",
+		"type":        "object",
+		"properties": map[string]interface{}{
+			"version":  map[string]interface{}{"const": JSONVersion},
+			"filename": map[string]interface{}{"type": "string"},
+			"module":   schemaOf(reflect.TypeOf(Module{})),
+		},
+		"required":             []string{"version", "filename", "module"},
+		"additionalProperties": false,
+		"definitions":          definitions,
+	}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
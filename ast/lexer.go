@@ -10,14 +10,45 @@ var (
 	parenToken      = Lexer.Symbols()["Paren"]
 	braceToken      = Lexer.Symbols()["Brace"]
 	newlineToken    = Lexer.Symbols()["Newline"]
+	whitespaceToken = Lexer.Symbols()["Whitespace"]
 	commentEndToken = Lexer.Symbols()["CommentEnd"]
 )
 
+// Semicolon insertion turns the newlines ending statements into semicolons,
+// so that statements are written one per line without them, like in Go. The
+// rules are:
+//
+//  1. A newline becomes a semicolon when the last token of its line is one of
+//     the terminators: an identifier, a number or boolean, the end of a
+//     string or heredoc, a closing parenthesis, bracket or brace, or the
+//     `...` of a splat.
+//  2. Otherwise the line continues on the next one, such as after an
+//     operator, a comma, an opening bracket or a keyword.
+//  3. The line also continues when the next line starts with a continuation:
+//     `else`, `with`, `as` or `.`, skipping blank lines.
+//  4. A comment runs to the end of its line, and the newline ending it is part
+//     of the comment, so it never becomes a semicolon. Comments are
+//     statements of their own: a comment after a statement ends it, and a
+//     comment can't come between an operator and its operand, or before a
+//     continuation.
+//
+// Other newlines are elided to whitespace.
+var (
+	terminatorTokens = tokenSet("Ident", "Decimal", "Numeric", "Bool", "StringEnd", "RawStringEnd", "HeredocEnd", "RawHeredocEnd", "ParenEnd", "BracketEnd", "BraceEnd")
+
+	continuations = map[string]bool{
+		"else": true,
+		"with": true,
+		"as":   true,
+		".":    true,
+	}
+)
+
 // A Lexer that inserts semi-colons.
 type semicolonLexerDefinition struct{}
 
 func (l *semicolonLexerDefinition) Lex(path string, r io.Reader) (lexer.Lexer, error) {
-	ll, err := Lexer.Lex(path, r)
+	ll, err := Scanner.Lex(path, r)
 	if err != nil {
 		return nil, err
 	}
@@ -30,39 +61,77 @@ func (l *semicolonLexerDefinition) Symbols() map[string]lexer.TokenType {
 
 type semicolonLexer struct {
 	lexer lexer.Lexer
-	last  lexer.Token
+
+	// last is the last token that isn't whitespace or a newline, or the
+	// semicolon inserted after it, and prev the one before it.
+	prev, last lexer.Token
+
+	// ahead are the tokens read to find the start of the next line, and err
+	// the error ending them if any.
+	ahead []lexer.Token
+	err   error
 }
 
 func (l *semicolonLexer) Next() (lexer.Token, error) {
-	for {
-		token, err := l.lexer.Next()
-		if err != nil {
-			return token, err
-		}
-		if token.Type != newlineToken {
-			l.last = token
-			return token, nil
-		}
+	token, err := l.next()
+	if err != nil {
+		return token, err
+	}
+	switch {
+	case token.Type == whitespaceToken:
+		return token, nil
+	case token.Type != newlineToken:
+		l.prev, l.last = l.last, token
+		return token, nil
+	case !l.terminated() || continuations[l.peek().Value]:
+		return elide(token), nil
+	}
+	token.Value = ";"
+	token.Type = ';'
+	l.prev, l.last = l.last, token
+	return token, nil
+}
+
+// terminated reports whether the last token can end a statement.
+func (l *semicolonLexer) terminated() bool {
+	return terminatorTokens[l.last.Type] || l.last.Value == "." && l.prev.Value == "."
+}
 
-		// Do we need to insert a semi-colon?
-		switch l.last.Value {
-		case ";", ",":
-			l.last = token
-			continue
-		case "}":
-			token.Value = ";"
-			token.Type = ';'
-		default:
-			switch l.last.Type {
-			case parenToken, braceToken, newlineToken, commentEndToken:
-				l.last = token
-				continue
-			default:
-				token.Value = ";"
-				token.Type = ';'
+func (l *semicolonLexer) next() (lexer.Token, error) {
+	if len(l.ahead) > 0 {
+		token := l.ahead[0]
+		l.ahead = l.ahead[1:]
+		return token, nil
+	}
+	if l.err != nil {
+		return lexer.Token{}, l.err
+	}
+	return l.lexer.Next()
+}
+
+// peek returns the first token after the blank lines ahead, reading up to it.
+func (l *semicolonLexer) peek() lexer.Token {
+	for i := 0; ; i++ {
+		if i == len(l.ahead) {
+			if l.err != nil {
+				return lexer.Token{}
+			}
+			token, err := l.lexer.Next()
+			if err != nil {
+				l.err = err
+				return lexer.Token{}
 			}
+			l.ahead = append(l.ahead, token)
+		}
+		if token := l.ahead[i]; token.Type != whitespaceToken && token.Type != newlineToken {
+			return token
 		}
-		l.last = token
-		return token, nil
 	}
 }
+
+// elide turns a newline that doesn't terminate a statement into whitespace, so
+// that it is skipped by the parser but still accounted for in node positions.
+func elide(token lexer.Token) lexer.Token {
+	token.Type = whitespaceToken
+	return token
+}
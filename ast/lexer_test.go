@@ -0,0 +1,74 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSemicolonInsertion(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+		want string
+	}{{
+		name: "terminators",
+		src:  "a\n1\n0x1\ntrue\n\"s\"\n`r`\n(a)\n[a]\n{a}\n",
+		want: `a ; 1 ; 0x1 ; true ; " s " ; ` + "` r ` ;" + ` ( a ) ; [ a ] ; { a } ;`,
+	}, {
+		name: "splat",
+		src:  "f(a...)\na...\na.\nb\n",
+		want: "f ( a . . . ) ; a . . . ; a . b ;",
+	}, {
+		name: "heredoc end",
+		src:  "<<EOF\n\tx\nEOF\n<<`EOF`\n\tx\nEOF\n",
+		want: "<<EOF \n\t x \n EOF ; <<`EOF` \n\t x \n EOF ;",
+	}, {
+		name: "continued lines",
+		src:  "a +\nb,\nc(\nd[\ne{\nfun\n",
+		want: "a + b , c ( d [ e { fun",
+	}, {
+		name: "continuations",
+		src:  "{}\nelse {}\n\n\twith a\n(a)\nas b\nc\n.d\n",
+		want: "{ } else { } with a ; ( a ) as b ; c . d ;",
+	}, {
+		name: "comment after a statement",
+		src:  "a # x\nb\n",
+		want: "a #   x \n b ;",
+	}, {
+		name: "comment lines",
+		src:  "a\n# x\n\n# y\nb\n",
+		want: "a ; #   x \n #   y \n b ;",
+	}, {
+		name: "comment after an operator",
+		src:  "a + # x\nb\nc +\n# y\nd\n",
+		want: "a + #   x \n b ; c + #   y \n d ;",
+	}, {
+		name: "comment before continuation",
+		src:  "{}\n# x\nelse\n",
+		want: "{ } ; #   x \n else",
+	}, {
+		name: "blank lines",
+		src:  "\n\na\n\n\nb\n\n",
+		want: "a ; b ;",
+	}, {
+		name: "explicit semicolons",
+		src:  "a;\nb; c\n",
+		want: "a ; b ; c ;",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			tokens, err := moduleParser.Lex("", strings.NewReader(tc.src))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var values []string
+			for _, token := range tokens {
+				if token.Type != whitespaceToken && !token.EOF() {
+					values = append(values, token.Value)
+				}
+			}
+			if got := strings.Join(values, " "); got != tc.want {
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
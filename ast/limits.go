@@ -0,0 +1,174 @@
+package ast
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+
+	participle "github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/codes"
+)
+
+// Untrusted are options limiting the resources used to parse untrusted
+// sources, like those typed in the playground. Parsing time grows faster than
+// the number of tokens, so the limits are well below what a module needs.
+var Untrusted = Options{
+	MaxSize:   1 << 20,
+	MaxTokens: 1 << 13,
+	MaxDepth:  64,
+	MaxErrors: 10,
+}
+
+// cancelInterval is the number of tokens lexed between checks of the context.
+const cancelInterval = 1024
+
+// CheckLimits returns a diagnostic if src, the source of the file filename,
+// exceeds the limits of opts or its context is done, and nil otherwise. It
+// checks sources before they are parsed other than by ParseSource, like with
+// Reparse.
+func CheckLimits(filename string, src []byte, opts Options) *Diagnostic {
+	_, err := lex(filename, src, opts)
+	if lerr, ok := err.(*limitError); ok {
+		return &Diagnostic{Pos: lerr.pos, End: lerr.pos, Message: lerr.msg, Code: codes.LimitExceeded}
+	}
+	return nil
+}
+
+// lex returns the tokens of src for the parser, or a *limitError if src
+// exceeds the limits of opts or its context is done.
+func lex(filename string, src []byte, opts Options) ([]lexer.Token, error) {
+	pos := lexer.Position{Filename: filename, Line: 1, Column: 1}
+	if opts.MaxSize > 0 && len(src) > opts.MaxSize {
+		return nil, &limitError{pos, fmt.Sprintf("source exceeds the maximum size of %d bytes", opts.MaxSize)}
+	}
+	lex, err := (&semicolonLexerDefinition{}).Lex(filename, bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		tokens []lexer.Token
+		count  int
+		depth  int
+	)
+	for {
+		if len(tokens)%cancelInterval == 0 {
+			if err := checkContext(opts.Context, pos); err != nil {
+				return nil, err
+			}
+		}
+		token, err := lex.Next()
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+		if token.EOF() {
+			return tokens, nil
+		}
+		pos = token.Pos
+
+		switch {
+		case token.Type == whitespaceToken:
+			continue
+		case pushTokens[token.Type]:
+			depth++
+		case popTokens[token.Type]:
+			depth--
+		}
+		if opts.MaxDepth > 0 && depth > opts.MaxDepth {
+			return nil, &limitError{pos, fmt.Sprintf("nesting exceeds the maximum depth of %d", opts.MaxDepth)}
+		}
+		count++
+		if opts.MaxTokens > 0 && count > opts.MaxTokens {
+			return nil, &limitError{pos, fmt.Sprintf("source exceeds the maximum of %d tokens", opts.MaxTokens)}
+		}
+	}
+}
+
+// checkContext returns a *limitError at pos if ctx is done.
+func checkContext(ctx context.Context, pos lexer.Position) error {
+	if ctx == nil {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return &limitError{pos, fmt.Sprintf("parsing canceled: %s", err)}
+	}
+	return nil
+}
+
+// parsing maps the first token of each source parsed with a context to the
+// context. Participle lexes all of a source before parsing it, so the context
+// is checked by Expr.Parse instead, finding it by the tokens that the lexers
+// of the branches tried by the parser share.
+var parsing sync.Map
+
+// parseContext parses the tokens of lex into v, failing at the next
+// expression once ctx is done.
+func parseContext(ctx context.Context, parser *participle.Parser, lex *lexer.PeekingLexer, v interface{}) error {
+	if ctx != nil {
+		if key := firstToken(lex); key != nil {
+			parsing.Store(key, ctx)
+			defer parsing.Delete(key)
+		}
+	}
+	err := parser.ParseFromLexer(lex, v)
+	if err != nil {
+		// The parser may report the error of a branch it tried after the
+		// one that was canceled.
+		pos, _ := lex.Peek(0)
+		if perr, ok := err.(interface{ Position() lexer.Position }); ok {
+			pos.Pos = perr.Position()
+		}
+		if cerr := checkContext(ctx, pos.Pos); cerr != nil {
+			return cerr
+		}
+	}
+	return err
+}
+
+// checkParsing returns a *limitError if the context of the source lex is
+// parsing is done.
+func checkParsing(lex *lexer.PeekingLexer) error {
+	key := firstToken(lex)
+	if key == nil {
+		return nil
+	}
+	ctx, ok := parsing.Load(key)
+	if !ok {
+		return nil
+	}
+	token, _ := lex.Peek(0)
+	return checkContext(ctx.(context.Context), token.Pos)
+}
+
+// firstToken returns the first token of lex, or nil if it has none.
+func firstToken(lex *lexer.PeekingLexer) *lexer.Token {
+	if lex.RawCursor() == 0 {
+		if token, _ := lex.RawPeek(0); token.EOF() {
+			return nil
+		}
+	}
+	return &lex.Range(0, 1)[0]
+}
+
+// limitError is the error of a source exceeding a limit of the options it's
+// parsed with. Sources exceeding limits aren't parsed, even when recovering
+// from errors.
+type limitError struct {
+	pos lexer.Position
+	msg string
+}
+
+func (e *limitError) Error() string {
+	return fmt.Sprintf("%s: %s", e.pos, e.msg)
+}
+
+func (e *limitError) Message() string {
+	return e.msg
+}
+
+func (e *limitError) Position() lexer.Position {
+	return e.pos
+}
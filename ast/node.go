@@ -0,0 +1,25 @@
+package ast
+
+import (
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Node is implemented by all the nodes of the AST.
+type Node interface {
+	// Position returns the position of the first token of the node.
+	Position() lexer.Position
+
+	// EndPosition returns the position immediately after the node.
+	EndPosition() lexer.Position
+}
+
+// Mixin is embedded in every node to record its source range. The fields are
+// populated by the parser.
+type Mixin struct {
+	Pos    lexer.Position
+	EndPos lexer.Position
+}
+
+func (m Mixin) Position() lexer.Position { return m.Pos }
+
+func (m Mixin) EndPosition() lexer.Position { return m.EndPos }
@@ -0,0 +1,55 @@
+package ast
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPositions(t *testing.T) {
+	src := "fun build() fs {\n\timage(\"alpine\")\n}\n"
+	mod := parseModule(t, src)
+	fun := mod.Decls[0].Func
+	call := fun.Body.Stmts[0].Expr.Unary.Ref
+
+	for _, tc := range []struct {
+		name string
+		node Node
+		want string
+	}{
+		{"decl", fun, "1:1-3:2"},
+		{"name", fun.Name, "1:5-1:10"},
+		{"params", fun.Params, "1:10-1:12"},
+		{"type", fun.Type, "1:13-1:15"},
+		{"body", fun.Body, "1:16-3:2"},
+		{"call", call, "2:2-2:17"},
+		{"args", call.Next.Call.Args, "2:7-2:17"},
+	} {
+		pos, end := tc.node.Position(), tc.node.EndPosition()
+		if got := fmt.Sprintf("%d:%d-%d:%d", pos.Line, pos.Column, end.Line, end.Column); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+		if pos.Filename != "test.hlb" {
+			t.Errorf("%s: got filename %q", tc.name, pos.Filename)
+		}
+	}
+}
+
+// TestRawHeredocSpaces checks that the spaces of raw heredocs are kept as
+// fragments, like those of heredocs.
+func TestRawHeredocSpaces(t *testing.T) {
+	src := "fun build() fs {\n\trun(<<`EOF`\n\t\tmake  all\n\tEOF)\n}\n"
+	mod := parseModule(t, src)
+	arg := mod.Decls[0].Func.Body.Stmts[0].Expr.Unary.Ref.Next.Call.Args.Exprs[0]
+	var text string
+	for _, f := range arg.Expr.Unary.Ref.Terminal.Lit.String.RawHeredoc.Fragments {
+		switch {
+		case f.Spaces != nil:
+			text += *f.Spaces
+		case f.Text != nil:
+			text += *f.Text
+		}
+	}
+	if want := "\n\t\tmake  all\n\t"; text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+}
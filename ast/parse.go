@@ -0,0 +1,65 @@
+package ast
+
+import (
+	participle "github.com/alecthomas/participle/v2"
+)
+
+// The parsers of the nodes of the grammar parsed on their own. Parsers are
+// immutable once built, so they are built once and are safe for concurrent
+// use.
+var (
+	exprParser    = buildParser(&Expr{})
+	typeParser    = buildParser(&Type{})
+	stmtParser    = buildParser(&Stmt{})
+	literalParser = buildParser(&Literal{})
+)
+
+func buildParser(grammar interface{}) *participle.Parser {
+	return participle.MustBuild(
+		grammar,
+		participle.Lexer(&semicolonLexerDefinition{}),
+		participle.Elide("Whitespace"),
+	)
+}
+
+// ParseExpr parses src as an expression, like `image("alpine")` or `a && b`.
+func ParseExpr(src string) (*Expr, error) {
+	expr := &Expr{}
+	err := exprParser.ParseString("", src, expr)
+	if err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+// ParseType parses src as a type, like `fs` or `[]option::run`.
+func ParseType(src string) (*Type, error) {
+	typ := &Type{}
+	err := typeParser.ParseString("", src, typ)
+	if err != nil {
+		return nil, err
+	}
+	return typ, nil
+}
+
+// ParseStmt parses src as a statement of a block, which may end with its
+// terminating newline or semicolon.
+func ParseStmt(src string) (*Stmt, error) {
+	stmt := &Stmt{}
+	err := stmtParser.ParseString("", src, stmt)
+	if err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// ParseLiteral parses src as a literal, like `"alpine"`, `0o644` or
+// `option::run { dir "/in" }`.
+func ParseLiteral(src string) (*Literal, error) {
+	lit := &Literal{}
+	err := literalParser.ParseString("", src, lit)
+	if err != nil {
+		return nil, err
+	}
+	return lit, nil
+}
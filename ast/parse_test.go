@@ -0,0 +1,95 @@
+package ast
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestParseNodes(t *testing.T) {
+	expr, err := ParseExpr(`a && image("alpine")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expr.Op != OpAnd || expr.Right.Unary.Ref.Terminal.Ident.Text != "image" {
+		t.Errorf("unexpected expression %+v", expr)
+	}
+
+	typ, err := ParseType("[]option::run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ.String() != "[]option::run" {
+		t.Errorf("got type %s, want []option::run", typ)
+	}
+
+	stmt, err := ParseStmt("run(\"make\") with option {\n\tdir \"/src\"\n}\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stmt.Expr == nil || stmt.Expr.Unary.Ref.Next.Call.With == nil {
+		t.Errorf("unexpected statement %+v", stmt)
+	}
+
+	lit, err := ParseLiteral("0o644")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lit.Numeric == nil || lit.Numeric.Value != 0o644 {
+		t.Errorf("unexpected literal %+v", lit)
+	}
+
+	for _, src := range []string{"a b", "image("} {
+		if _, err := ParseExpr(src); err == nil {
+			t.Errorf("expected error parsing %q", src)
+		}
+	}
+}
+
+func TestParseConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if _, err := ParseLiteral(`"node:alpine"`); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkParseExpr(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseExpr(`mount(scratch, "/in/node_modules") as nodeModules`); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseType(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseType("[]option::run"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseStmt(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseStmt(`run("npm install") with option { dir "/in" }`); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseLiteral(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseLiteral(`"node:alpine"`); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package ast
+
+import (
+	"bytes"
+	"context"
+
+	participle "github.com/alecthomas/participle/v2"
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Edit describes a change to the source of a module: the bytes of the
+// previous source from Start to OldEnd were replaced by the bytes of the new
+// source from Start to NewEnd.
+type Edit struct {
+	Start  int
+	OldEnd int
+	NewEnd int
+}
+
+// Diff returns the edit changing oldSrc into newSrc, which replaces the bytes
+// between their common prefix and suffix.
+func Diff(oldSrc, newSrc []byte) Edit {
+	start := 0
+	for start < len(oldSrc) && start < len(newSrc) && oldSrc[start] == newSrc[start] {
+		start++
+	}
+	oldEnd, newEnd := len(oldSrc), len(newSrc)
+	for oldEnd > start && newEnd > start && oldSrc[oldEnd-1] == newSrc[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+	return Edit{Start: start, OldEnd: oldEnd, NewEnd: newEnd}
+}
+
+var (
+	pushTokens = tokenSet("String", "RawString", "Heredoc", "RawHeredoc", "Brace", "Paren", "Bracket", "Comment", "Interpolated")
+	popTokens  = tokenSet("StringEnd", "RawStringEnd", "HeredocEnd", "RawHeredocEnd", "BraceEnd", "ParenEnd", "BracketEnd", "CommentEnd")
+)
+
+func tokenSet(names ...string) map[lexer.TokenType]bool {
+	set := make(map[lexer.TokenType]bool)
+	for _, name := range names {
+		set[Lexer.Symbols()[name]] = true
+	}
+	return set
+}
+
+// Reparse returns the module of src, the source of mod after edit, reusing
+// the declarations of mod the edit leaves untouched. It reparses from the
+// start of the declaration before the edit up to the first declaration after
+// it where the lexer is back outside of any string, heredoc, comment or
+// bracket, and falls back to parsing all of src when no such declarations
+// exist. The declarations after the edit are moved in place, so mod must not
+// be used afterwards.
+func Reparse(mod *Module, src []byte, edit Edit) (*Module, error) {
+	if newMod, ok := reparse(mod, src, edit); ok {
+		return newMod, nil
+	}
+	newMod := &Module{}
+	err := moduleParser.ParseBytes(mod.Pos.Filename, src, newMod)
+	return newMod, err
+}
+
+func reparse(mod *Module, src []byte, edit Edit) (*Module, bool) {
+	decls := mod.Decls
+	k := 0
+	for k < len(decls) && decls[k].EndPos.Offset < edit.Start {
+		k++
+	}
+	// Consecutive comments are parsed as one declaration, so the reparsed
+	// declarations can't start or end next to comments.
+	if k > 0 {
+		k--
+	}
+	for k > 0 && decls[k-1].Comments != nil {
+		k--
+	}
+	if k == len(decls) || (k == 0 && mod.Comments != nil) || decls[k].Pos.Offset > edit.Start {
+		return nil, false
+	}
+	base := decls[k].Pos
+
+	// Candidates to resume the previous declarations start after the edit
+	// on a line of their own.
+	delta := edit.NewEnd - edit.OldEnd
+	var next []*Decl
+	for _, decl := range decls[k+1:] {
+		offset := decl.Pos.Offset + delta
+		if decl.Pos.Offset > edit.OldEnd && decl.Comments == nil && offset <= len(src) && src[offset-1] == '\n' {
+			next = append(next, decl)
+		}
+	}
+
+	// Lex up to the first candidate outside of any nested lexer state.
+	lex, err := Scanner.Lex(base.Filename, bytes.NewReader(src[base.Offset:]))
+	if err != nil {
+		return nil, false
+	}
+	var (
+		resume *Decl
+		lines  int
+		depth  int
+	)
+	for resume == nil && len(next) > 0 {
+		token, err := lex.Next()
+		if err != nil {
+			return nil, false
+		}
+		if token.EOF() {
+			break
+		}
+		offset := base.Offset + token.Pos.Offset
+		for len(next) > 0 && next[0].Pos.Offset+delta < offset {
+			next = next[1:]
+		}
+		if depth == 0 && len(next) > 0 && next[0].Pos.Offset+delta == offset {
+			resume = next[0]
+			lines = base.Line + token.Pos.Line - 1 - resume.Pos.Line
+		}
+		switch {
+		case pushTokens[token.Type]:
+			depth++
+		case popTokens[token.Type]:
+			depth--
+		}
+	}
+
+	end := len(src)
+	if resume != nil {
+		end = resume.Pos.Offset + delta
+	}
+	region, err := parseRegion(nil, moduleParser, src[:end], base)
+	if err != nil {
+		return nil, false
+	}
+
+	newMod := &Module{Mixin: mod.Mixin, Comments: mod.Comments}
+	newMod.Decls = append(newMod.Decls, decls[:k]...)
+	if region.Comments != nil {
+		if k == 0 {
+			newMod.Comments = region.Comments
+		} else {
+			newMod.Decls = append(newMod.Decls, &Decl{Mixin: region.Comments.Mixin, Comments: region.Comments})
+		}
+	}
+	newMod.Decls = append(newMod.Decls, region.Decls...)
+	if k == 0 {
+		newMod.Pos = region.Pos
+	}
+	if resume == nil {
+		newMod.EndPos = region.EndPos
+		return newMod, true
+	}
+
+	var rest []*Decl
+	for _, decl := range decls[k+1:] {
+		if decl.Pos.Offset >= resume.Pos.Offset {
+			rest = append(rest, decl)
+		}
+	}
+	for _, decl := range rest {
+		Inspect(decl, func(node Node) bool {
+			if m, ok := node.(interface{ shift(offset, lines int) }); ok {
+				m.shift(delta, lines)
+			}
+			return true
+		})
+	}
+	newMod.Decls = append(newMod.Decls, rest...)
+	newMod.EndPos.Offset += delta
+	newMod.EndPos.Line += lines
+	return newMod, true
+}
+
+// shift moves the node by offset bytes and lines lines, which keeps its
+// columns.
+func (m *Mixin) shift(offset, lines int) {
+	m.Pos.Offset += offset
+	m.Pos.Line += lines
+	m.EndPos.Offset += offset
+	m.EndPos.Line += lines
+}
+
+// parseRegion parses the declarations of src from base up to its end, which
+// must be outside of any nested lexer state, with parser until ctx is done.
+func parseRegion(ctx context.Context, parser *participle.Parser, src []byte, base lexer.Position) (*Module, error) {
+	ll, err := Scanner.Lex(base.Filename, bytes.NewReader(src[base.Offset:]))
+	if err != nil {
+		return nil, err
+	}
+	peeker, err := lexer.Upgrade(&semicolonLexer{lexer: &shiftedLexer{lexer: ll, base: base}}, whitespaceToken)
+	if err != nil {
+		return nil, err
+	}
+	region := &Module{}
+	err = parseContext(ctx, parser, peeker, region)
+	return region, err
+}
+
+// shiftedLexer lexes a part of a source starting at base.
+type shiftedLexer struct {
+	lexer lexer.Lexer
+	base  lexer.Position
+}
+
+func (l *shiftedLexer) Next() (lexer.Token, error) {
+	token, err := l.lexer.Next()
+	if serr, ok := err.(*scanError); ok {
+		serr.Pos = l.shift(serr.Pos)
+	}
+	token.Pos = l.shift(token.Pos)
+	return token, err
+}
+
+func (l *shiftedLexer) shift(pos lexer.Position) lexer.Position {
+	if pos.Line == 1 {
+		pos.Column += l.base.Column - 1
+	}
+	pos.Line += l.base.Line - 1
+	pos.Offset += l.base.Offset
+	return pos
+}
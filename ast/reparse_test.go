@@ -0,0 +1,79 @@
+package ast
+
+import (
+	"math/rand"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var snippets = []string{
+	"\n", " ", "\"", "`", "#", "{", "}", "(", ")", "${", "<<EOF\n", "\nEOF", "x", ";",
+	"\nfun added() fs {\n\tscratch\n}\n",
+	"# comment\n",
+	"\"quoted\"",
+	"image(\"alpine\")\n",
+}
+
+func TestReparse(t *testing.T) {
+	data, err := os.ReadFile("../build.hlb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		src := append([]byte{}, data...)
+		mod := &Module{}
+		if err := moduleParser.ParseBytes("build.hlb", src, mod); err != nil {
+			t.Fatal(err)
+		}
+		// Each round makes a run of edits, reparsing after each one while
+		// the source parses.
+		for j := 0; j < 10; j++ {
+			start := rng.Intn(len(src) + 1)
+			end := start
+			if rng.Intn(2) == 0 {
+				end += rng.Intn(40)
+				if end > len(src) {
+					end = len(src)
+				}
+			}
+			text := ""
+			if rng.Intn(3) > 0 {
+				text = snippets[rng.Intn(len(snippets))]
+			}
+			newSrc := append(append(append([]byte{}, src[:start]...), text...), src[end:]...)
+			edit := Edit{Start: start, OldEnd: end, NewEnd: start + len(text)}
+
+			want := &Module{}
+			wantErr := moduleParser.ParseBytes("build.hlb", newSrc, want)
+			got, err := Reparse(mod, newSrc, edit)
+			if (err == nil) != (wantErr == nil) || (err != nil && err.Error() != wantErr.Error()) {
+				t.Fatalf("got error %v, want %v, after replacing %d:%d with %q", err, wantErr, start, end, text)
+			}
+			if wantErr != nil {
+				break
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("reparse differs from parse after replacing %d:%d with %q in:\n%s", start, end, text, src)
+			}
+			src, mod = newSrc, got
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	for _, tc := range []struct {
+		old, new string
+		want     Edit
+	}{
+		{"abc", "abc", Edit{3, 3, 3}},
+		{"abc", "axc", Edit{1, 2, 2}},
+		{"abc", "abbc", Edit{2, 2, 3}},
+		{"abc", "ac", Edit{1, 2, 1}},
+	} {
+		if got := Diff([]byte(tc.old), []byte(tc.new)); got != tc.want {
+			t.Errorf("Diff(%q, %q) = %+v, want %+v", tc.old, tc.new, got, tc.want)
+		}
+	}
+}
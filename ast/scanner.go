@@ -0,0 +1,342 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// Scanner is a hand-written lexer producing the same tokens and errors as
+// Lexer without running regular expressions, so it's the one the parser
+// uses. Lexer remains the definition of the tokens.
+var Scanner lexer.Definition = &scannerDefinition{}
+
+var (
+	symbols = Lexer.Symbols()
+
+	modifierToken      = symbols["Modifier"]
+	keywordToken       = symbols["Keyword"]
+	numericToken       = symbols["Numeric"]
+	decimalToken       = symbols["Decimal"]
+	boolToken          = symbols["Bool"]
+	stringToken        = symbols["String"]
+	stringEndToken     = symbols["StringEnd"]
+	rawStringToken     = symbols["RawString"]
+	rawStringEndToken  = symbols["RawStringEnd"]
+	heredocToken       = symbols["Heredoc"]
+	heredocEndToken    = symbols["HeredocEnd"]
+	rawHeredocToken    = symbols["RawHeredoc"]
+	rawHeredocEndToken = symbols["RawHeredocEnd"]
+	braceEndToken      = symbols["BraceEnd"]
+	parenEndToken      = symbols["ParenEnd"]
+	bracketToken       = symbols["Bracket"]
+	bracketEndToken    = symbols["BracketEnd"]
+	identToken         = symbols["Ident"]
+	punctToken         = symbols["Punct"]
+	commentStartToken  = symbols["Comment"]
+	commentTextToken   = symbols["CommentText"]
+	escapedToken       = symbols["Escaped"]
+	interpolatedToken  = symbols["Interpolated"]
+	charToken          = symbols["Char"]
+	rawCharToken       = symbols["RawChar"]
+	spacesToken        = symbols["Spaces"]
+	textToken          = symbols["Text"]
+	rawTextToken       = symbols["RawText"]
+)
+
+// scanState is a state of the Lexer rules, and the name of the heredoc it
+// ends with.
+type scanState struct {
+	rules     string
+	delimiter string
+}
+
+type scannerDefinition struct{}
+
+func (d *scannerDefinition) Symbols() map[string]lexer.TokenType {
+	return Lexer.Symbols()
+}
+
+func (d *scannerDefinition) Lex(filename string, r io.Reader) (lexer.Lexer, error) {
+	var sb strings.Builder
+	if _, err := io.Copy(&sb, r); err != nil {
+		return nil, err
+	}
+	return d.LexString(filename, sb.String())
+}
+
+func (d *scannerDefinition) LexString(filename string, s string) (lexer.Lexer, error) {
+	return &scanner{
+		data:  s,
+		stack: []scanState{{rules: "Root"}},
+		pos:   lexer.Position{Filename: filename, Line: 1, Column: 1},
+	}, nil
+}
+
+func (d *scannerDefinition) LexBytes(filename string, b []byte) (lexer.Lexer, error) {
+	return d.LexString(filename, string(b))
+}
+
+type scanner struct {
+	data  string
+	stack []scanState
+	pos   lexer.Position
+}
+
+// scanError is a lexing error, formatted like those of Lexer.
+type scanError struct {
+	Msg string
+	Pos lexer.Position
+}
+
+func (e *scanError) Message() string { return e.Msg }
+
+func (e *scanError) Position() lexer.Position { return e.Pos }
+
+func (e *scanError) Error() string {
+	var prefix string
+	if e.Pos.Filename != "" {
+		prefix = e.Pos.Filename + ":"
+	}
+	if e.Pos.Line != 0 || e.Pos.Column != 0 {
+		prefix += fmt.Sprintf("%d:%d:", e.Pos.Line, e.Pos.Column)
+	}
+	if prefix == "" {
+		return e.Msg
+	}
+	return prefix + " " + e.Msg
+}
+
+func (s *scanner) Next() (lexer.Token, error) {
+	if len(s.data) == 0 {
+		return lexer.EOFToken(s.pos), nil
+	}
+	typ, n := s.scan()
+	if n == 0 {
+		sample := []rune(s.data)
+		if len(sample) > 16 {
+			sample = append(sample[:16], []rune("...")...)
+		}
+		return lexer.Token{}, &scanError{Msg: fmt.Sprintf("invalid input text %q", string(sample)), Pos: s.pos}
+	}
+	token := lexer.Token{Type: typ, Value: s.data[:n], Pos: s.pos}
+	s.pos.Advance(token.Value)
+	s.data = s.data[n:]
+	return token, nil
+}
+
+func (s *scanner) push(rules, delimiter string) {
+	s.stack = append(s.stack, scanState{rules: rules, delimiter: delimiter})
+}
+
+func (s *scanner) pop() {
+	s.stack = s.stack[:len(s.stack)-1]
+}
+
+// scan returns the type and length of the next token, which is empty if no
+// rule of the state matches.
+func (s *scanner) scan() (lexer.TokenType, int) {
+	data := s.data
+	state := s.stack[len(s.stack)-1]
+	switch state.rules {
+	case "String":
+		switch {
+		case data[0] == '"':
+			s.pop()
+			return stringEndToken, 1
+		case data[0] == '\\':
+			if n := escaped(data); n > 0 {
+				return escapedToken, n
+			}
+			return 0, 0
+		case strings.HasPrefix(data, "${"):
+			s.push("Interpolated", "")
+			return interpolatedToken, 2
+		case data[0] == '$':
+			return charToken, 1
+		}
+		return charToken, span(data, func(c byte) bool { return c != '"' && c != '$' && c != '\\' })
+	case "RawString":
+		if data[0] == '`' {
+			s.pop()
+			return rawStringEndToken, 1
+		}
+		return rawCharToken, span(data, func(c byte) bool { return c != '`' })
+	case "Heredoc":
+		switch {
+		case isDelimiter(data, state.delimiter):
+			s.pop()
+			return heredocEndToken, len(state.delimiter)
+		case isSpace(data[0]):
+			return spacesToken, span(data, isSpace)
+		case data[0] == '\\' && escaped(data) > 0:
+			return escapedToken, escaped(data)
+		case strings.HasPrefix(data, "${"):
+			s.push("Interpolated", "")
+			return interpolatedToken, 2
+		case data[0] == '$':
+			return textToken, 1
+		}
+		return textToken, span(data, func(c byte) bool { return !isSpace(c) && c != '$' })
+	case "RawHeredoc":
+		switch {
+		case isDelimiter(data, state.delimiter):
+			s.pop()
+			return rawHeredocEndToken, len(state.delimiter)
+		case isSpace(data[0]):
+			return spacesToken, span(data, isSpace)
+		}
+		return rawTextToken, span(data, func(c byte) bool { return !isSpace(c) })
+	case "Comment":
+		if data[0] == '\n' {
+			s.pop()
+			return commentEndToken, 1
+		}
+		_, n := utf8.DecodeRuneInString(data)
+		return commentTextToken, n
+	case "Interpolated", "Brace":
+		if data[0] == '}' {
+			s.pop()
+			return braceEndToken, 1
+		}
+	case "Paren":
+		if data[0] == ')' {
+			s.pop()
+			return parenEndToken, 1
+		}
+	case "Bracket":
+		if data[0] == ']' {
+			s.pop()
+			return bracketEndToken, 1
+		}
+	}
+	return s.scanRoot()
+}
+
+// scanRoot scans a token with the rules of the Root state.
+func (s *scanner) scanRoot() (lexer.TokenType, int) {
+	data := s.data
+	c := data[0]
+	switch {
+	case c == ' ' || c == '\t' || c == '\r':
+		return whitespaceToken, span(data, func(c byte) bool { return c == ' ' || c == '\t' || c == '\r' })
+	case isLetter(c):
+		n := span(data, isWord)
+		switch data[:n] {
+		case "pub":
+			return modifierToken, n
+		case "if", "else", "for", "in", "with", "as", "import", "fun":
+			return keywordToken, n
+		case "true", "false":
+			return boolToken, n
+		}
+		return identToken, n
+	case isDigit(c):
+		n := span(data, isWord)
+		switch word := data[:n]; {
+		case len(word) > 2 && word[0] == '0' && strings.IndexByte("bBoOxX", word[1]) >= 0 && span(word[2:], isHex) == n-2:
+			return numericToken, n
+		case word == "0", c != '0' && span(word, isDigit) == n:
+			return decimalToken, n
+		}
+		return 0, 0
+	case c == '"':
+		s.push("String", "")
+		return stringToken, 1
+	case c == '`':
+		s.push("RawString", "")
+		return rawStringToken, 1
+	case strings.HasPrefix(data, "<<"):
+		i := 2
+		if i < len(data) && (data[i] == '-' || data[i] == '~') {
+			i++
+		}
+		if n := span(data[i:], isWord); n > 0 {
+			s.push("Heredoc", data[i:i+n])
+			return heredocToken, i + n
+		}
+		if i < len(data) && data[i] == '`' {
+			n := span(data[i+1:], isWord)
+			if n > 0 && i+1+n < len(data) && data[i+1+n] == '`' {
+				s.push("RawHeredoc", data[i+1:i+1+n])
+				return rawHeredocToken, i + n + 2
+			}
+		}
+	case c == '{':
+		s.push("Brace", "")
+		return braceToken, 1
+	case c == '(':
+		s.push("Paren", "")
+		return parenToken, 1
+	case c == '[':
+		s.push("Bracket", "")
+		return bracketToken, 1
+	case c == '\n':
+		return newlineToken, 1
+	case c == '#':
+		s.push("Comment", "")
+		return commentStartToken, 1
+	}
+
+	if len(data) > 1 {
+		switch data[:2] {
+		case ">=", "<=", "&&", "||", "==", "!=":
+			return operatorToken, 2
+		}
+	}
+	switch {
+	case strings.IndexByte("-+=*/%<>^!|&", c) >= 0:
+		return operatorToken, 1
+	case strings.IndexByte("@:;?.,", c) >= 0:
+		return punctToken, 1
+	}
+	return 0, 0
+}
+
+// escaped returns the length of the escape sequence at the start of data, a
+// backslash followed by any character but a newline, or 0 if there is none.
+func escaped(data string) int {
+	if len(data) < 2 || data[1] == '\n' {
+		return 0
+	}
+	_, n := utf8.DecodeRuneInString(data[1:])
+	return 1 + n
+}
+
+// isDelimiter reports whether data starts with the word delimiter.
+func isDelimiter(data, delimiter string) bool {
+	return strings.HasPrefix(data, delimiter) && (len(data) == len(delimiter) || !isWord(data[len(delimiter)]))
+}
+
+// span returns the length of the run of bytes at the start of data matching
+// f.
+func span(data string, f func(byte) bool) int {
+	n := 0
+	for n < len(data) && f(data[n]) {
+		n++
+	}
+	return n
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '_'
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+func isHex(c byte) bool {
+	return isDigit(c) || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func isWord(c byte) bool {
+	return isLetter(c) || isDigit(c)
+}
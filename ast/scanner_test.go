@@ -0,0 +1,152 @@
+package ast
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+var scannerCases = []string{
+	"fun a() fs {\n\timage(\"alpine\")\n}\n",
+	"pub puba if iff import in inx 0 0x1F 0xg 05 123 12a true falsey _x é",
+	"\"a\\\"b${x + \"y\"}$ $\\\n\"",
+	"`raw ${x}`",
+	"<<EOF\n\tfoo$ ${bar} \\$ \\\nxEOF EOFx $EOF\nEOF",
+	"<<-`EOF`\n  ${x}\nEOF)",
+	"<<~EOF\nEOF <<- <<`x <<`` <<`x` a<<b << <= <",
+	"a >= b <= c && d || e == f != g - + = * / % < > ^ ! | & @ : ; ? . ,",
+	"# comment é\n#\n# unterminated",
+	"{ } ( ) [ ] } ) ]",
+	"(}", "{)", "\x00", "\"\xff\" `\xff` #\xff\n\\\xff",
+	"\r\n\t \f",
+}
+
+func lexAll(def lexer.Definition, src string) ([]lexer.Token, string) {
+	lex, err := def.Lex("test.hlb", strings.NewReader(src))
+	if err != nil {
+		return nil, err.Error()
+	}
+	tokens, err := lexer.ConsumeAll(lex)
+	if err != nil {
+		return tokens, err.Error()
+	}
+	return tokens, ""
+}
+
+func checkScanner(t *testing.T, src string) {
+	t.Helper()
+	want, wantErr := lexAll(Lexer, src)
+	got, gotErr := lexAll(Scanner, src)
+	if gotErr != wantErr {
+		t.Fatalf("got error %q, want %q, lexing %q", gotErr, wantErr, src)
+	}
+	if wantErr == "" && !reflect.DeepEqual(got, want) {
+		for i := range want {
+			if i >= len(got) || got[i] != want[i] {
+				t.Fatalf("token %d: got %+v, want %+v, lexing %q", i, got[i:], want[i], src)
+			}
+		}
+		t.Fatalf("got extra tokens %+v, lexing %q", got[len(want):], src)
+	}
+}
+
+func TestScanner(t *testing.T) {
+	for _, src := range scannerCases {
+		checkScanner(t, src)
+	}
+	data, err := os.ReadFile("../build.hlb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkScanner(t, string(data))
+
+	// Random sources made of the pieces of tokens.
+	var pieces []string
+	for _, src := range scannerCases {
+		tokens, _ := lexAll(Lexer, src)
+		for _, token := range tokens {
+			pieces = append(pieces, token.Value)
+		}
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		var sb strings.Builder
+		for j := rng.Intn(20); j >= 0; j-- {
+			sb.WriteString(pieces[rng.Intn(len(pieces))])
+		}
+		checkScanner(t, sb.String())
+	}
+}
+
+func FuzzScanner(f *testing.F) {
+	for _, src := range scannerCases {
+		f.Add(src)
+	}
+	for _, path := range []string{"../bar.hlb", "../build.hlb", "../foo.hlb"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(data))
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		checkScanner(t, src)
+	})
+}
+
+// generateModule returns a module of n functions.
+func generateModule(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, `# build%[1]d builds the binary %[1]d.
+fun build%[1]d(string pkg, int count) fs {
+	image("golang:1.%[1]d-alpine")
+	run("go build -o /out/bin%[1]d ${pkg}") with option {
+		dir "/src"
+		mount fs {
+			local "."
+		}, "/src", readonly
+	}
+	run(<<EOF
+		go test ./... -count=${count}
+	EOF)
+}
+
+`, i)
+	}
+	return sb.String()
+}
+
+func benchmarkLexer(b *testing.B, def lexer.Definition) {
+	src := generateModule(1000)
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		lex, err := def.Lex("bench.hlb", strings.NewReader(src))
+		if err != nil {
+			b.Fatal(err)
+		}
+		for {
+			token, err := lex.Next()
+			if err != nil {
+				b.Fatal(err)
+			}
+			if token.EOF() {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkLexer(b *testing.B) {
+	benchmarkLexer(b, Lexer)
+}
+
+func BenchmarkScanner(b *testing.B) {
+	benchmarkLexer(b, Scanner)
+}
@@ -0,0 +1,104 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Unquoted returns the value of the string literal with quotes and escapes
+// removed and heredocs dedented. The second result is false if the literal
+// contains interpolations, which have no static value.
+func (l *StringLit) Unquoted() (string, bool) {
+	switch {
+	case l.String != nil:
+		var sb strings.Builder
+		for _, f := range l.String.Fragments {
+			switch {
+			case f.Escaped != nil:
+				sb.WriteString(unescape(*f.Escaped))
+			case f.Interpolated != nil:
+				return "", false
+			case f.Text != nil:
+				sb.WriteString(*f.Text)
+			}
+		}
+		return sb.String(), true
+	case l.RawString != nil:
+		return l.RawString.Text, true
+	case l.Heredoc != nil:
+		return heredocValue(l.Heredoc.Start, l.Heredoc.Fragments)
+	case l.RawHeredoc != nil:
+		return heredocValue(l.RawHeredoc.Start, l.RawHeredoc.Fragments)
+	}
+	return "", false
+}
+
+func unescape(escaped string) string {
+	s, err := strconv.Unquote(`"` + escaped + `"`)
+	if err != nil {
+		return escaped[1:]
+	}
+	return s
+}
+
+func heredocValue(start string, fragments []*HeredocFragment) (string, bool) {
+	var sb strings.Builder
+	for _, f := range fragments {
+		switch {
+		case f.Spaces != nil:
+			sb.WriteString(*f.Spaces)
+		case f.Escaped != nil:
+			sb.WriteString(unescape(*f.Escaped))
+		case f.Interpolated != nil:
+			return "", false
+		case f.Text != nil:
+			sb.WriteString(*f.Text)
+		}
+	}
+
+	// The body starts on the line after the opening marker and ends before the
+	// indentation of the terminator.
+	value := sb.String()
+	if i := strings.IndexByte(value, '\n'); i >= 0 && strings.TrimSpace(value[:i]) == "" {
+		value = value[i+1:]
+	}
+	if i := strings.LastIndexByte(value, '\n'); i >= 0 && strings.TrimSpace(value[i:]) == "" {
+		value = value[:i+1]
+	}
+
+	switch strings.TrimLeft(start, "<")[0] {
+	case '-':
+		lines := strings.Split(value, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimLeft(line, "\t")
+		}
+		value = strings.Join(lines, "\n")
+	case '~':
+		value = dedent(value)
+	}
+	return value, true
+}
+
+// dedent removes the longest common whitespace prefix from non-blank lines.
+func dedent(s string) string {
+	lines := strings.Split(s, "\n")
+	prefix := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			prefix, first = indent, false
+			continue
+		}
+		for !strings.HasPrefix(indent, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	for i, line := range lines {
+		lines[i] = strings.TrimPrefix(line, prefix)
+	}
+	return strings.Join(lines, "\n")
+}
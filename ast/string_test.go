@@ -0,0 +1,37 @@
+package ast
+
+import "testing"
+
+func TestUnquoted(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		lit    string
+		want   string
+		static bool
+	}{
+		{"string", `"a\tb\"c"`, "a\tb\"c", true},
+		{"interpolated", `"a${b}"`, "", false},
+		{"raw", "`a\\tb`", `a\tb`, true},
+		{"heredoc", "<<EOF\n\tmake\n\tEOF", "\tmake\n", true},
+		{"heredoc tabs", "<<-EOF\n\t\tmake\n\t\t  all\n\tEOF", "make\n  all\n", true},
+		{"heredoc dedent", "<<~EOF\n\t\tmake\n\t\t  all\n\tEOF", "make\n  all\n", true},
+		{"raw heredoc", "<<~`EOF`\n\t\t${make}\n\tEOF", "${make}\n", true},
+		{"heredoc interpolated", "<<EOF\n\t${make}\n\tEOF", "", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mod := parseModule(t, "fun a() string {\n\t"+tc.lit+"\n}\n")
+			lit := mod.Decls[0].Func.Body.Stmts[0].Expr.Unary.Ref.Terminal.Lit.String
+			got, static := lit.Unquoted()
+			if got != tc.want || static != tc.static {
+				t.Errorf("got %q, %t, want %q, %t", got, static, tc.want, tc.static)
+			}
+		})
+	}
+}
+
+func TestTypeString(t *testing.T) {
+	mod := parseModule(t, "fun a([]option::run opts) fs {}\n")
+	if got, want := mod.Decls[0].Func.Params.Fields[0].Field.Type.String(), "[]option::run"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
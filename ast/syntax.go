@@ -0,0 +1,350 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/codes"
+)
+
+var (
+	unexpectedRegexp = regexp.MustCompile(`^unexpected token "(.*)"(?: \(expected (.*)\))?$`)
+	invalidRegexp    = regexp.MustCompile(`^invalid input text "(.)`)
+
+	// expectedNames are the HLB terms for the grammar nodes and tokens the
+	// parser expects.
+	expectedNames = map[string]string{
+		"CloseParen":   `")"`,
+		"CloseBrace":   `"}"`,
+		"CloseBracket": `"]"`,
+		"Quote":        `closing '"'`,
+		"Ident":        "name",
+		"Type":         "type",
+		"FieldList":    "parameter list",
+		"StmtList":     "block",
+		"Expr":         "expression",
+		"From":         `"from"`,
+		"Condition":    `"("`,
+	}
+
+	// typeNames are the scalar types, telling apart parameters missing
+	// their type from those missing their name.
+	typeNames = map[string]bool{
+		"bool":   true,
+		"fs":     true,
+		"int":    true,
+		"option": true,
+		"set":    true,
+		"string": true,
+	}
+
+	// keywordMistakes are keywords of other languages written at the start
+	// of declarations, and the hint to use the HLB one instead.
+	keywordMistakes = map[string]string{
+		"func":     `functions are declared with "fun", not "func"`,
+		"function": `functions are declared with "fun", not "function"`,
+		"export":   `functions are exported with "pub", not "export"`,
+		"public":   `functions are exported with "pub", not "public"`,
+	}
+)
+
+// SyntaxDiagnostic returns a diagnostic for err, an error lexing or parsing
+// src. Its message names the construct being parsed and what was expected in
+// it, and it hints at a fix for common mistakes. Errors that aren't syntax
+// errors are returned as is.
+func SyntaxDiagnostic(src []byte, err error) *Diagnostic {
+	d := &Diagnostic{Message: err.Error()}
+	perr, ok := err.(interface {
+		Message() string
+		Position() lexer.Position
+	})
+	if !ok {
+		return d
+	}
+	d.Pos, d.End, d.Message = perr.Position(), perr.Position(), perr.Message()
+	d.Code = codes.UnexpectedToken
+	d.Source = sourceLine(src, d.Pos)
+	if _, ok := err.(*limitError); ok {
+		d.Code = codes.LimitExceeded
+		return d
+	}
+
+	var unexpected, expected string
+	if m := unexpectedRegexp.FindStringSubmatch(d.Message); m != nil {
+		unexpected, expected = m[1], m[2]
+		if text, err := strconv.Unquote(`"` + m[1] + `"`); err == nil {
+			unexpected = text
+		}
+	} else if m := invalidRegexp.FindStringSubmatch(d.Message); m != nil && strings.Contains(")]}", m[1]) {
+		// A closing bracket not matching the innermost opening one.
+		unexpected = m[1]
+	} else {
+		return d
+	}
+	if fields := strings.Fields(expected); len(fields) > 0 {
+		expected = expectedNames[fields[0]]
+	}
+
+	atNewline := d.Pos.Offset < len(src) && src[d.Pos.Offset] == '\n'
+	s := syntaxContextAt(src, d.Pos)
+	inner := s.inner()
+	if inner != nil && inner.close != "" && (expected == "" || unexpected == "<EOF>") {
+		expected = fmt.Sprintf("%q", inner.close)
+	}
+
+	switch {
+	case unexpected == "<EOF>" && inner != nil && inner.unterminated != "":
+		// Point at the start of the string, which is where it's missing
+		// its end.
+		d.Pos, d.End = inner.open.Pos, inner.open.Pos
+		d.Source = sourceLine(src, d.Pos)
+		d.Message = fmt.Sprintf("unterminated %s", inner.context)
+		d.Code = codes.UnterminatedString
+		d.Hint = inner.unterminated
+		return d
+	case keywordMistakes[unexpected] != "" && inner == nil:
+		expected = "declaration"
+		d.Code = codes.ForeignKeyword
+		d.Hint = keywordMistakes[unexpected]
+	case (unexpected == "{" || unexpected == "}") && expected == `")"`:
+		d.Code = codes.UnclosedBracket
+		d.Hint = fmt.Sprintf(`missing ")" before %q`, unexpected)
+	case unexpected == ";" && atNewline && inner != nil && inner.close != "" && expected == fmt.Sprintf("%q", inner.close):
+		d.Code = codes.MissingComma
+		d.Hint = `end the line with "," to continue the list on the next one`
+	case unexpected == "<EOF>" && inner != nil && inner.close != "":
+		d.Code = codes.UnclosedBracket
+	case inner != nil && inner.params && typeNames[unexpected] && s.nextIs(`,`, `)`):
+		expected = "parameter name after type"
+		d.Code = codes.IncompleteParameter
+	case inner != nil && inner.params && s.nextIs(`,`, `)`):
+		expected = "type before parameter name"
+		d.Code = codes.IncompleteParameter
+	case (unexpected == "if" || unexpected == "for") && !s.nextIs("("):
+		d.Code = codes.UnparenthesizedHead
+		d.Hint = fmt.Sprintf(`the header of %s is written in parentheses, like "%s (...) {"`, unexpected, unexpected)
+	case s.prev.Type == operatorToken:
+		expected = fmt.Sprintf("expression after %q", s.prev.Value)
+		d.Code = codes.MissingOperand
+	case inner == nil && s.inFun && expected == "type":
+		expected = "return type"
+		d.Code = codes.MissingReturnType
+	case inner == nil && s.inFun && s.fun == "" && expected == "name":
+		expected = "function name"
+	case inner == nil && !s.inFun && !s.inImport && expected == "":
+		expected = "declaration"
+	}
+
+	var sb strings.Builder
+	switch {
+	case unexpected == "<EOF>":
+		sb.WriteString("unexpected end of file")
+	case unexpected == ";" && atNewline:
+		sb.WriteString("unexpected end of line")
+	default:
+		fmt.Fprintf(&sb, "unexpected %q", unexpected)
+	}
+	switch {
+	case inner != nil:
+		fmt.Fprintf(&sb, " in %s", inner.context)
+	case s.inFun:
+		fmt.Fprintf(&sb, " in declaration of %s", s.funName())
+	case s.inImport:
+		sb.WriteString(" in import declaration")
+	}
+	if expected != "" {
+		fmt.Fprintf(&sb, ", expected %s", expected)
+	}
+	d.Message = sb.String()
+	return d
+}
+
+// sourceLine returns the line of src pos is on, without its newline.
+func sourceLine(src []byte, pos lexer.Position) string {
+	if pos.Offset > len(src) {
+		return ""
+	}
+	start := bytes.LastIndexByte(src[:pos.Offset], '\n') + 1
+	end := bytes.IndexByte(src[pos.Offset:], '\n')
+	if end < 0 {
+		return string(src[start:])
+	}
+	return string(src[start : pos.Offset+end])
+}
+
+// syntaxContext is where a position is in the source: the brackets and
+// strings it's nested in, and the function declaration it's in.
+type syntaxContext struct {
+	stack []*syntaxFrame
+
+	// inFun is true in a function declaration, and fun is its name once
+	// parsed. inImport is true in an import declaration.
+	inFun    bool
+	fun      string
+	inImport bool
+
+	// prev is the last token before the position, and next the first token
+	// after it.
+	prev, next lexer.Token
+}
+
+type syntaxFrame struct {
+	open    lexer.Token
+	close   string
+	context string
+
+	// params is true for parameter lists, and unterminated is the hint for
+	// strings and heredocs that aren't terminated.
+	params       bool
+	unterminated string
+}
+
+func (s *syntaxContext) inner() *syntaxFrame {
+	if len(s.stack) == 0 {
+		return nil
+	}
+	return s.stack[len(s.stack)-1]
+}
+
+func (s *syntaxContext) funName() string {
+	if s.fun == "" {
+		return "fun"
+	}
+	return "fun " + s.fun
+}
+
+func (s *syntaxContext) nextIs(values ...string) bool {
+	for _, value := range values {
+		if s.next.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// syntaxContextAt returns the context of pos in src, from the tokens before
+// it.
+func syntaxContextAt(src []byte, pos lexer.Position) *syntaxContext {
+	s := &syntaxContext{}
+	lex, err := Scanner.Lex(pos.Filename, bytes.NewReader(src))
+	if err != nil {
+		return s
+	}
+
+	var (
+		closed      lexer.Token
+		closedFrame *syntaxFrame
+		funState    int // 1 after fun, 2 after its parameters.
+	)
+	symbols := Lexer.Symbols()
+	for {
+		token, err := lex.Next()
+		if err != nil || token.EOF() {
+			return s
+		}
+		if token.Type == newlineToken && len(s.stack) == 0 {
+			s.inImport = false
+		}
+		if token.Type == whitespaceToken || token.Type == newlineToken {
+			continue
+		}
+		if token.Pos.Offset >= pos.Offset {
+			if token.Pos.Offset > pos.Offset {
+				s.next = token
+				return s
+			}
+			continue
+		}
+
+		switch {
+		case token.Value == "fun" && len(s.stack) == 0:
+			s.inFun, s.fun, funState = true, "", 1
+		case token.Value == "import" && len(s.stack) == 0:
+			s.inImport = true
+		case token.Type == symbols["Ident"] && funState == 1 && s.fun == "":
+			s.fun = token.Value
+		case pushTokens[token.Type]:
+			frame := &syntaxFrame{open: token}
+			s.frame(frame, closed, closedFrame, funState)
+			s.stack = append(s.stack, frame)
+		case popTokens[token.Type]:
+			if len(s.stack) > 0 {
+				closedFrame = s.stack[len(s.stack)-1]
+				s.stack = s.stack[:len(s.stack)-1]
+				if closedFrame.params {
+					funState = 2
+				}
+				if len(s.stack) == 0 && closedFrame.context == "body of "+s.funName() {
+					s.inFun, s.fun, funState = false, "", 0
+				}
+			}
+			closed = token
+		}
+		if token.Type != symbols["CommentText"] {
+			s.prev = token
+		}
+	}
+}
+
+// frame describes frame, opened after s.prev. closed is the last token
+// closing a frame, closedFrame.
+func (s *syntaxContext) frame(frame *syntaxFrame, closed lexer.Token, closedFrame *syntaxFrame, funState int) {
+	symbols := Lexer.Symbols()
+	top := len(s.stack) == 0
+	name := s.funName()
+	prev := s.prev
+	switch frame.open.Type {
+	case symbols["Paren"]:
+		frame.close = ")"
+		switch {
+		case top && funState == 1:
+			frame.context, frame.params = "parameter list of "+name, true
+		case top && funState == 2:
+			frame.context, frame.params = "effects of "+name, true
+		case prev.Value == "if":
+			frame.context = "condition of if"
+		case prev.Value == "for":
+			frame.context = "header of for loop"
+		case prev.Type == symbols["Ident"]:
+			frame.context = "arguments of call to " + prev.Value
+		case prev.Type == symbols["ParenEnd"] || prev.Type == symbols["BracketEnd"]:
+			frame.context = "arguments of call"
+		default:
+			frame.context = "parentheses"
+		}
+	case symbols["Brace"]:
+		frame.close = "}"
+		switch {
+		case top && funState > 0:
+			frame.context = "body of " + name
+		case prev.Value == "else":
+			frame.context = "body of else"
+		case prev == closed && closedFrame != nil && closedFrame.context == "condition of if":
+			frame.context = "body of if"
+		case prev == closed && closedFrame != nil && closedFrame.context == "header of for loop":
+			frame.context = "body of for loop"
+		default:
+			frame.context = "block"
+		}
+	case symbols["Bracket"]:
+		frame.close, frame.context = "]", "subscript"
+	case symbols["String"]:
+		frame.context = "string"
+		frame.unterminated = `end the string with '"'`
+	case symbols["RawString"]:
+		frame.context = "raw string"
+		frame.unterminated = "end the string with '`'"
+	case symbols["Heredoc"], symbols["RawHeredoc"]:
+		delim := strings.Trim(strings.TrimLeft(frame.open.Value, "<-~"), "`")
+		frame.context = "heredoc " + frame.open.Value
+		frame.unterminated = fmt.Sprintf("end the heredoc with a line containing %q", delim)
+	case symbols["Interpolated"]:
+		frame.close, frame.context = "}", "interpolation"
+	case symbols["Comment"]:
+		frame.context = "comment"
+	}
+}
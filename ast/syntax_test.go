@@ -0,0 +1,93 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/hinshun/hlb-parser/codes"
+)
+
+func TestSyntaxDiagnostic(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		src     string
+		message string
+		hint    string
+		code    string
+	}{{
+		name:    "func keyword",
+		src:     "func a() fs {}\n",
+		message: `unexpected "func", expected declaration`,
+		hint:    `functions are declared with "fun", not "func"`,
+		code:    codes.ForeignKeyword,
+	}, {
+		name:    "export keyword",
+		src:     "export fun a() fs {}\n",
+		message: `unexpected "export", expected declaration`,
+		hint:    `functions are exported with "pub", not "export"`,
+		code:    codes.ForeignKeyword,
+	}, {
+		name:    "missing paren",
+		src:     "fun a() fs {\n\timage(\"alpine\" {}\n}\n",
+		message: `unexpected "}" in arguments of call to image, expected ")"`,
+		hint:    `missing ")" before "}"`,
+		code:    codes.UnclosedBracket,
+	}, {
+		name:    "missing return type",
+		src:     "fun a() {}\n",
+		message: `unexpected "{" in declaration of fun a, expected return type`,
+		code:    codes.MissingReturnType,
+	}, {
+		name:    "parameter missing type",
+		src:     "fun a(b) fs {}\n",
+		message: `unexpected "b" in parameter list of fun a, expected type before parameter name`,
+		code:    codes.IncompleteParameter,
+	}, {
+		name:    "list without trailing comma",
+		src:     "fun a(\n\tfs b\n) fs {}\n",
+		message: `unexpected end of line in parameter list of fun a, expected ")"`,
+		hint:    `end the line with "," to continue the list on the next one`,
+		code:    codes.MissingComma,
+	}, {
+		name:    "unclosed block",
+		src:     "fun a() fs {\n\tscratch\n",
+		message: `unexpected end of file in body of fun a, expected "}"`,
+		code:    codes.UnclosedBracket,
+	}, {
+		name:    "unterminated heredoc",
+		src:     "fun a() fs {\n\trun(<<EOF\n\t\techo\n\t)\n}\n",
+		message: "unterminated heredoc <<EOF",
+		hint:    `end the heredoc with a line containing "EOF"`,
+		code:    codes.UnterminatedString,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := ParseSource("test.hlb", []byte(tc.src), Options{})
+			if len(f.Diagnostics) == 0 {
+				t.Fatal("parsed without errors")
+			}
+			d := f.Diagnostics[0]
+			if d.Message != tc.message {
+				t.Errorf("got message %q, want %q", d.Message, tc.message)
+			}
+			if d.Code != tc.code {
+				t.Errorf("got code %s, want %s", d.Code, tc.code)
+			}
+			if d.Hint != tc.hint {
+				t.Errorf("got hint %q, want %q", d.Hint, tc.hint)
+			}
+		})
+	}
+}
+
+func TestDiagnosticReport(t *testing.T) {
+	f := ParseSource("test.hlb", []byte("fun a() fs {\n\trun(<<EOF\n\t\techo\n\t)\n}\n"), Options{})
+	if len(f.Diagnostics) == 0 {
+		t.Fatal("parsed without errors")
+	}
+	want := "test.hlb:2:6: unterminated heredoc <<EOF [E0002]\n" +
+		"\t\trun(<<EOF\n" +
+		"\t\t    ^\n" +
+		"\thint: end the heredoc with a line containing \"EOF\""
+	if got := f.Diagnostics[0].Report(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,23 @@
+package ast
+
+import "strings"
+
+// String returns the type as written in source, e.g. []option::run.
+func (t *Type) String() string {
+	if t == nil {
+		return ""
+	}
+	var sb strings.Builder
+	switch {
+	case t.Scalar != nil:
+		sb.WriteString(t.Scalar.Text)
+	case t.Array != nil:
+		sb.WriteString("[]")
+		sb.WriteString(t.Array.String())
+	}
+	if t.Association != nil {
+		sb.WriteString("::")
+		sb.WriteString(t.Association.Ident.Text)
+	}
+	return sb.String()
+}
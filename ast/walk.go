@@ -0,0 +1,112 @@
+package ast
+
+import (
+	"reflect"
+)
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If
+// the result visitor w is not nil, Walk visits each of the children of node
+// with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order: It starts by calling
+// v.Visit(node); node must not be nil. If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with visitor w for
+// each of the non-nil children of node, followed by a call of w.Visit(nil).
+//
+// Children are visited in the order their fields are declared, which is the
+// order they appear in the source.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	walkChildren(v, reflect.ValueOf(node))
+	v.Visit(nil)
+}
+
+var nodeType = reflect.TypeOf((*Node)(nil)).Elem()
+
+func walkChildren(v Visitor, rv reflect.Value) {
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if field.Anonymous || field.PkgPath != "" {
+			continue
+		}
+		fv := rv.Field(i)
+		switch fv.Kind() {
+		case reflect.Ptr:
+			walkValue(v, fv)
+		case reflect.Slice:
+			for j := 0; j < fv.Len(); j++ {
+				walkValue(v, fv.Index(j))
+			}
+		}
+	}
+}
+
+func walkValue(v Visitor, fv reflect.Value) {
+	if fv.Kind() != reflect.Ptr || fv.IsNil() || !fv.Type().Implements(nodeType) {
+		return
+	}
+	Walk(v, fv.Interface().(Node))
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: It starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of node, followed by a call of
+// f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// PathEnclosing returns the nodes enclosing offset, from the innermost to
+// root. A node encloses the offsets from its first byte up to but not
+// including its end, so when siblings share a boundary the one starting at
+// offset is chosen. The end of root is enclosed by root.
+func PathEnclosing(root Node, offset int) []Node {
+	var (
+		path  []Node
+		depth int
+	)
+	Inspect(root, func(node Node) bool {
+		if node == nil {
+			depth--
+			return false
+		}
+		// Only descend into a node if its parent is the innermost enclosing
+		// node so far and none of its siblings were chosen.
+		if depth != len(path) || offset < node.Position().Offset {
+			return false
+		}
+		if offset >= node.EndPosition().Offset && (depth > 0 || offset > node.EndPosition().Offset) {
+			return false
+		}
+		path = append(path, node)
+		depth++
+		return true
+	})
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
@@ -0,0 +1,59 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func parseModule(t *testing.T, src string) *Module {
+	t.Helper()
+	f := ParseSource("test.hlb", []byte(src), Options{Comments: true, Features: AllFeatures})
+	if err := f.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return f.Module
+}
+
+// TestInspect checks that nodes are visited in source order, each followed
+// by its children.
+func TestInspect(t *testing.T) {
+	mod := parseModule(t, "fun build(string a) fs {\n\trun(a)\n}\n")
+	var idents []string
+	Inspect(mod, func(node Node) bool {
+		if id, ok := node.(*Ident); ok {
+			idents = append(idents, fmt.Sprintf("%s@%d:%d", id.Text, id.Pos.Line, id.Pos.Column))
+		}
+		return true
+	})
+	want := "build@1:5 string@1:11 a@1:18 fs@1:21 run@2:2 a@2:6"
+	if got := strings.Join(idents, " "); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+// TestInspectPrune checks that the children of a node aren't visited when f
+// returns false for it, and that each visit of children ends with nil.
+func TestInspectPrune(t *testing.T) {
+	mod := parseModule(t, "fun a() fs {\n\trun(\"a\")\n}\n\nfun b() fs {}\n")
+	var funcs, stmts, ends int
+	Inspect(mod, func(node Node) bool {
+		switch node.(type) {
+		case nil:
+			ends++
+		case *FuncDecl:
+			funcs++
+			return false
+		case *Stmt:
+			stmts++
+		}
+		return true
+	})
+	if funcs != 2 || stmts != 0 {
+		t.Errorf("got %d functions and %d statements, want 2 and 0", funcs, stmts)
+	}
+	// The module and its two declarations have their children visited.
+	if ends != 3 {
+		t.Errorf("got %d visits of nil, want 3", ends)
+	}
+}
@@ -0,0 +1,74 @@
+// Package builtin declares the functions implemented by the compiler.
+//
+// The declarations are written in HLB in builtin.hlb, where the doc comment
+// preceding each function documents it and the return type's association
+// scopes options to the function they configure, e.g. option::run.
+package builtin
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+// Filename is the name builtin declarations are parsed under.
+const Filename = "builtin.hlb"
+
+//go:embed builtin.hlb
+var source string
+
+var (
+	// Module is the parsed builtin declarations.
+	Module = mustParse()
+
+	funcs = map[string][]*ast.FuncDecl{}
+)
+
+func init() {
+	for _, decl := range Module.Decls {
+		if decl.Func != nil {
+			funcs[decl.Func.Name.Text] = append(funcs[decl.Func.Name.Text], decl.Func)
+		}
+	}
+}
+
+func mustParse() *ast.Module {
+	f := ast.ParseSource(Filename, []byte(source), ast.Options{Comments: true})
+	if err := f.Err(); err != nil {
+		panic(fmt.Sprintf("failed to parse builtins: %s", err))
+	}
+	return f.Module
+}
+
+// Source returns the HLB source of the builtin declarations.
+func Source() string {
+	return source
+}
+
+// Lookup returns the builtin named name. When there are builtins of the same
+// name for different types, the one returning typ is preferred, otherwise the
+// first one declared is returned.
+func Lookup(name, typ string) *ast.FuncDecl {
+	candidates := funcs[name]
+	for _, fun := range candidates {
+		if fun.Type.String() == typ {
+			return fun
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0]
+	}
+	return nil
+}
+
+// Funcs returns the builtins returning typ, or every builtin if typ is empty.
+func Funcs(typ string) []*ast.FuncDecl {
+	var funs []*ast.FuncDecl
+	for _, decl := range Module.Decls {
+		if decl.Func != nil && (typ == "" || decl.Func.Type.String() == typ) {
+			funs = append(funs, decl.Func)
+		}
+	}
+	return funs
+}
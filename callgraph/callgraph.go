@@ -0,0 +1,244 @@
+// Package callgraph builds the graph of calls between functions.
+//
+// A call site is any reference to a function, whether it has arguments or
+// not, including references inside with clauses and string interpolations.
+package callgraph
+
+import (
+	"sort"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// Edge labels describing how the callee is used at a call site.
+const (
+	LabelWith = "with" // called inside a with clause
+	LabelAs   = "as"   // bound to an effect or the return register
+	LabelAt   = "@"    // one of its effects is accessed
+)
+
+// Options configures which functions are included in the graph.
+type Options struct {
+	// Imports spans the modules loaded for imports, adding the calls made by
+	// their functions. Otherwise imported functions are external leaves.
+	Imports bool
+
+	// Builtins adds calls to builtin functions.
+	Builtins bool
+}
+
+// Graph is a call graph.
+type Graph struct {
+	Nodes []*Node
+	Edges []*Edge
+
+	nodes map[*ast.FuncDecl]*Node
+	edges map[[2]*Node]*Edge
+}
+
+// Node is a function in the call graph.
+type Node struct {
+	// ID is the function name qualified by its import name for functions of
+	// imported modules, e.g. go.build.
+	ID       string
+	Name     string
+	Filename string
+	Pos      lexer.Position
+	Public   bool
+	Builtin  bool
+
+	// External is true for functions of modules that weren't spanned.
+	External bool
+
+	Func *ast.FuncDecl
+}
+
+// Edge is the set of calls from one function to another.
+type Edge struct {
+	Caller *Node
+	Callee *Node
+	Labels []string
+	Sites  []*Site
+}
+
+// Site is a single call.
+type Site struct {
+	Pos    lexer.Position
+	Labels []string
+}
+
+// Build builds the call graph of the resolved module.
+func Build(info *resolve.Info, opts Options) *Graph {
+	g := &Graph{
+		nodes: make(map[*ast.FuncDecl]*Node),
+		edges: make(map[[2]*Node]*Edge),
+	}
+
+	// Name imported functions by the import they were first loaded from.
+	prefixes := map[*resolve.Module]string{info.Modules[0]: ""}
+	for _, mod := range info.Modules {
+		for name, imported := range mod.Imports {
+			if _, ok := prefixes[imported]; !ok {
+				prefixes[imported] = prefixes[mod] + name + "."
+			}
+		}
+	}
+
+	spanned := info.Modules[:1]
+	if opts.Imports {
+		spanned = info.Modules
+	}
+	for _, mod := range spanned {
+		for _, decl := range mod.AST.Decls {
+			if decl.Func != nil {
+				g.node(prefixes, mod, decl.Func, false)
+			}
+		}
+	}
+	for _, mod := range spanned {
+		for _, decl := range mod.AST.Decls {
+			if decl.Func != nil {
+				g.addCalls(info, prefixes, opts, decl.Func)
+			}
+		}
+	}
+
+	sort.SliceStable(g.Nodes, func(i, j int) bool {
+		return g.Nodes[i].ID < g.Nodes[j].ID
+	})
+	sort.SliceStable(g.Edges, func(i, j int) bool {
+		if g.Edges[i].Caller.ID != g.Edges[j].Caller.ID {
+			return g.Edges[i].Caller.ID < g.Edges[j].Caller.ID
+		}
+		return g.Edges[i].Callee.ID < g.Edges[j].Callee.ID
+	})
+	return g
+}
+
+// Node returns the node of fun, or nil if it isn't in the graph.
+func (g *Graph) Node(fun *ast.FuncDecl) *Node {
+	return g.nodes[fun]
+}
+
+func (g *Graph) node(prefixes map[*resolve.Module]string, mod *resolve.Module, fun *ast.FuncDecl, external bool) *Node {
+	if n, ok := g.nodes[fun]; ok {
+		return n
+	}
+	n := &Node{
+		ID:       prefixes[mod] + fun.Name.Text,
+		Name:     fun.Name.Text,
+		Filename: mod.Filename,
+		Pos:      fun.Name.Pos,
+		Public:   resolve.IsPublic(fun),
+		Builtin:  mod == resolve.Universe,
+		External: external,
+		Func:     fun,
+	}
+	g.nodes[fun] = n
+	g.Nodes = append(g.Nodes, n)
+	return n
+}
+
+func (g *Graph) addCalls(info *resolve.Info, prefixes map[*resolve.Module]string, opts Options, fun *ast.FuncDecl) {
+	caller := g.nodes[fun]
+
+	// withs counts the with clauses enclosing the current node.
+	var (
+		stack []ast.Node
+		withs int
+	)
+	ast.Inspect(fun, func(node ast.Node) bool {
+		if node == nil {
+			if _, ok := stack[len(stack)-1].(*ast.WithClause); ok {
+				withs--
+			}
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		stack = append(stack, node)
+
+		switch n := node.(type) {
+		case *ast.WithClause:
+			withs++
+		case *ast.Ref:
+			ident, call := callOf(info, n)
+			if ident == nil {
+				return true
+			}
+			obj := info.Uses[ident]
+			if obj.Kind == resolve.Builtin && !opts.Builtins {
+				return true
+			}
+
+			var callee *Node
+			if existing, ok := g.nodes[obj.FuncDecl()]; ok {
+				callee = existing
+			} else {
+				callee = g.node(prefixes, obj.Module, obj.FuncDecl(), obj.Kind == resolve.Func)
+			}
+
+			site := &Site{Pos: ident.Pos}
+			if withs > 0 {
+				site.Labels = append(site.Labels, LabelWith)
+			}
+			if call != nil && call.As != nil {
+				site.Labels = append(site.Labels, LabelAs)
+			}
+			if call != nil && call.At != nil {
+				site.Labels = append(site.Labels, LabelAt)
+			}
+			g.addSite(caller, callee, site)
+		}
+		return true
+	})
+}
+
+// callOf returns the identifier of the function called by ref and its call
+// clauses, if any.
+func callOf(info *resolve.Info, ref *ast.Ref) (*ast.Ident, *ast.Call) {
+	ident := ref.Terminal.Ident
+	if ident == nil {
+		return nil, nil
+	}
+	next := ref.Next
+	obj := info.Uses[ident]
+	if obj != nil && obj.Kind == resolve.Import && next != nil && next.Selector != nil {
+		ident = next.Selector.Ident
+		obj = info.Uses[ident]
+		next = next.Next
+	}
+	if obj == nil || (obj.Kind != resolve.Func && obj.Kind != resolve.Builtin) {
+		return nil, nil
+	}
+	if next != nil && next.Call != nil {
+		return ident, next.Call
+	}
+	return ident, nil
+}
+
+func (g *Graph) addSite(caller, callee *Node, site *Site) {
+	key := [2]*Node{caller, callee}
+	edge, ok := g.edges[key]
+	if !ok {
+		edge = &Edge{Caller: caller, Callee: callee}
+		g.edges[key] = edge
+		g.Edges = append(g.Edges, edge)
+	}
+	edge.Sites = append(edge.Sites, site)
+	for _, label := range site.Labels {
+		if !contains(edge.Labels, label) {
+			edge.Labels = append(edge.Labels, label)
+		}
+	}
+}
+
+func contains(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package callgraph
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+func TestBuild(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		src   string
+		files map[string]string
+		opts  Options
+		nodes []string
+		edges []string
+	}{{
+		name: "labels",
+		src: `fun base() fs (string digest) {
+	image("alpine")
+}
+
+fun target() string {
+	"bin"
+}
+
+fun opt() option::run {
+	dir("/src")
+}
+
+fun build() fs (fs out) {
+	run("make") with {
+		opt
+	}
+	base@digest
+	run("make ${target()}")
+	base as out
+}
+`,
+		nodes: []string{"base", "build", "opt", "target"},
+		edges: []string{
+			"build -> base [@ as] 2",
+			"build -> opt [with] 1",
+			"build -> target [] 1",
+		},
+	}, {
+		name: "builtins",
+		src: `fun build() fs {
+	image("alpine")
+	run("make") with {
+		dir("/src")
+	}
+	run("make install")
+}
+`,
+		opts:  Options{Builtins: true},
+		nodes: []string{"build", "dir", "image", "run"},
+		edges: []string{
+			"build -> dir [with] 1",
+			"build -> image [] 1",
+			"build -> run [] 2",
+		},
+	}, {
+		name: "imports as leaves",
+		src: `import lib from "lib.hlb"
+
+fun build() fs {
+	lib.base()
+}
+`,
+		files: map[string]string{
+			"lib.hlb": `pub fun base() fs {
+	helper()
+}
+
+fun helper() fs {
+	image("alpine")
+}
+`,
+		},
+		nodes: []string{"build", "lib.base external"},
+		edges: []string{
+			"build -> lib.base [] 1",
+		},
+	}, {
+		name: "imports spanned",
+		src: `import lib from "lib.hlb"
+
+fun build() fs {
+	lib.base()
+}
+`,
+		files: map[string]string{
+			"lib.hlb": `pub fun base() fs {
+	helper()
+}
+
+fun helper() fs {
+	image("alpine")
+}
+`,
+		},
+		opts:  Options{Imports: true},
+		nodes: []string{"build", "lib.base", "lib.helper"},
+		edges: []string{
+			"build -> lib.base [] 1",
+			"lib.base -> lib.helper [] 1",
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, src := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			filename := filepath.Join(dir, "test.hlb")
+			f := ast.ParseSource(filename, []byte(tc.src), ast.Options{})
+			if err := f.Err(); err != nil {
+				t.Fatal(err)
+			}
+			info := resolve.Resolve(filename, f.Module, resolve.FileImporter{})
+			if len(info.Errors) > 0 {
+				t.Fatal(info.Errors[0])
+			}
+			g := Build(info, tc.opts)
+
+			var nodes []string
+			for _, n := range g.Nodes {
+				node := n.ID
+				if n.External {
+					node += " external"
+				}
+				nodes = append(nodes, node)
+			}
+			var edges []string
+			for _, e := range g.Edges {
+				edges = append(edges, fmt.Sprintf("%s -> %s %v %d", e.Caller.ID, e.Callee.ID, e.Labels, len(e.Sites)))
+			}
+			if got, want := strings.Join(nodes, "\n"), strings.Join(tc.nodes, "\n"); got != want {
+				t.Errorf("got nodes:\n%s\nwant:\n%s", got, want)
+			}
+			if got, want := strings.Join(edges, "\n"), strings.Join(tc.edges, "\n"); got != want {
+				t.Errorf("got edges:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
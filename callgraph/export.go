@@ -0,0 +1,131 @@
+package callgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteDOT writes the graph in Graphviz DOT format. Functions are grouped in a
+// cluster per module and edges are labeled with how the callee is used.
+func (g *Graph) WriteDOT(w io.Writer, name string) error {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "digraph %s {\n", strconv.Quote(name))
+	sb.WriteString("\tnode [shape=box];\n")
+
+	var (
+		clusters []string
+		nodes    = make(map[string][]*Node)
+	)
+	for _, n := range g.Nodes {
+		if _, ok := nodes[n.Filename]; !ok {
+			clusters = append(clusters, n.Filename)
+		}
+		nodes[n.Filename] = append(nodes[n.Filename], n)
+	}
+
+	for i, filename := range clusters {
+		fmt.Fprintf(&sb, "\tsubgraph cluster_%d {\n", i)
+		fmt.Fprintf(&sb, "\t\tlabel=%s;\n", strconv.Quote(filename))
+		for _, n := range nodes[filename] {
+			var attrs []string
+			if n.Public {
+				attrs = append(attrs, "penwidth=2")
+			}
+			if n.External || n.Builtin {
+				attrs = append(attrs, "style=dashed")
+			}
+			fmt.Fprintf(&sb, "\t\t%s", strconv.Quote(n.ID))
+			if len(attrs) > 0 {
+				fmt.Fprintf(&sb, " [%s]", strings.Join(attrs, ", "))
+			}
+			sb.WriteString(";\n")
+		}
+		sb.WriteString("\t}\n")
+	}
+
+	for _, e := range g.Edges {
+		fmt.Fprintf(&sb, "\t%s -> %s", strconv.Quote(e.Caller.ID), strconv.Quote(e.Callee.ID))
+		if len(e.Labels) > 0 {
+			fmt.Fprintf(&sb, " [label=%s]", strconv.Quote(strings.Join(e.Labels, ",")))
+		}
+		sb.WriteString(";\n")
+	}
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+type jsonGraph struct {
+	Nodes []*jsonNode `json:"nodes"`
+	Edges []*jsonEdge `json:"edges"`
+}
+
+type jsonNode struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Filename string `json:"filename"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Public   bool   `json:"public,omitempty"`
+	Builtin  bool   `json:"builtin,omitempty"`
+	External bool   `json:"external,omitempty"`
+}
+
+type jsonEdge struct {
+	Caller string      `json:"caller"`
+	Callee string      `json:"callee"`
+	Labels []string    `json:"labels,omitempty"`
+	Sites  []*jsonSite `json:"sites"`
+}
+
+type jsonSite struct {
+	Filename string   `json:"filename"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Labels   []string `json:"labels,omitempty"`
+}
+
+// WriteJSON writes the graph as JSON with a list of nodes and a list of edges
+// referring to nodes by ID. Each edge lists the positions of its call sites.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	out := &jsonGraph{
+		Nodes: []*jsonNode{},
+		Edges: []*jsonEdge{},
+	}
+	for _, n := range g.Nodes {
+		out.Nodes = append(out.Nodes, &jsonNode{
+			ID:       n.ID,
+			Name:     n.Name,
+			Filename: n.Filename,
+			Line:     n.Pos.Line,
+			Column:   n.Pos.Column,
+			Public:   n.Public,
+			Builtin:  n.Builtin,
+			External: n.External,
+		})
+	}
+	for _, e := range g.Edges {
+		edge := &jsonEdge{
+			Caller: e.Caller.ID,
+			Callee: e.Callee.ID,
+			Labels: e.Labels,
+		}
+		for _, site := range e.Sites {
+			edge.Sites = append(edge.Sites, &jsonSite{
+				Filename: site.Pos.Filename,
+				Line:     site.Pos.Line,
+				Column:   site.Pos.Column,
+				Labels:   site.Labels,
+			})
+		}
+		out.Edges = append(out.Edges, edge)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
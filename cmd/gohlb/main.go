@@ -4,7 +4,7 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/alecthomas/repr"
+	"github.com/hinshun/hlb-parser/ast"
 	x "github.com/hinshun/hlb-parser/hlb"
 )
 
@@ -28,7 +28,7 @@ func run() error {
 
 		x.Func("run").Params(x.Variadic("string"), "args").Returns("fs"),
 
-		x.Func("nodeModules").Export().Returns("fs").Body(
+		x.Func("nodeModules").Public().Returns("fs").Body(
 			x.Comments("Optional parens for no argument functions"),
 			x.Ident("node"),
 			x.Ident("run").Call("npm install").With(x.Array("[]option::run").Items(
@@ -49,6 +49,5 @@ func run() error {
 	EOF`),
 		),
 	)
-	repr.Println(mod)
-	return nil
+	return ast.Dump(os.Stdout, mod, ast.DumpOptions{})
 }
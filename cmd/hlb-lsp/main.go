@@ -0,0 +1,19 @@
+// Command hlb-lsp is a language server for HLB speaking the Language Server
+// Protocol over stdin and stdout.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/hinshun/hlb-parser/lsp"
+)
+
+func main() {
+	err := lsp.NewServer(os.Stdin, os.Stdout).Run(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "err: %s\n", err)
+		os.Exit(1)
+	}
+}
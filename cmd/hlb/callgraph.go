@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/callgraph"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+func init() {
+	commands["callgraph"] = &command{
+		usage: "[-format dot|json] [-imports] [-builtins] <file>",
+		short: "export the call graph of a module",
+		run:   runCallgraph,
+	}
+}
+
+func runCallgraph(args []string) error {
+	fs := newFlagSet("callgraph")
+	format := fs.String("format", "dot", "output format, dot or json")
+	imports := fs.Bool("imports", false, "span modules imported from local paths")
+	builtins := fs.Bool("builtins", false, "include calls to builtin functions")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one file")
+	}
+
+	filename := fs.Arg(0)
+	mod, err := parseFile(filename)
+	if err != nil {
+		return err
+	}
+
+	info := resolve.Resolve(filename, mod, resolve.FileImporter{})
+	g := callgraph.Build(info, callgraph.Options{
+		Imports:  *imports,
+		Builtins: *builtins,
+	})
+
+	switch *format {
+	case "dot":
+		return g.WriteDOT(os.Stdout, filename)
+	case "json":
+		return g.WriteJSON(os.Stdout)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func parseFile(filename string) (*ast.Module, error) {
+	f, err := ast.ParseFile(filename, ast.Options{Features: ast.AllFeatures})
+	if err != nil {
+		return nil, err
+	}
+	if err := f.Err(); err != nil {
+		return nil, err
+	}
+	return f.Module, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCallgraphImports(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"test.hlb": `import lib from "lib.hlb"
+
+fun build() fs {
+	lib.base()
+}
+`,
+		"lib.hlb": `pub fun base() fs {
+	helper()
+}
+
+fun helper() fs {
+	image("alpine")
+}
+`,
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	filename := filepath.Join(dir, "test.hlb")
+
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{{
+		args: []string{"-format", "json", filename},
+		want: "build lib.base",
+	}, {
+		args: []string{"-format", "json", "-imports", filename},
+		want: "build lib.base lib.helper",
+	}} {
+		out := captureStdout(t, func() error { return runCallgraph(tc.args) })
+		var g struct {
+			Nodes []struct{ ID string }
+		}
+		if err := json.Unmarshal([]byte(out), &g); err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, n := range g.Nodes {
+			ids = append(ids, n.ID)
+		}
+		if got := strings.Join(ids, " "); got != tc.want {
+			t.Errorf("%s: got nodes %s, want %s", strings.Join(tc.args[:len(tc.args)-1], " "), got, tc.want)
+		}
+	}
+}
+
+// captureStdout returns what run writes to stdout.
+func captureStdout(t *testing.T, run func() error) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	err = run()
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return <-out
+}
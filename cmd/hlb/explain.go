@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/hinshun/hlb-parser/codes"
+)
+
+func init() {
+	commands["explain"] = &command{
+		usage: "[code]",
+		short: "explain a diagnostic code, or list the codes",
+		run:   runExplain,
+	}
+}
+
+func runExplain(args []string) error {
+	fs := newFlagSet("explain")
+	fs.Parse(args)
+
+	switch fs.NArg() {
+	case 0:
+		for _, e := range codes.Entries() {
+			fmt.Printf("%s  %s\n", e.Code, e.Title)
+		}
+		return nil
+	case 1:
+		code := strings.ToUpper(fs.Arg(0))
+		e := codes.Lookup(code)
+		if e == nil {
+			return fmt.Errorf("unknown code %q, run hlb explain to list the codes", fs.Arg(0))
+		}
+		writeEntry(os.Stdout, e)
+		return nil
+	default:
+		fs.Usage()
+		return fmt.Errorf("expected at most one code")
+	}
+}
+
+// writeEntry writes the explanation of e followed by its examples, indented.
+func writeEntry(w io.Writer, e *codes.Entry) {
+	fmt.Fprintf(w, "%s: %s\n\n%s\n", e.Code, e.Title, e.Explanation)
+	var names []string
+	for name := range e.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "\nWith %s:\n\n%s", name, indent(e.Files[name]))
+	}
+	fmt.Fprintf(w, "\nErroneous example:\n\n%s", indent(e.Example))
+	fmt.Fprintf(w, "\nFixed example:\n\n%s", indent(e.Fixed))
+}
+
+func indent(src string) string {
+	var sb strings.Builder
+	for _, line := range strings.SplitAfter(src, "\n") {
+		if strings.TrimSpace(line) != "" {
+			sb.WriteString("\t")
+		}
+		sb.WriteString(line)
+	}
+	return sb.String()
+}
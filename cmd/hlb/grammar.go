@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/alecthomas/participle/v2/ebnf"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/railroad"
+)
+
+func init() {
+	commands["grammar"] = &command{
+		usage: "[-html file] [-svg dir]",
+		short: "print the grammar as EBNF, or draw its railroad diagrams",
+		run:   runGrammar,
+	}
+}
+
+func runGrammar(args []string) error {
+	fs := newFlagSet("grammar")
+	htmlFile := fs.String("html", "", "write a page with the diagram of each production to `file`")
+	svgDir := fs.String("svg", "", "write the diagram of each production to `dir`/<production>.svg")
+	fs.Parse(args)
+
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments")
+	}
+	if *htmlFile == "" && *svgDir == "" {
+		fmt.Print(ast.EBNF())
+		return nil
+	}
+
+	g, err := ebnf.ParseString(ast.GrammarEBNF())
+	if err != nil {
+		return err
+	}
+	if *htmlFile != "" {
+		f, err := os.Create(*htmlFile)
+		if err != nil {
+			return err
+		}
+		err = railroad.HTML(f, "HLB grammar", g)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if *svgDir != "" {
+		if err := os.MkdirAll(*svgDir, 0o755); err != nil {
+			return err
+		}
+		link := func(name string) string { return name + ".svg" }
+		for _, p := range g.Productions {
+			path := filepath.Join(*svgDir, p.Production+".svg")
+			if err := os.WriteFile(path, []byte(railroad.SVG(p, link)), 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"plugin"
+	"sort"
+	"strings"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/analysis/checker"
+	"github.com/hinshun/hlb-parser/analysis/passes"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/fix"
+	"github.com/hinshun/hlb-parser/internal/diff"
+)
+
+func init() {
+	commands["lint"] = &command{
+		usage: "[-analyzers a,b] [-plugin file.so] [-list] [-fix|-diff] <file>...",
+		short: "run static analyzers over modules",
+		run:   runLint,
+	}
+}
+
+// stringsFlag is a flag that may be repeated.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+func runLint(args []string) error {
+	fs := newFlagSet("lint")
+	names := fs.String("analyzers", "", "comma separated analyzers to run, defaults to all")
+	list := fs.Bool("list", false, "list the available analyzers and exit")
+	fixFlag := fs.Bool("fix", false, "apply suggested fixes to the files")
+	diffFlag := fs.Bool("diff", false, "print the suggested fixes as a unified diff instead of applying them")
+	var plugins stringsFlag
+	fs.Var(&plugins, "plugin", "load analyzers from a Go plugin exporting `var Analyzers []*analysis.Analyzer`, may be repeated")
+	fs.Parse(args)
+
+	available := append([]*analysis.Analyzer{}, passes.Analyzers...)
+	for _, path := range plugins {
+		analyzers, err := loadPlugin(path)
+		if err != nil {
+			return err
+		}
+		available = append(available, analyzers...)
+	}
+
+	if *list {
+		sort.Slice(available, func(i, j int) bool {
+			return available[i].Name < available[j].Name
+		})
+		for _, a := range available {
+			fmt.Printf("%-16s %s\n", a.Name, strings.SplitN(a.Doc, "\n", 2)[0])
+		}
+		return nil
+	}
+
+	analyzers, err := selectAnalyzers(available, *names)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected at least one file")
+	}
+
+	count := 0
+	for _, filename := range fs.Args() {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		res, err := checker.Analyze(filename, src, analyzers)
+		if d, ok := err.(*ast.Diagnostic); ok {
+			fmt.Fprintln(os.Stderr, d.Report())
+			count++
+			continue
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			count++
+			continue
+		}
+		if !*fixFlag && !*diffFlag {
+			for _, d := range res.Diagnostics {
+				fmt.Println(d)
+			}
+			count += len(res.Diagnostics)
+			continue
+		}
+
+		unfixed, err := applyFixes(res, *diffFlag)
+		if err != nil {
+			return err
+		}
+		count += unfixed
+	}
+	if count > 0 {
+		return fmt.Errorf("found %d problems", count)
+	}
+	return nil
+}
+
+// applyFixes applies the first suggested fix of each diagnostic, skipping
+// fixes that conflict with ones already applied, and either writes the result
+// or prints it as a diff. It returns the number of diagnostics left unfixed.
+func applyFixes(res *checker.Result, printDiff bool) (int, error) {
+	var (
+		set     fix.Set
+		unfixed int
+	)
+	for _, d := range res.Diagnostics {
+		if len(d.SuggestedFixes) == 0 {
+			fmt.Println(d)
+			unfixed++
+			continue
+		}
+		if err := set.Add(d.SuggestedFixes[0].TextEdits...); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: skipped fix: %s\n", d, err)
+			unfixed++
+		}
+	}
+	if len(set.Edits()) == 0 {
+		return unfixed, nil
+	}
+
+	out, err := fix.Fix(res.Filename, res.Source, set.Edits())
+	if err != nil {
+		return unfixed, fmt.Errorf("%s: %w", res.Filename, err)
+	}
+	if printDiff {
+		fmt.Print(diff.Unified(res.Filename, res.Filename, res.Source, out))
+		return unfixed, nil
+	}
+
+	info, err := os.Stat(res.Filename)
+	if err != nil {
+		return unfixed, err
+	}
+	return unfixed, os.WriteFile(res.Filename, out, info.Mode())
+}
+
+func selectAnalyzers(available []*analysis.Analyzer, names string) ([]*analysis.Analyzer, error) {
+	if names == "" {
+		return available, nil
+	}
+	byName := make(map[string]*analysis.Analyzer)
+	for _, a := range available {
+		byName[a.Name] = a
+	}
+	var analyzers []*analysis.Analyzer
+	for _, name := range strings.Split(names, ",") {
+		a, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown analyzer %q", name)
+		}
+		analyzers = append(analyzers, a)
+	}
+	return analyzers, nil
+}
+
+func loadPlugin(path string) ([]*analysis.Analyzer, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup("Analyzers")
+	if err != nil {
+		return nil, err
+	}
+	analyzers, ok := sym.(*[]*analysis.Analyzer)
+	if !ok {
+		return nil, fmt.Errorf("plugin %s: Analyzers is %T, not []*analysis.Analyzer", path, sym)
+	}
+	return *analyzers, nil
+}
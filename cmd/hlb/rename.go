@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/fix"
+	"github.com/hinshun/hlb-parser/internal/diff"
+	"github.com/hinshun/hlb-parser/rename"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+func init() {
+	commands["rename"] = &command{
+		usage: "[-diff] <file>:<line>:<column> <name>",
+		short: "rename a declaration and its references",
+		run:   runRename,
+	}
+}
+
+func runRename(args []string) error {
+	fs := newFlagSet("rename")
+	diffFlag := fs.Bool("diff", false, "print the changes as a unified diff instead of applying them")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected a position and a name")
+	}
+
+	filename, line, column, err := parsePosition(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	// Modules are identified by filename, which must be written the same as
+	// when other modules import them.
+	filename = filepath.Clean(filename)
+	mod, err := parseFile(filename)
+	if err != nil {
+		return err
+	}
+	ident := identAt(mod, line, column)
+	if ident == nil {
+		return fmt.Errorf("%s: no identifier at %d:%d", filename, line, column)
+	}
+
+	info := resolve.Resolve(filename, mod, resolve.FileImporter{})
+	importers, err := resolveDir(filepath.Dir(filename), filename)
+	if err != nil {
+		return err
+	}
+	edits, err := rename.Rename(info, ident, fs.Arg(1), importers)
+	if err != nil {
+		return err
+	}
+
+	var filenames []string
+	for filename := range edits {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	for _, filename := range filenames {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		out, err := fix.Apply(src, edits[filename])
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		if *diffFlag {
+			fmt.Print(diff.Unified(filename, filename, src, out))
+			continue
+		}
+
+		fi, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(filename, out, fi.Mode())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveDir resolves the modules in dir other than the one of filename,
+// which may import it.
+func resolveDir(dir, filename string) ([]*resolve.Info, error) {
+	files, err := ast.ParseDir(dir, ast.Options{Recover: true, Features: ast.AllFeatures})
+	if err != nil {
+		return nil, err
+	}
+	infos := []*resolve.Info{}
+	for _, f := range files {
+		if f.Filename == filename || f.Module == nil {
+			continue
+		}
+		infos = append(infos, resolve.Resolve(f.Filename, f.Module, resolve.FileImporter{}))
+	}
+	return infos, nil
+}
+
+// parsePosition parses a position of the form file:line:column.
+func parsePosition(pos string) (filename string, line, column int, err error) {
+	parts := strings.Split(pos, ":")
+	if len(parts) < 3 {
+		return "", 0, 0, fmt.Errorf("invalid position %q, expected file:line:column", pos)
+	}
+	n := len(parts)
+	line, err = strconv.Atoi(parts[n-2])
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid line in %q: %w", pos, err)
+	}
+	column, err = strconv.Atoi(parts[n-1])
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("invalid column in %q: %w", pos, err)
+	}
+	return strings.Join(parts[:n-2], ":"), line, column, nil
+}
+
+// identAt returns the identifier spanning the one-based line and column.
+func identAt(mod *ast.Module, line, column int) *ast.Ident {
+	var found *ast.Ident
+	ast.Inspect(mod, func(node ast.Node) bool {
+		ident, ok := node.(*ast.Ident)
+		if !ok || found != nil {
+			return found == nil
+		}
+		if ident.Pos.Line == line && ident.Pos.Column <= column && column < ident.EndPos.Column {
+			found = ident
+		}
+		return true
+	})
+	return found
+}
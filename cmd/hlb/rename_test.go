@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenameImporters(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib.hlb": `pub fun build() fs {
+	image("alpine")
+}
+`,
+		"main.hlb": `import lib from "lib.hlb"
+
+fun test() fs {
+	lib.build()
+}
+`,
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The position is written differently from how main.hlb imports lib.hlb.
+	pos := dir + "/./lib.hlb:1:9"
+	if err := runRename([]string{pos, "make"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		"lib.hlb":  strings.Replace(files["lib.hlb"], "build", "make", 1),
+		"main.hlb": strings.Replace(files["main.hlb"], "lib.build", "lib.make", 1),
+	} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/hinshun/hlb-parser/index"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+func init() {
+	commands["tags"] = &command{
+		usage: "[-e] [-o file] <file>...",
+		short: "write a tags file of the functions in modules",
+		run:   runTags,
+	}
+}
+
+func runTags(args []string) error {
+	fs := newFlagSet("tags")
+	etags := fs.Bool("e", false, "write an etags file for emacs instead of a ctags file")
+	output := fs.String("o", "", "file to write, or - for stdout, defaults to tags or TAGS with -e")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected at least one file")
+	}
+
+	var infos []*resolve.Info
+	for _, filename := range fs.Args() {
+		mod, err := parseFile(filename)
+		if err != nil {
+			return err
+		}
+		infos = append(infos, resolve.Resolve(filename, mod, resolve.FileImporter{}))
+	}
+	idx := index.Build(infos...)
+
+	var (
+		buf bytes.Buffer
+		err error
+	)
+	if *etags {
+		err = idx.WriteEtags(&buf, os.ReadFile)
+	} else {
+		err = idx.WriteCtags(&buf)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case *output == "-":
+		_, err = os.Stdout.Write(buf.Bytes())
+		return err
+	case *output == "" && *etags:
+		*output = "TAGS"
+	case *output == "":
+		*output = "tags"
+	}
+	return os.WriteFile(*output, buf.Bytes(), 0o644)
+}
@@ -1,19 +1,30 @@
 package main
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"strings"
 	"syscall/js"
+	"time"
+	"unicode/utf16"
 
-	"github.com/alecthomas/repr"
 	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/completion"
+	"github.com/hinshun/hlb-parser/highlight"
 )
 
 func main() {
 	js.Global().Set("parseHLB", parseWrapper())
+	js.Global().Set("dumpHLB", dumpWrapper())
+	js.Global().Set("completeHLB", completeWrapper())
+	js.Global().Set("signatureHLB", signatureWrapper())
+	js.Global().Set("highlightHLB", highlightWrapper())
 	<-make(chan struct{})
 }
 
+// parseWrapper returns the module parsed from the input in the JSON encoding
+// of ast.MarshalJSON, or the syntax error.
 func parseWrapper() js.Func {
 	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
 		if len(args) != 1 {
@@ -21,22 +32,182 @@ func parseWrapper() js.Func {
 		}
 
 		input := args[0].String()
-		mod, err := parse(input)
+		data, err := parse(input)
 		if err != nil {
 			return err.Error()
 		}
-		return mod
+		return data
 	})
 }
 
+// checkLimits returns an error if the input exceeds the limits of untrusted
+// sources, as it's typed by anyone in the playground. Inputs exceeding them
+// aren't completed or highlighted.
+func checkLimits(input string) error {
+	if d := ast.CheckLimits("build.hlb", []byte(input), ast.Untrusted); d != nil {
+		return d
+	}
+	return nil
+}
+
 func parse(input string) (string, error) {
-	mod := &ast.Module{}
-	err := ast.Parser.Parse("build.hlb", strings.NewReader(input), mod)
+	mod, err := parseModule(input)
 	if err != nil {
 		return "", err
 	}
+	data, err := ast.MarshalJSON("build.hlb", mod)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// parseTimeout bounds the time spent parsing an input, which is parsed on
+// every keystroke.
+const parseTimeout = time.Second
+
+// parseModule parses the input once, with the limits of untrusted sources and
+// a deadline.
+func parseModule(input string) (*ast.Module, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), parseTimeout)
+	defer cancel()
+
+	opts := ast.Untrusted
+	opts.Context = ctx
+	opts.Comments = true
+	opts.Features = ast.AllFeatures
+	f := ast.ParseSource("build.hlb", []byte(input), opts)
+	if len(f.Diagnostics) > 0 {
+		return nil, errors.New(f.Diagnostics[0].Report())
+	}
+	return f.Module, nil
+}
+
+// dumpWrapper returns the module parsed from the input as a tree written by
+// ast.Dump, or the syntax error. The optional second and third arguments are
+// the maximum depth of the nodes written and their comma separated kinds.
+func dumpWrapper() js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) < 1 || len(args) > 3 {
+			return "must have 1 to 3 args"
+		}
+
+		mod, err := parseModule(args[0].String())
+		if err != nil {
+			return err.Error()
+		}
+		var opts ast.DumpOptions
+		if len(args) > 1 {
+			opts.MaxDepth = args[1].Int()
+		}
+		if len(args) > 2 && args[2].String() != "" {
+			opts.Kinds = strings.Split(args[2].String(), ",")
+		}
+		var sb strings.Builder
+		if err := ast.Dump(&sb, mod, opts); err != nil {
+			return err.Error()
+		}
+		return sb.String()
+	})
+}
+
+// completeWrapper returns the completions at a cursor in the input as JSON.
+// The cursor is an offset in UTF-16 code units like a JavaScript string index,
+// and so are the start and end of the word replaced by the completions.
+func completeWrapper() js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != 2 {
+			return "must have exactly 2 args"
+		}
+
+		input := args[0].String()
+		if checkLimits(input) != nil {
+			return "null"
+		}
+		list := completion.Complete("build.hlb", input, byteOffset(input, args[1].Int()), nil)
+		list.Start = utf16Offset(input, list.Start)
+		list.End = utf16Offset(input, list.End)
+		data, err := json.Marshal(list)
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	})
+}
+
+// signatureWrapper returns the signature of the call at a cursor in the input
+// as JSON, or null if the cursor isn't in the arguments of a call. Like for
+// completions, the cursor and the ranges of parameters in the label are in
+// UTF-16 code units.
+func signatureWrapper() js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != 2 {
+			return "must have exactly 2 args"
+		}
+
+		input := args[0].String()
+		if checkLimits(input) != nil {
+			return "null"
+		}
+		sig := completion.SignatureHelp("build.hlb", input, byteOffset(input, args[1].Int()), nil)
+		if sig != nil {
+			for i, param := range sig.Params {
+				sig.Params[i].Start = utf16Offset(sig.Label, param.Start)
+				sig.Params[i].End = utf16Offset(sig.Label, param.End)
+			}
+		}
+		data, err := json.Marshal(sig)
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	})
+}
+
+// highlightWrapper returns the classified tokens of the input as JSON. Like
+// for completions, the start and end of tokens are in UTF-16 code units.
+func highlightWrapper() js.Func {
+	return js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) != 1 {
+			return "must have exactly 1 arg"
+		}
+
+		type span struct {
+			Class highlight.Class `json:"class"`
+			Start int             `json:"start"`
+			End   int             `json:"end"`
+		}
+		input := args[0].String()
+		if checkLimits(input) != nil {
+			return "[]"
+		}
+		tokens, _ := highlight.Source("build.hlb", input)
+		spans := []span{}
+		for _, token := range tokens {
+			spans = append(spans, span{
+				Class: token.Class,
+				Start: utf16Offset(input, token.Pos.Offset),
+				End:   utf16Offset(input, token.End.Offset),
+			})
+		}
+		data, err := json.Marshal(spans)
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	})
+}
+
+func byteOffset(s string, units int) int {
+	for i, r := range s {
+		if units <= 0 {
+			return i
+		}
+		units -= len(utf16.Encode([]rune{r}))
+	}
+	return len(s)
+}
 
-	buf := new(bytes.Buffer)
-	repr.New(buf).Println(mod)
-	return buf.String(), nil
+func utf16Offset(s string, offset int) int {
+	return len(utf16.Encode([]rune(s[:offset])))
 }
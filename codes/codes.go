@@ -0,0 +1,461 @@
+// Package codes defines the stable codes of the diagnostics reported for HLB
+// modules, and a catalog explaining each of them with an example reporting
+// it and the example fixed.
+//
+// Codes are never reused: a diagnostic that is no longer reported keeps its
+// code reserved. Syntax errors are numbered from E0001, resolution errors
+// from E0100 and the diagnostics of the analyzers from E0200.
+package codes
+
+import (
+	"sort"
+	"strings"
+)
+
+// Syntax errors.
+const (
+	UnexpectedToken     = "E0001"
+	UnterminatedString  = "E0002"
+	ForeignKeyword      = "E0003"
+	UnclosedBracket     = "E0004"
+	MissingComma        = "E0005"
+	IncompleteParameter = "E0006"
+	UnparenthesizedHead = "E0007"
+	MissingOperand      = "E0008"
+	MissingReturnType   = "E0009"
+	ExperimentalSyntax  = "E0010"
+	LimitExceeded       = "E0011"
+)
+
+// Resolution errors.
+const (
+	Undefined        = "E0100"
+	Redeclared       = "E0101"
+	ImportFailed     = "E0102"
+	NotPublic        = "E0103"
+	UnknownParameter = "E0104"
+	UnknownEffect    = "E0105"
+	BindToNonEffect  = "E0106"
+)
+
+// Analyzer diagnostics.
+const (
+	ShadowedFunction = "E0200"
+	SimplifiableWith = "E0201"
+)
+
+// Entry explains a code.
+type Entry struct {
+	Code  string
+	Title string
+
+	// Explanation is one or more paragraphs separated by blank lines.
+	Explanation string
+
+	// Example is a module reporting the code, and Fixed is the example
+	// with the problem fixed.
+	Example string
+	Fixed   string
+
+	// Files are the other modules in the directory of the examples, by
+	// filename, for the examples importing them.
+	Files map[string]string
+
+	// Untrusted is whether the examples are parsed with the limits for
+	// untrusted sources, ast.Untrusted.
+	Untrusted bool
+}
+
+// Lookup returns the entry of code, or nil if there is none.
+func Lookup(code string) *Entry {
+	i := sort.Search(len(catalog), func(i int) bool {
+		return catalog[i].Code >= code
+	})
+	if i < len(catalog) && catalog[i].Code == code {
+		return catalog[i]
+	}
+	return nil
+}
+
+// Entries returns the entries of the catalog, sorted by code.
+func Entries() []*Entry {
+	return append([]*Entry{}, catalog...)
+}
+
+// catalog is sorted by code.
+var catalog = []*Entry{{
+	Code:  UnexpectedToken,
+	Title: "unexpected token",
+	Explanation: `The parser found a token that can't appear where it is. The message
+names the construct being parsed and what was expected instead.
+
+This is the code of syntax errors that have no more specific code.`,
+	Example: `fun build() fs {
+	image("alpine"))
+}
+`,
+	Fixed: `fun build() fs {
+	image("alpine")
+}
+`,
+}, {
+	Code:  UnterminatedString,
+	Title: "unterminated string",
+	Explanation: `A string, raw string or heredoc isn't closed before the end of the
+file. The error points at where it starts.
+
+Strings end with '"' and raw strings with a backquote on the same line. A
+heredoc started with <<EOF ends with a line holding only EOF, after
+optional indentation.`,
+	Example: `fun build() fs {
+	image("alpine")
+	run(<<EOF
+		apk add git
+	)
+}
+`,
+	Fixed: `fun build() fs {
+	image("alpine")
+	run(<<EOF
+		apk add git
+	EOF)
+}
+`,
+}, {
+	Code:  ForeignKeyword,
+	Title: "keyword of another language",
+	Explanation: `A declaration starts with a keyword that HLB doesn't have but other
+languages do.
+
+Functions are declared with "fun", and made visible to the modules
+importing them with "pub".`,
+	Example: `func build() fs {
+	image("alpine")
+}
+`,
+	Fixed: `fun build() fs {
+	image("alpine")
+}
+`,
+}, {
+	Code:  UnclosedBracket,
+	Title: "unclosed bracket",
+	Explanation: `A parenthesis, brace or square bracket isn't closed: the file ends,
+or a bracket opened before it is closed first.
+
+The error names the construct the bracket opened, like the arguments of a
+call or the body of a function.`,
+	Example: `fun build() fs {
+	image("alpine"
+}
+`,
+	Fixed: `fun build() fs {
+	image("alpine")
+}
+`,
+}, {
+	Code:  MissingComma,
+	Title: "line of a list not ended with a comma",
+	Explanation: `A newline after a name, literal or closing bracket ends the statement,
+as if followed by a semicolon, even in a list.
+
+To continue a list of parameters or arguments on the next line, end the
+line with a comma. The last element may have one too.`,
+	Example: `fun build(
+	string ref
+) fs {
+	image(ref)
+}
+`,
+	Fixed: `fun build(
+	string ref,
+) fs {
+	image(ref)
+}
+`,
+}, {
+	Code:  IncompleteParameter,
+	Title: "parameter without a type or name",
+	Explanation: `Each parameter and effect is declared with its type followed by its
+name, like "string ref".`,
+	Example: `fun build(ref) fs {
+	image(ref)
+}
+`,
+	Fixed: `fun build(string ref) fs {
+	image(ref)
+}
+`,
+}, {
+	Code:  UnparenthesizedHead,
+	Title: "if or for without parentheses",
+	Explanation: `The condition of an if statement and the header of a for loop are
+written in parentheses, like "if (cond) { ... }".`,
+	Example: `fun build(bool debug) fs {
+	image("alpine")
+	if debug {
+		run("apk add strace")
+	}
+}
+`,
+	Fixed: `fun build(bool debug) fs {
+	image("alpine")
+	if (debug) {
+		run("apk add strace")
+	}
+}
+`,
+}, {
+	Code:  MissingOperand,
+	Title: "operator without an operand",
+	Explanation: `An operator isn't followed by the expression it applies to.
+
+An operator at the end of a line continues the expression on the next one,
+so the operand may be written there.`,
+	Example: `fun enabled(bool a, bool b) bool {
+	a &&
+}
+`,
+	Fixed: `fun enabled(bool a, bool b) bool {
+	a &&
+		b
+}
+`,
+}, {
+	Code:  MissingReturnType,
+	Title: "function without a return type",
+	Explanation: `A function declares the type it returns after its parameters, like
+"fun build() fs". Its body starts after the return type, and the effects if
+any.`,
+	Example: `fun build() {
+	image("alpine")
+}
+`,
+	Fixed: `fun build() fs {
+	image("alpine")
+}
+`,
+}, {
+	Code:  ExperimentalSyntax,
+	Title: "experimental syntax not enabled",
+	Explanation: `The module uses syntax that is still experimental: for loops, splats
+or subscripts. It parses, but is only accepted when the feature is enabled
+by the tool reading the module.
+
+Write the module without it, or enable the feature.`,
+	Example: `fun build(string... pkgs) fs {
+	image("alpine")
+	for (pkg in pkgs) {
+		run("apk add ${pkg}")
+	}
+}
+`,
+	Fixed: `fun build(string pkgs) fs {
+	image("alpine")
+	run("apk add ${pkgs}")
+}
+`,
+}, {
+	Code:  LimitExceeded,
+	Title: "parsing limit exceeded",
+	Explanation: `The source exceeds a limit of the parser, which then stops without
+parsing it: its size, its number of tokens, how deeply it nests brackets
+and strings, or the number of syntax errors reported. Parsing also stops
+when it is canceled, like when it takes too long.
+
+Tools reading sources they don't trust, like the playground, set these
+limits well below what a module needs. Split the module, or nest less.`,
+	Example: `fun build() fs {
+	image(` + strings.Repeat("(", 64) + `"alpine"` + strings.Repeat(")", 64) + `)
+}
+`,
+	Fixed: `fun build() fs {
+	image("alpine")
+}
+`,
+	Untrusted: true,
+}, {
+	Code:  Undefined,
+	Title: "undefined name",
+	Explanation: `A name refers to no function of the module, builtin, import,
+parameter, effect or loop variable in scope, or an imported module has no
+function of that name.
+
+Names are case sensitive.`,
+	Example: `fun build() fs {
+	imge("alpine")
+}
+`,
+	Fixed: `fun build() fs {
+	image("alpine")
+}
+`,
+}, {
+	Code:  Redeclared,
+	Title: "name redeclared",
+	Explanation: `Two functions of a module, imports, or parameters and effects of a
+function have the same name. The second declaration is reported.`,
+	Example: `fun build() fs {
+	image("alpine")
+}
+
+fun build() fs {
+	image("busybox")
+}
+`,
+	Fixed: `fun build() fs {
+	image("alpine")
+}
+
+fun buildBusybox() fs {
+	image("busybox")
+}
+`,
+}, {
+	Code:  ImportFailed,
+	Title: "import could not be loaded",
+	Explanation: `A module imported from a local path doesn't exist or can't be read.
+
+Local paths are relative to the directory of the importing module. Modules
+imported from images aren't loaded, and can't report this error.`,
+	Example: `import lib from "./lbi.hlb"
+
+fun build() fs {
+	lib.build()
+}
+`,
+	Fixed: `import lib from "./lib.hlb"
+
+fun build() fs {
+	lib.build()
+}
+`,
+	Files: map[string]string{
+		"lib.hlb": `pub fun build() fs {
+	image("alpine")
+}
+`,
+	},
+}, {
+	Code:  NotPublic,
+	Title: "function not public",
+	Explanation: `A function of an imported module is called, but only functions
+declared with "pub" can be called from other modules.`,
+	Example: `import lib from "./lib.hlb"
+
+fun build() fs {
+	lib.base()
+}
+`,
+	Fixed: `import lib from "./lib.hlb"
+
+fun build() fs {
+	lib.build()
+}
+`,
+	Files: map[string]string{
+		"lib.hlb": `fun base() fs {
+	image("alpine")
+}
+
+pub fun build() fs {
+	base()
+}
+`,
+	},
+}, {
+	Code:        UnknownParameter,
+	Title:       "unknown parameter",
+	Explanation: `A named argument names no parameter of the called function.`,
+	Example: `fun build(string ref) fs {
+	image(ref)
+}
+
+fun alpine() fs {
+	build(image: "alpine")
+}
+`,
+	Fixed: `fun build(string ref) fs {
+	image(ref)
+}
+
+fun alpine() fs {
+	build(ref: "alpine")
+}
+`,
+}, {
+	Code:  UnknownEffect,
+	Title: "unknown effect",
+	Explanation: `A call selects an effect with "@" that the called function doesn't
+declare.`,
+	Example: `fun build() fs (fs out) {
+	image("alpine")
+}
+
+fun artifact() fs {
+	build@result()
+}
+`,
+	Fixed: `fun build() fs (fs out) {
+	image("alpine")
+}
+
+fun artifact() fs {
+	build@out()
+}
+`,
+}, {
+	Code:  BindToNonEffect,
+	Title: "binding to a name that isn't an effect",
+	Explanation: `The result of a call is bound with "as" to the special return register
+or to an effect of the enclosing function, not to a parameter or a
+function.`,
+	Example: `fun build(fs src) fs (fs out) {
+	image("alpine") as src
+}
+`,
+	Fixed: `fun build(fs src) fs (fs out) {
+	image("alpine") as out
+}
+`,
+}, {
+	Code:  ShadowedFunction,
+	Title: "local name masks a function",
+	Explanation: `A parameter, effect or loop variable has the name of a function of the
+module, which can't be called from the body of the function any more.
+
+This is reported by the shadow analyzer.`,
+	Example: `fun base() fs {
+	image("alpine")
+}
+
+fun build(fs base) fs {
+	base
+}
+`,
+	Fixed: `fun base() fs {
+	image("alpine")
+}
+
+fun build(fs src) fs {
+	src
+}
+`,
+}, {
+	Code:  SimplifiableWith,
+	Title: "with clause of a single option",
+	Explanation: `A with clause applies a block of a single option. A single expression
+is allowed as a single element block, so the braces can be removed.
+
+This is reported by the simplifywith analyzer, which suggests the fix.`,
+	Example: `fun build() fs {
+	image("alpine")
+	run("apk add git") with { readonly }
+}
+`,
+	Fixed: `fun build() fs {
+	image("alpine")
+	run("apk add git") with readonly
+}
+`,
+}}
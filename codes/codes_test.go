@@ -0,0 +1,119 @@
+package codes_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hinshun/hlb-parser/analysis/checker"
+	"github.com/hinshun/hlb-parser/analysis/passes"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/codes"
+)
+
+// TestExamples checks that the example of each entry reports its code, and
+// that the fixed example reports nothing.
+func TestExamples(t *testing.T) {
+	for _, e := range codes.Entries() {
+		e := e
+		t.Run(e.Code, func(t *testing.T) {
+			if e.Title == "" || e.Explanation == "" {
+				t.Error("missing title or explanation")
+			}
+			found := false
+			for _, d := range check(t, e, e.Example) {
+				found = found || d.code == e.Code
+			}
+			if !found {
+				t.Errorf("example doesn't report %s", e.Code)
+			}
+			for _, d := range check(t, e, e.Fixed) {
+				t.Errorf("fixed example reports %s", d)
+			}
+		})
+	}
+}
+
+// TestLimits checks that every limit stopping the parser reports
+// LimitExceeded, not only the one of its example.
+func TestLimits(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	src := []byte("fun build() fs {\n\timage(\"alpine\")\n}\n")
+	for _, tc := range []struct {
+		name string
+		src  []byte
+		opts ast.Options
+	}{
+		{"size", src, ast.Options{MaxSize: 8}},
+		{"tokens", src, ast.Options{MaxTokens: 4}},
+		{"depth", src, ast.Options{MaxDepth: 1}},
+		{"errors", []byte("fun a( {}\nfun b( {}\n"), ast.Options{Recover: true, MaxErrors: 1}},
+		{"canceled", src, ast.Options{Context: canceled}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := ast.ParseSource("limits.hlb", tc.src, tc.opts)
+			if n := len(f.Diagnostics); n == 0 || f.Diagnostics[n-1].Code != codes.LimitExceeded {
+				t.Errorf("got %v, want a last diagnostic with %s", f.Diagnostics, codes.LimitExceeded)
+			}
+			if d := ast.CheckLimits("limits.hlb", tc.src, tc.opts); tc.name != "errors" && (d == nil || d.Code != codes.LimitExceeded) {
+				t.Errorf("CheckLimits() = %v, want %s", d, codes.LimitExceeded)
+			}
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	entries := codes.Entries()
+	for i, e := range entries {
+		if i > 0 && entries[i-1].Code >= e.Code {
+			t.Errorf("%s is after %s", e.Code, entries[i-1].Code)
+		}
+		if got := codes.Lookup(e.Code); got != e {
+			t.Errorf("Lookup(%s) = %v", e.Code, got)
+		}
+	}
+	if got := codes.Lookup("E9999"); got != nil {
+		t.Errorf("Lookup(E9999) = %s", got.Code)
+	}
+}
+
+type diagnostic struct {
+	code, msg string
+}
+
+func (d diagnostic) String() string { return d.msg }
+
+// check parses and analyzes src in a directory holding the files of e, and
+// returns its diagnostics.
+func check(t *testing.T, e *codes.Entry, src string) []diagnostic {
+	dir := t.TempDir()
+	for name, file := range e.Files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(file), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	filename := filepath.Join(dir, "example.hlb")
+
+	var diags []diagnostic
+	var opts ast.Options
+	if e.Untrusted {
+		opts = ast.Untrusted
+	}
+	f := ast.ParseSource(filename, []byte(src), opts)
+	for _, d := range f.Diagnostics {
+		diags = append(diags, diagnostic{d.Code, d.Error()})
+	}
+	if len(diags) > 0 {
+		return diags
+	}
+	res, err := checker.AnalyzeModule(filename, []byte(src), f.Module, passes.Analyzers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range res.Diagnostics {
+		diags = append(diags, diagnostic{d.Code, d.String()})
+	}
+	return diags
+}
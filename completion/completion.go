@@ -0,0 +1,560 @@
+// Package completion suggests the functions, variables, effects, keywords and
+// snippets that can be inserted at a position in a module.
+//
+// The source being edited usually doesn't parse, so it is patched first: a
+// placeholder identifier is inserted when the cursor isn't on a word, and if
+// the source still doesn't parse, everything after the cursor is replaced by
+// the brackets and strings left open before it. The enclosing nodes of the
+// word in the patched module give the expected type, such as option::run
+// inside the with clause of a call to run, and its resolved scope gives the
+// candidates.
+package completion
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/builtin"
+	"github.com/hinshun/hlb-parser/format"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// Kind is the kind of a completion item.
+type Kind int
+
+const (
+	Function Kind = iota // function declared in a module
+	Builtin              // builtin function
+	Variable             // parameter or loop variable
+	Effect               // function effect
+	Module               // imported module
+	Keyword              // keyword or statement template
+	Type                 // type name
+)
+
+var kindNames = [...]string{
+	Function: "function",
+	Builtin:  "builtin",
+	Variable: "variable",
+	Effect:   "effect",
+	Module:   "module",
+	Keyword:  "keyword",
+	Type:     "type",
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Item is a completion candidate.
+type Item struct {
+	Label  string `json:"label"`
+	Kind   Kind   `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+	Doc    string `json:"doc,omitempty"`
+
+	// Insert is the text replacing the word at the cursor, in snippet syntax:
+	// ${1:name} is a placeholder and $0 is the final cursor position.
+	Insert string `json:"insert"`
+
+	// rank orders items before their labels, lowest first.
+	rank int
+}
+
+// List is the result of a completion.
+type List struct {
+	// Start and End are the byte offsets of the word replaced by an item.
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	Items []*Item `json:"items"`
+}
+
+// Ranks of items, so that the most specific come first.
+const (
+	rankLocal = iota
+	rankMatch
+	rankOther
+	rankKeyword
+)
+
+// Complete returns the completions at offset in src, the source of the module
+// named filename. Modules imported from local paths are loaded with imp unless
+// it is nil.
+func Complete(filename, src string, offset int, imp resolve.Importer) *List {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(src) {
+		offset = len(src)
+	}
+	start := wordStart(src, offset)
+	list := &List{Start: start, End: offset}
+	if start < offset && isDigit(src[start]) {
+		return list
+	}
+
+	s, ok := scanSource(filename, src[:offset])
+	if !ok || !s.inCode() {
+		return list
+	}
+	c := &completer{
+		scan:   s,
+		start:  start,
+		prefix: src[start:offset],
+	}
+	if mod := parse(filename, src, start, offset, s); mod != nil {
+		c.info = resolve.Resolve(filename, mod, imp)
+		c.path = ast.PathEnclosing(mod, start)
+	}
+	c.complete()
+
+	for _, item := range c.items {
+		if strings.HasPrefix(item.Label, c.prefix) && item.Label != placeholder {
+			list.Items = append(list.Items, item)
+		}
+	}
+	sort.SliceStable(list.Items, func(i, j int) bool {
+		a, b := list.Items[i], list.Items[j]
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		return a.Label < b.Label
+	})
+	return list
+}
+
+type completer struct {
+	scan   *scan
+	start  int
+	prefix string
+
+	// info and path are nil if the source couldn't be patched to parse.
+	info *resolve.Info
+	path []ast.Node
+
+	items []*Item
+}
+
+func (c *completer) add(item *Item) {
+	c.items = append(c.items, item)
+}
+
+func (c *completer) complete() {
+	var ident *ast.Ident
+	if len(c.path) > 1 {
+		ident, _ = c.path[0].(*ast.Ident)
+	}
+	if ident == nil || ident.Pos.Offset != c.start {
+		if len(c.scan.open) == 0 {
+			c.topLevel()
+		}
+		return
+	}
+
+	switch parent := c.path[1].(type) {
+	case *ast.Selector:
+		c.selector(parent)
+	case *ast.AtClause:
+		if fun := c.callee(c.path[2:], c.path[2].(*ast.Call)); fun != nil {
+			c.fields(resolve.Effect, fun.Effects)
+		}
+	case *ast.Type:
+		if parent.Scalar == ident {
+			c.types(scalarTypes())
+		}
+	case *ast.Association:
+		c.types(associations())
+	case *ast.Terminal:
+		if c.isAsTarget() {
+			c.bindings()
+			return
+		}
+		c.expr()
+	}
+}
+
+// topLevel completes the keywords starting declarations.
+func (c *completer) topLevel() {
+	prev, ok := c.scan.previous(c.start)
+	switch {
+	case !ok:
+		c.keyword("import", `import ${1:name} from "${2:path}"`)
+		c.keyword("pub", "pub fun ${1:name}($2) ${3:fs} {\n\t$0\n}")
+		c.keyword("fun", "fun ${1:name}($2) ${3:fs} {\n\t$0\n}")
+	case prev.Value == "pub":
+		c.keyword("fun", "fun ${1:name}($2) ${3:fs} {\n\t$0\n}")
+	}
+}
+
+// selector completes the public functions of an imported module after its
+// name and a dot.
+func (c *completer) selector(sel *ast.Selector) {
+	ref, _ := c.path[3].(*ast.Ref)
+	if ref == nil || ref.Terminal.Ident == nil || ref.Next.Selector != sel {
+		return
+	}
+	imp := c.info.ObjectOf(ref.Terminal.Ident)
+	if imp == nil || imp.Kind != resolve.Import || imp.Module == nil {
+		return
+	}
+	for _, decl := range imp.Module.AST.Decls {
+		if decl.Func != nil && resolve.IsPublic(decl.Func) {
+			c.add(funcItem(imp.Module.Scope.Objects[decl.Func.Name.Text], rankOther))
+		}
+	}
+}
+
+// isAsTarget reports whether the word is the target of an as clause.
+func (c *completer) isAsTarget() bool {
+	if len(c.path) < 4 {
+		return false
+	}
+	as, ok := c.path[3].(*ast.AsClause)
+	return ok && as.Effect == c.path[2] && as.Effect.Next == nil
+}
+
+// bindings completes the targets of an as clause: the effects of the
+// enclosing function and the return register.
+func (c *completer) bindings() {
+	for _, node := range c.path {
+		if fun, ok := node.(*ast.FuncDecl); ok {
+			c.fields(resolve.Effect, fun.Effects)
+			break
+		}
+	}
+	c.add(&Item{
+		Label:  "return",
+		Kind:   Keyword,
+		Detail: "bind to the return register",
+		Insert: "return",
+		rank:   rankKeyword,
+	})
+}
+
+// expr completes an identifier starting an operand.
+func (c *completer) expr() {
+	if c.clauseKeywords() {
+		return
+	}
+	typ := c.expected()
+	if isStmtStart(c.path) {
+		c.keyword("if", "if (${1:condition}) {\n\t$0\n}")
+		c.keyword("for", "for (${1:item} in ${2:items}) {\n\t$0\n}")
+	}
+
+	seen := map[string]bool{placeholder: true}
+	for scope := c.scope(); scope != nil; scope = scope.Parent {
+		for name, obj := range scope.Objects {
+			if !seen[name] {
+				seen[name] = true
+				c.object(obj, typ)
+			}
+		}
+	}
+	for _, fun := range builtin.Funcs("") {
+		name := fun.Name.Text
+		if !seen[name] {
+			seen[name] = true
+			c.object(resolve.LookupBuiltin(name, typ), typ)
+		}
+	}
+}
+
+// clauseKeywords completes the keywords following a call or block on the same
+// line, reporting whether the word follows one.
+func (c *completer) clauseKeywords() bool {
+	prev, ok := c.scan.previous(c.start)
+	if !ok {
+		return false
+	}
+	switch prev.Type {
+	case symbols["ParenEnd"], symbols["Ident"]:
+		c.keyword("with", "with $0")
+		c.keyword("as", "as $0")
+	case symbols["BraceEnd"]:
+		c.keyword("else", "else {\n\t$0\n}")
+	default:
+		return false
+	}
+	return true
+}
+
+// object completes an object in scope where a value of typ is expected.
+// Option types only admit functions and parameters of that type, while other
+// types rank matching functions first.
+func (c *completer) object(obj *resolve.Object, typ string) {
+	option := isOption(typ)
+	switch obj.Kind {
+	case resolve.Builtin, resolve.Func:
+		ftyp := obj.FuncDecl().Type.String()
+		switch {
+		case option && ftyp != typ, !option && isOption(ftyp):
+		case ftyp == typ:
+			c.add(funcItem(obj, rankMatch))
+		default:
+			c.add(funcItem(obj, rankOther))
+		}
+	case resolve.Param, resolve.Effect:
+		field := obj.Decl.(*ast.Field)
+		if !option || field.Type.String() == typ {
+			c.add(fieldItem(obj.Kind, field))
+		}
+	case resolve.Var:
+		if !option {
+			c.add(&Item{
+				Label:  obj.Name,
+				Kind:   Variable,
+				Detail: "loop variable",
+				Insert: obj.Name,
+				rank:   rankLocal,
+			})
+		}
+	case resolve.Import:
+		if !option {
+			c.add(&Item{
+				Label:  obj.Name,
+				Kind:   Module,
+				Detail: format.String(obj.Decl.(*ast.ImportDecl)),
+				Insert: obj.Name,
+				rank:   rankOther,
+			})
+		}
+	}
+}
+
+// expected returns the type expected of the operand at the cursor, or an
+// empty string if it's unknown.
+func (c *completer) expected() string {
+	for i, node := range c.path {
+		switch n := node.(type) {
+		case *ast.ExprList:
+			call, ok := c.path[i+1].(*ast.Call)
+			if !ok {
+				return ""
+			}
+			return argType(c.callee(c.path[i+1:], call), n, c.start)
+		case *ast.WithClause:
+			ident := calleeIdent(c.path[i+1:], c.path[i+1].(*ast.Call))
+			if ident == nil {
+				return ""
+			}
+			return "option::" + ident.Text
+		case *ast.BlockLit:
+			if n.Type != nil {
+				return strings.TrimPrefix(n.Type.String(), "[]")
+			}
+		case *ast.FuncDecl:
+			return n.Type.String()
+		case *ast.Condition, *ast.ForHeader, *ast.Subscript, *ast.Interpolated,
+			*ast.FieldDefault, *ast.Group, *ast.ImportDecl, *ast.Entry:
+			return ""
+		}
+	}
+	return ""
+}
+
+// scope returns the innermost scope enclosing the cursor.
+func (c *completer) scope() *resolve.Scope {
+	for _, node := range c.path {
+		if scope, ok := c.info.Scopes[node]; ok {
+			return scope
+		}
+	}
+	return nil
+}
+
+// callee returns the declaration of the function called by call, where path
+// are the nodes enclosing it starting with call.
+func (c *completer) callee(path []ast.Node, call *ast.Call) *ast.FuncDecl {
+	ident := calleeIdent(path, call)
+	if ident == nil {
+		return nil
+	}
+	obj := c.info.ObjectOf(ident)
+	if obj == nil {
+		return nil
+	}
+	return obj.FuncDecl()
+}
+
+// calleeIdent returns the identifier naming the function called by call,
+// which is the last one before it in the enclosing reference.
+func calleeIdent(path []ast.Node, call *ast.Call) *ast.Ident {
+	for _, node := range path {
+		ref, ok := node.(*ast.Ref)
+		if !ok {
+			continue
+		}
+		ident := ref.Terminal.Ident
+		for next := ref.Next; next != nil; next = next.Next {
+			switch {
+			case next.Call == call:
+				return ident
+			case next.Selector != nil:
+				ident = next.Selector.Ident
+			default:
+				ident = nil
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// argType returns the type of the parameter of fun receiving the argument at
+// offset in args.
+func argType(fun *ast.FuncDecl, args *ast.ExprList, offset int) string {
+	if fun == nil {
+		return ""
+	}
+	params := resolve.Fields(fun.Params)
+	if i := activeParam(params, args, offset); i >= 0 {
+		return params[i].Type.String()
+	}
+	return ""
+}
+
+// isStmtStart reports whether the identifier at the start of path begins a
+// statement of a block.
+func isStmtStart(path []ast.Node) bool {
+	if len(path) < 6 {
+		return false
+	}
+	stmt, ok := path[5].(*ast.Stmt)
+	return ok && stmt.Expr == path[4] && path[1] == stmt.Expr.Unary.Ref.Terminal
+}
+
+func (c *completer) fields(kind resolve.Kind, list *ast.FieldList) {
+	for _, field := range resolve.Fields(list) {
+		c.add(fieldItem(kind, field))
+	}
+}
+
+func (c *completer) types(names []string) {
+	for _, name := range names {
+		c.add(&Item{Label: name, Kind: Type, Insert: name, rank: rankOther})
+	}
+}
+
+func (c *completer) keyword(name, snippet string) {
+	c.add(&Item{Label: name, Kind: Keyword, Insert: snippet, rank: rankKeyword})
+}
+
+// funcItem returns the item for a function, which inserts a call with
+// placeholders for the parameters without defaults.
+func funcItem(obj *resolve.Object, rank int) *Item {
+	fun := obj.FuncDecl()
+	sig := *fun
+	sig.Body = nil
+
+	kind := Function
+	if obj.Kind == resolve.Builtin {
+		kind = Builtin
+	}
+
+	var params []string
+	for _, field := range resolve.Fields(fun.Params) {
+		if field.Default == nil {
+			params = append(params, fmt.Sprintf("${%d:%s}", len(params)+1, field.Name.Text))
+		}
+	}
+	insert := fun.Name.Text
+	if len(params) > 0 {
+		insert += "(" + strings.Join(params, ", ") + ")"
+	}
+
+	return &Item{
+		Label:  fun.Name.Text,
+		Kind:   kind,
+		Detail: format.String(&sig),
+		Doc:    obj.Module.AST.Doc(fun),
+		Insert: insert,
+		rank:   rank,
+	}
+}
+
+func fieldItem(kind resolve.Kind, field *ast.Field) *Item {
+	item := &Item{
+		Label:  field.Name.Text,
+		Kind:   Variable,
+		Detail: format.String(field),
+		Insert: field.Name.Text,
+		rank:   rankLocal,
+	}
+	if kind == resolve.Effect {
+		item.Kind = Effect
+	}
+	return item
+}
+
+// scalarTypes returns the names of the types used by builtins.
+func scalarTypes() []string {
+	names := map[string]bool{"bool": true, "int": true}
+	eachType(func(t *ast.Type) {
+		for t.Array != nil {
+			t = t.Array
+		}
+		names[t.Scalar.Text] = true
+	})
+	return sortedKeys(names)
+}
+
+// associations returns the names that builtin option types are associated
+// with, e.g. run for option::run.
+func associations() []string {
+	names := map[string]bool{}
+	eachType(func(t *ast.Type) {
+		if t.Association != nil {
+			names[t.Association.Ident.Text] = true
+		}
+	})
+	return sortedKeys(names)
+}
+
+func eachType(f func(*ast.Type)) {
+	for _, fun := range builtin.Funcs("") {
+		f(fun.Type)
+		for _, list := range []*ast.FieldList{fun.Params, fun.Effects} {
+			for _, field := range resolve.Fields(list) {
+				f(field.Type)
+			}
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isOption(typ string) bool {
+	return strings.Contains(typ, "::")
+}
+
+// wordStart returns the offset of the start of the word ending at offset.
+func wordStart(src string, offset int) int {
+	start := offset
+	for start > 0 && isIdentByte(src[start-1]) {
+		start--
+	}
+	return start
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || isDigit(b) || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
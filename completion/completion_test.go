@@ -0,0 +1,100 @@
+package completion
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+func TestComplete(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "go.hlb"), []byte(`pub fun build(string pkg) fs {
+	image("golang")
+}
+
+fun internal() fs
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cursor is at the | in each source.
+	for _, tc := range []struct {
+		name string
+		src  string
+		want []string
+		not  []string
+	}{{
+		name: "with block",
+		src:  "fun f() fs {\n\trun(\"make\") with {\n\t\t|\n\t}\n}\n",
+		want: []string{"dir", "mount", "ssh"},
+		not:  []string{"image", "readonly"},
+	}, {
+		name: "unterminated with block",
+		src:  "fun f() fs {\n\trun(\"make\") with { mount(scratch, \"/in\") with { re|",
+		want: []string{"readonly"},
+		not:  []string{"dir", "resolve"},
+	}, {
+		name: "statement",
+		src:  "fun f(string pkg) fs {\n\tfor (x in pkg) {\n\t\t|\n\t}\n}\n",
+		want: []string{"if", "for", "x", "pkg", "image", "f"},
+		not:  []string{"readonly", "_"},
+	}, {
+		name: "selector",
+		src:  "import go from \"./go.hlb\"\n\nfun f() fs {\n\tgo.|\n}\n",
+		want: []string{"build"},
+		not:  []string{"internal", "image"},
+	}, {
+		name: "effect",
+		src:  "fun f() string {\n\tdockerPush(\"ref\")@|\n}\n",
+		want: []string{"digest"},
+	}, {
+		name: "as",
+		src:  "fun f() fs (string out) {\n\trun(\"x\") with {\n\t\tmount(scratch, \"/out\") as |\n\t}\n}\n",
+		want: []string{"out", "return"},
+		not:  []string{"image"},
+	}, {
+		name: "clause",
+		src:  "fun f() fs {\n\trun(\"x\") w|\n}\n",
+		want: []string{"with"},
+		not:  []string{"image", "if"},
+	}, {
+		name: "top level",
+		src:  "fun f() fs\n\n|",
+		want: []string{"fun", "import", "pub"},
+	}, {
+		name: "type",
+		src:  "fun f() o|",
+		want: []string{"option"},
+	}, {
+		name: "string",
+		src:  "fun f() fs {\n\timage(\"|\")\n}\n",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			offset := strings.Index(tc.src, "|")
+			src := tc.src[:offset] + tc.src[offset+1:]
+			list := Complete(filepath.Join(dir, "test.hlb"), src, offset, resolve.FileImporter{})
+
+			labels := map[string]bool{}
+			for _, item := range list.Items {
+				labels[item.Label] = true
+			}
+			for _, label := range tc.want {
+				if !labels[label] {
+					t.Errorf("missing %s in %v", label, labels)
+				}
+			}
+			for _, label := range tc.not {
+				if labels[label] {
+					t.Errorf("unexpected %s in %v", label, labels)
+				}
+			}
+			if tc.want == nil && len(list.Items) > 0 {
+				t.Errorf("unexpected items %v", labels)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package completion
+
+import (
+	"strings"
+
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/format"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// Signature is the signature of the function called by the innermost call
+// whose arguments enclose the cursor.
+type Signature struct {
+	// Label renders the signature, such as
+	// `build(string pkg, string... flags) fs (string digest)`.
+	Label string `json:"label"`
+	Doc   string `json:"doc,omitempty"`
+
+	Params []Param `json:"params"`
+
+	// Active is the index of the parameter receiving the argument at the
+	// cursor, or -1 if no parameter does.
+	Active int `json:"active"`
+}
+
+// Param is a parameter of a signature.
+type Param struct {
+	// Start and End are the byte offsets of the parameter in the label of
+	// its signature.
+	Start int `json:"start"`
+	End   int `json:"end"`
+
+	Name     string `json:"name"`
+	Variadic bool   `json:"variadic,omitempty"`
+
+	// Default is the default value of the parameter, or empty if it has none.
+	Default string `json:"default,omitempty"`
+}
+
+// SignatureHelp returns the signature of the function called with the
+// arguments at offset in src, the source of the module named filename, or nil
+// if the cursor isn't in the arguments of a call to a known function. Modules
+// imported from local paths are loaded with imp unless it is nil.
+func SignatureHelp(filename, src string, offset int, imp resolve.Importer) *Signature {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > len(src) {
+		offset = len(src)
+	}
+	start := wordStart(src, offset)
+	s, ok := scanSource(filename, src[:offset])
+	if !ok || !s.inCode() {
+		return nil
+	}
+	mod := parse(filename, src, start, offset, s)
+	if mod == nil {
+		return nil
+	}
+	info := resolve.Resolve(filename, mod, imp)
+	path := ast.PathEnclosing(mod, start)
+
+	for i, node := range path {
+		switch n := node.(type) {
+		case *ast.ExprList:
+			call, ok := path[i+1].(*ast.Call)
+			if !ok {
+				return nil
+			}
+			ident := calleeIdent(path[i+1:], call)
+			if ident == nil {
+				return nil
+			}
+			obj := info.ObjectOf(ident)
+			if obj == nil || obj.FuncDecl() == nil {
+				return nil
+			}
+			sig := signature(obj)
+			sig.Active = activeParam(resolve.Fields(obj.FuncDecl().Params), n, start)
+			return sig
+		case *ast.StmtList, *ast.WithClause, *ast.FieldList, *ast.ImportDecl:
+			// The cursor is in a block, option or declaration rather than
+			// directly in arguments.
+			return nil
+		}
+	}
+	return nil
+}
+
+// signature renders the signature of the function obj.
+func signature(obj *resolve.Object) *Signature {
+	fun := obj.FuncDecl()
+	sig := &Signature{Doc: obj.Module.AST.Doc(fun)}
+
+	var sb strings.Builder
+	sb.WriteString(fun.Name.Text)
+	sb.WriteString("(")
+	for i, field := range resolve.Fields(fun.Params) {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		param := Param{
+			Start:    sb.Len(),
+			Name:     field.Name.Text,
+			Variadic: field.Variadic != nil,
+		}
+		if field.Default != nil {
+			param.Default = format.String(field.Default.Unary)
+		}
+		sb.WriteString(format.String(field))
+		param.End = sb.Len()
+		sig.Params = append(sig.Params, param)
+	}
+	sb.WriteString(") ")
+	sb.WriteString(fun.Type.String())
+	if fun.Effects != nil {
+		sb.WriteString(" ")
+		sb.WriteString(format.String(fun.Effects))
+	}
+	sig.Label = sb.String()
+	return sig
+}
+
+// activeParam returns the index of the parameter in params receiving the
+// argument at offset in args, or -1 if none does. An entry passes the
+// parameter named by its first key, and positional arguments after it pass
+// the parameters following that one. Arguments beyond the parameters, and
+// arguments at or after a splat, pass the variadic parameter if there is one.
+func activeParam(params []*ast.Field, args *ast.ExprList, offset int) int {
+	variadic := -1
+	if len(params) > 0 && params[len(params)-1].Variadic != nil {
+		variadic = len(params) - 1
+	}
+	index := func(name string) int {
+		for i, param := range params {
+			if param.Name.Text == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	next, spread := 0, false
+	for _, arg := range args.Exprs {
+		if arg.Entry == nil && arg.Expr == nil {
+			continue
+		}
+		current := arg.EndPos.Offset > offset
+		switch {
+		case arg.Entry != nil:
+			i := index(arg.Entry.Keys[0].Text)
+			if current {
+				return i
+			}
+			if i >= 0 {
+				next = i + 1
+			}
+			continue
+		case isSplat(arg.Expr):
+			spread = true
+		}
+		if current {
+			break
+		}
+		next++
+	}
+	if spread || next >= len(params) {
+		return variadic
+	}
+	return next
+}
+
+// isSplat reports whether expr spreads a value over the remaining
+// parameters, as in `args...`.
+func isSplat(expr *ast.Expr) bool {
+	if expr.Unary == nil || expr.Unary.Ref == nil {
+		return false
+	}
+	next := expr.Unary.Ref.Next
+	for next != nil && next.Next != nil {
+		next = next.Next
+	}
+	return next != nil && next.Splat != nil
+}
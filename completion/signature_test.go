@@ -0,0 +1,95 @@
+package completion
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSignatureHelp(t *testing.T) {
+	const decl = "# test runs the tests of a package.\nfun test(fs src, string package, string config = \"default\", string... flags) fs {\n\timage(\"golang\")\n}\n\n"
+
+	// The cursor is at the | in each source.
+	for _, tc := range []struct {
+		name   string
+		src    string
+		label  string
+		active string
+	}{{
+		name:   "unterminated",
+		src:    "fun f() fs {\n\ttest(context(\".\"), |",
+		label:  `test(fs src, string package, string config = "default", string... flags) fs`,
+		active: "package",
+	}, {
+		name:   "nested",
+		src:    "fun f() fs {\n\ttest(image(|), \"pkg\")\n}\n",
+		label:  "image(string ref) fs",
+		active: "ref",
+	}, {
+		name:   "word",
+		src:    "fun f(string pkg) fs {\n\ttest(scratch, pk|)\n}\n",
+		active: "package",
+	}, {
+		name:   "entry",
+		src:    "fun f() fs {\n\ttest(scratch, config: |)\n}\n",
+		active: "config",
+	}, {
+		name:   "after entry",
+		src:    "fun f() fs {\n\ttest(scratch, package: \"pkg\", |)\n}\n",
+		active: "config",
+	}, {
+		name:   "variadic",
+		src:    "fun f() fs {\n\ttest(scratch, \"pkg\", \"config\", \"-v\", |)\n}\n",
+		active: "flags",
+	}, {
+		name:   "splat",
+		src:    "fun f(string... args) fs {\n\ttest(scratch, args..., |)\n}\n",
+		active: "flags",
+	}, {
+		name: "block argument",
+		src:  "fun f() fs {\n\ttest(fs {\n\t\t|\n\t})\n}\n",
+	}, {
+		name: "outside call",
+		src:  "fun f() fs {\n\ttest(scratch, \"pkg\") |\n}\n",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := decl + tc.src
+			offset := strings.Index(src, "|")
+			src = src[:offset] + src[offset+1:]
+
+			sig := SignatureHelp("test.hlb", src, offset, nil)
+			if tc.active == "" {
+				if sig != nil {
+					t.Fatalf("got signature %+v, want none", sig)
+				}
+				return
+			}
+			if sig == nil {
+				t.Fatal("got no signature")
+			}
+			if tc.label != "" && sig.Label != tc.label {
+				t.Errorf("got label %q, want %q", sig.Label, tc.label)
+			}
+			if sig.Active < 0 {
+				t.Fatalf("got no active parameter, want %s", tc.active)
+			}
+			param := sig.Params[sig.Active]
+			if param.Name != tc.active {
+				t.Errorf("got active parameter %s, want %s", param.Name, tc.active)
+			}
+			if got := sig.Label[param.Start:param.End]; !strings.Contains(got, " "+param.Name) {
+				t.Errorf("got parameter label %q for %s", got, param.Name)
+			}
+		})
+	}
+
+	sig := SignatureHelp("test.hlb", decl+"fun f() fs {\n\ttest(", len(decl)+19, nil)
+	if sig == nil || sig.Doc != "test runs the tests of a package." {
+		t.Fatalf("got signature %+v, want the doc comment of test", sig)
+	}
+	if config := sig.Params[2]; config.Default != `"default"` {
+		t.Errorf("got default %q for config", config.Default)
+	}
+	if flags := sig.Params[3]; !flags.Variadic {
+		t.Errorf("got non-variadic flags")
+	}
+}
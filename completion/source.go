@@ -0,0 +1,143 @@
+package completion
+
+import (
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+// placeholder is inserted at the cursor when it isn't on a word, so that
+// there is an identifier to find the enclosing nodes of. The resolver ignores
+// it like any other blank identifier.
+const placeholder = "_"
+
+var symbols = ast.Lexer.Symbols()
+
+// openers maps the tokens that push a lexer state to the text closing it.
+// Heredocs are closed by their own terminator.
+var openers = map[lexer.TokenType]string{
+	symbols["Paren"]:        ")",
+	symbols["Brace"]:        "}",
+	symbols["Bracket"]:      "]",
+	symbols["Interpolated"]: "}",
+	symbols["String"]:       `"`,
+	symbols["RawString"]:    "`",
+	symbols["Heredoc"]:      "",
+	symbols["RawHeredoc"]:   "",
+	symbols["Comment"]:      "\n",
+}
+
+var closers = map[lexer.TokenType]bool{
+	symbols["ParenEnd"]:      true,
+	symbols["BraceEnd"]:      true,
+	symbols["BracketEnd"]:    true,
+	symbols["StringEnd"]:     true,
+	symbols["RawStringEnd"]:  true,
+	symbols["HeredocEnd"]:    true,
+	symbols["RawHeredocEnd"]: true,
+	symbols["CommentEnd"]:    true,
+}
+
+// code are the tokens opening states in which the cursor is in code rather
+// than in the text of a string or comment.
+var code = map[lexer.TokenType]bool{
+	symbols["Paren"]:        true,
+	symbols["Brace"]:        true,
+	symbols["Bracket"]:      true,
+	symbols["Interpolated"]: true,
+}
+
+// scan is the result of lexing the source before the cursor.
+type scan struct {
+	// tokens are the tokens before the cursor, without whitespace.
+	tokens []lexer.Token
+
+	// open are the tokens of the states left open, outermost first.
+	open []lexer.Token
+}
+
+func scanSource(filename, src string) (*scan, bool) {
+	lex, err := ast.Scanner.Lex(filename, strings.NewReader(src))
+	if err != nil {
+		return nil, false
+	}
+	s := &scan{}
+	for {
+		token, err := lex.Next()
+		if err != nil {
+			return nil, false
+		}
+		if token.EOF() {
+			return s, true
+		}
+		switch {
+		case token.Type == symbols["Whitespace"]:
+			continue
+		case closers[token.Type] && len(s.open) > 0:
+			s.open = s.open[:len(s.open)-1]
+		default:
+			if _, ok := openers[token.Type]; ok {
+				s.open = append(s.open, token)
+			}
+		}
+		s.tokens = append(s.tokens, token)
+	}
+}
+
+// inCode reports whether the end of the scanned source is in code.
+func (s *scan) inCode() bool {
+	return len(s.open) == 0 || code[s.open[len(s.open)-1].Type]
+}
+
+// closing returns the text closing every state left open.
+func (s *scan) closing() string {
+	var sb strings.Builder
+	for i := len(s.open) - 1; i >= 0; i-- {
+		token := s.open[i]
+		switch token.Type {
+		case symbols["Heredoc"], symbols["RawHeredoc"]:
+			sb.WriteString("\n")
+			sb.WriteString(strings.Trim(token.Value, "<-~`"))
+		default:
+			sb.WriteString(openers[token.Type])
+		}
+	}
+	return sb.String()
+}
+
+// previous returns the last token before offset on the same line, if any.
+func (s *scan) previous(offset int) (lexer.Token, bool) {
+	for i := len(s.tokens) - 1; i >= 0; i-- {
+		token := s.tokens[i]
+		if token.Pos.Offset >= offset {
+			continue
+		}
+		if token.Type == symbols["Newline"] {
+			break
+		}
+		return token, true
+	}
+	return lexer.Token{}, false
+}
+
+// parse parses the source patched so that the word at the cursor is an
+// identifier. It first tries the whole source, then the source up to the
+// cursor with the brackets and strings left open closed. It returns nil if
+// neither parses.
+func parse(filename, src string, start, offset int, s *scan) *ast.Module {
+	fill := ""
+	if start == offset {
+		fill = placeholder
+	}
+	for _, text := range []string{
+		src[:offset] + fill + src[offset:],
+		src[:offset] + fill + s.closing(),
+	} {
+		f := ast.ParseSource(filename, []byte(text), ast.Options{Comments: true, Features: ast.AllFeatures})
+		if f.Err() == nil {
+			return f.Module
+		}
+	}
+	return nil
+}
@@ -0,0 +1,282 @@
+// Package cst implements a lossless concrete syntax tree of HLB source.
+//
+// The parser drops the whitespace between tokens and turns newlines into
+// semicolons, so the AST alone can't reproduce the source it was parsed
+// from. A File keeps the tokens of the source instead, each with the
+// whitespace, newlines and comments around it attached as trivia, and maps
+// the nodes of the AST to the tokens they span. Tools can then edit the
+// source of a node without disturbing the formatting around it.
+package cst
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+var (
+	whitespaceToken  = ast.Lexer.Symbols()["Whitespace"]
+	commentToken     = ast.Lexer.Symbols()["Comment"]
+	commentTextToken = ast.Lexer.Symbols()["CommentText"]
+	commentEndToken  = ast.Lexer.Symbols()["CommentEnd"]
+)
+
+// TriviaKind is the kind of a piece of trivia.
+type TriviaKind int
+
+const (
+	// Whitespace is a run of spaces and tabs.
+	Whitespace TriviaKind = iota
+	// Newline is a newline that doesn't end a statement.
+	Newline
+	// Semicolon is a newline the parser reads as a semicolon ending a
+	// statement.
+	Semicolon
+	// Comment is a comment, from its '#' up to the end of its line.
+	Comment
+)
+
+func (k TriviaKind) String() string {
+	switch k {
+	case Whitespace:
+		return "Whitespace"
+	case Newline:
+		return "Newline"
+	case Semicolon:
+		return "Semicolon"
+	case Comment:
+		return "Comment"
+	}
+	return "TriviaKind(?)"
+}
+
+// Trivia is source text without meaning to the parser, or a newline it
+// reads as a semicolon.
+type Trivia struct {
+	Kind TriviaKind
+	Text string
+	Pos  lexer.Position
+}
+
+// Token is a token of the source written in it, along with the trivia
+// before and after it. Trailing trivia runs up to the end of the token's
+// line, and leading trivia from there up to the token. The last token of a
+// file is an EOF token with empty text.
+type Token struct {
+	Type     lexer.TokenType
+	Text     string
+	Pos      lexer.Position
+	Leading  []Trivia
+	Trailing []Trivia
+}
+
+// EndPos returns the position following the text of the token.
+func (t *Token) EndPos() lexer.Position {
+	pos := t.Pos
+	pos.Offset += len(t.Text)
+	pos.Column += len(t.Text)
+	return pos
+}
+
+// File is the concrete syntax tree of a source file.
+type File struct {
+	Filename string
+	Module   *ast.Module
+	Tokens   []*Token
+}
+
+// Parse parses src into its concrete syntax tree.
+func Parse(filename string, src []byte) (*File, error) {
+	f := ast.ParseSource(filename, src, ast.Options{Comments: true, Features: ast.AllFeatures})
+	if err := f.Err(); err != nil {
+		return nil, err
+	}
+	return &File{
+		Filename: filename,
+		Module:   f.Module,
+		Tokens:   attach(src, f.Tokens),
+	}, nil
+}
+
+// attach returns the tokens of src with trivia attached, from the tokens
+// lexed by the parser.
+func attach(src []byte, lexed []lexer.Token) []*Token {
+	var (
+		tokens  []*Token
+		pending []Trivia
+		last    *Token
+		comment *Trivia
+	)
+	// Trivia on the line of the last token trails it.
+	add := func(trivia Trivia) {
+		if last != nil && len(pending) == 0 && !isNewline(trivia) {
+			last.Trailing = append(last.Trailing, trivia)
+			return
+		}
+		pending = append(pending, trivia)
+	}
+	for i, tok := range lexed {
+		end := len(src)
+		if i+1 < len(lexed) {
+			end = lexed[i+1].Pos.Offset
+		}
+		text := string(src[tok.Pos.Offset:end])
+
+		switch tok.Type {
+		case commentToken:
+			comment = &Trivia{Kind: Comment, Text: text, Pos: tok.Pos}
+			continue
+		case commentTextToken:
+			comment.Text += text
+			continue
+		}
+		if comment != nil {
+			add(*comment)
+			comment = nil
+		}
+
+		switch {
+		case tok.Type == whitespaceToken && text == "\n", tok.Type == commentEndToken:
+			add(Trivia{Kind: Newline, Text: text, Pos: tok.Pos})
+		case tok.Type == whitespaceToken:
+			add(Trivia{Kind: Whitespace, Text: text, Pos: tok.Pos})
+		case tok.Type == ';' && text != ";":
+			add(Trivia{Kind: Semicolon, Text: text, Pos: tok.Pos})
+		default:
+			last = &Token{Type: tok.Type, Text: text, Pos: tok.Pos, Leading: pending}
+			tokens = append(tokens, last)
+			pending = nil
+		}
+	}
+	return tokens
+}
+
+// String returns the source of the file.
+func (f *File) String() string {
+	var sb strings.Builder
+	for _, tok := range f.Tokens {
+		writeTrivia(&sb, tok.Leading)
+		sb.WriteString(tok.Text)
+		writeTrivia(&sb, tok.Trailing)
+	}
+	return sb.String()
+}
+
+func writeTrivia(sb *strings.Builder, trivia []Trivia) {
+	for _, t := range trivia {
+		sb.WriteString(t.Text)
+	}
+}
+
+// Span returns the indices of the first token of node and of the token
+// following its last one, or two equal indices if node spans no tokens,
+// like a comment.
+func (f *File) Span(node ast.Node) (int, int) {
+	start, end := node.Position().Offset, node.EndPosition().Offset
+	i := sort.Search(len(f.Tokens), func(i int) bool {
+		return f.Tokens[i].Pos.Offset >= start
+	})
+	j := sort.Search(len(f.Tokens), func(j int) bool {
+		return f.Tokens[j].EndPos().Offset > end
+	})
+	if j < i {
+		j = i
+	}
+	return i, j
+}
+
+// NodeTokens returns the tokens of node.
+func (f *File) NodeTokens(node ast.Node) []*Token {
+	i, j := f.Span(node)
+	return f.Tokens[i:j]
+}
+
+// Text returns the source of node from its first token to its last one,
+// with the trivia between them but not around them.
+func (f *File) Text(node ast.Node) string {
+	tokens := f.NodeTokens(node)
+	var sb strings.Builder
+	for i, tok := range tokens {
+		if i > 0 {
+			writeTrivia(&sb, tok.Leading)
+		}
+		sb.WriteString(tok.Text)
+		if i+1 < len(tokens) {
+			writeTrivia(&sb, tok.Trailing)
+		}
+	}
+	return sb.String()
+}
+
+// Replace returns an edit replacing the tokens of node with text, keeping
+// the trivia around them.
+func (f *File) Replace(node ast.Node, text string) analysis.TextEdit {
+	tokens := f.NodeTokens(node)
+	if len(tokens) == 0 {
+		pos := node.Position()
+		return analysis.TextEdit{Pos: pos, End: pos, NewText: []byte(text)}
+	}
+	return analysis.TextEdit{
+		Pos:     tokens[0].Pos,
+		End:     tokens[len(tokens)-1].EndPos(),
+		NewText: []byte(text),
+	}
+}
+
+// Delete returns an edit removing the tokens of node. When node is alone on
+// its lines, the lines are removed along with the comment trailing it;
+// otherwise the tokens are removed along with the whitespace separating them
+// from the rest of their line.
+func (f *File) Delete(node ast.Node) analysis.TextEdit {
+	i, j := f.Span(node)
+	if i == j {
+		pos := node.Position()
+		return analysis.TextEdit{Pos: pos, End: pos}
+	}
+	first, last := f.Tokens[i], f.Tokens[j-1]
+	pos, end := first.Pos, last.EndPos()
+
+	// The whitespace before the first token, and whether it starts a line.
+	var indent *Trivia
+	lead := first.Leading
+	if n := len(lead); n > 0 && lead[n-1].Kind == Whitespace {
+		indent, lead = &lead[n-1], lead[:n-1]
+	}
+	startsLine := i == 0 && len(lead) == 0 || len(lead) > 0 && isNewline(lead[len(lead)-1])
+
+	// The trivia after the last token up to the end of its line, and whether
+	// the line ends there.
+	var after []Trivia
+	endsLine := f.Tokens[j].Type == lexer.EOF
+	for _, t := range append(append([]Trivia{}, last.Trailing...), f.Tokens[j].Leading...) {
+		after = append(after, t)
+		if isNewline(t) {
+			endsLine = true
+			break
+		}
+	}
+
+	switch {
+	case startsLine && endsLine:
+		if indent != nil {
+			pos = indent.Pos
+		}
+		if n := len(after); n > 0 {
+			end = after[n-1].Pos
+			end.Offset += len(after[n-1].Text)
+		}
+	case len(after) > 0 && after[0].Kind == Whitespace && (!endsLine || len(after) > 1 && !isNewline(after[1])):
+		end = after[0].Pos
+		end.Offset += len(after[0].Text)
+	case indent != nil && !startsLine:
+		pos = indent.Pos
+	}
+	return analysis.TextEdit{Pos: pos, End: end}
+}
+
+func isNewline(t Trivia) bool {
+	return t.Kind == Newline || t.Kind == Semicolon
+}
@@ -0,0 +1,107 @@
+package cst
+
+import (
+	"os"
+	"testing"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/fix"
+)
+
+const src = `# Builds the binary.
+fun build(string pkg) fs {   # trailing
+	image("golang:alpine");   run("go build", pkg)
+
+	run(<<EOF
+	  go test
+	EOF)
+}
+`
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		src  string
+	}{
+		{"source", src},
+		{"no trailing newline", "fun a() fs {\n\tscratch\n}"},
+	} {
+		f, err := Parse(tc.name, []byte(tc.src))
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if got := f.String(); got != tc.src {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.src)
+		}
+	}
+
+	data, err := os.ReadFile("../build.hlb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := Parse("build.hlb", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.String() != string(data) {
+		t.Error("build.hlb doesn't round trip")
+	}
+}
+
+func TestTrivia(t *testing.T) {
+	f, err := Parse("test.hlb", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := f.Tokens[7]
+	if open.Text != "{" {
+		t.Fatalf("got token %q, want {", open.Text)
+	}
+	if len(open.Trailing) != 2 || open.Trailing[1].Kind != Comment || open.Trailing[1].Text != "# trailing" {
+		t.Errorf("unexpected trailing trivia %+v", open.Trailing)
+	}
+	image := f.Tokens[8]
+	if len(image.Leading) != 2 || image.Leading[0].Kind != Newline || image.Leading[1].Kind != Whitespace {
+		t.Errorf("unexpected leading trivia %+v", image.Leading)
+	}
+	if last := f.Tokens[len(f.Tokens)-1]; len(last.Leading) != 1 || last.Leading[0].Kind != Semicolon {
+		t.Errorf("unexpected trivia before EOF %+v", last.Leading)
+	}
+}
+
+func TestEdits(t *testing.T) {
+	f, err := Parse("test.hlb", []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stmts := f.Module.Decls[0].Func.Body.Stmts
+	if got := f.Text(stmts[1]); got != `image("golang:alpine");` {
+		t.Errorf("got text %q", got)
+	}
+
+	for _, tc := range []struct {
+		name string
+		edit analysis.TextEdit
+		want string
+	}{{
+		"replace",
+		f.Replace(stmts[2].Expr, `run("go vet")`),
+		"# Builds the binary.\nfun build(string pkg) fs {   # trailing\n\timage(\"golang:alpine\");   run(\"go vet\")\n\n\trun(<<EOF\n\t  go test\n\tEOF)\n}\n",
+	}, {
+		"delete inline",
+		f.Delete(stmts[1]),
+		"# Builds the binary.\nfun build(string pkg) fs {   # trailing\n\trun(\"go build\", pkg)\n\n\trun(<<EOF\n\t  go test\n\tEOF)\n}\n",
+	}, {
+		"delete line",
+		f.Delete(stmts[3]),
+		"# Builds the binary.\nfun build(string pkg) fs {   # trailing\n\timage(\"golang:alpine\");   run(\"go build\", pkg)\n\n}\n",
+	}} {
+		got, err := fix.Apply([]byte(src), []analysis.TextEdit{tc.edit})
+		if err != nil {
+			t.Fatalf("%s: %s", tc.name, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Command nolatest is an example of analyzers loaded by `hlb lint` from a Go
+// plugin. It forbids images without a pinned tag or digest, since their
+// contents change under the build.
+//
+// Build and run it with:
+//
+//	go build -buildmode=plugin -o nolatest.so ./examples/nolatest
+//	hlb lint -plugin nolatest.so build.hlb
+package main
+
+import (
+	"strings"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/analysis/passes/resolver"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// Analyzers is looked up by `hlb lint -plugin`.
+var Analyzers = []*analysis.Analyzer{Analyzer}
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "nolatest",
+	Doc:      "forbid unpinned images\n\nImages must be pinned to a tag other than latest, or to a digest.",
+	Requires: []*analysis.Analyzer{resolver.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	info := pass.ResultOf[resolver.Analyzer].(*resolve.Info)
+	ast.Inspect(pass.Module, func(node ast.Node) bool {
+		ref, ok := node.(*ast.Ref)
+		if !ok || ref.Terminal.Ident == nil || ref.Next == nil || ref.Next.Call == nil {
+			return true
+		}
+		obj := info.Uses[ref.Terminal.Ident]
+		if obj == nil || obj.Kind != resolve.Builtin || obj.Name != "image" {
+			return true
+		}
+		args := ref.Next.Call.Args
+		if args == nil || len(args.Exprs) == 0 || args.Exprs[0].Expr == nil {
+			return true
+		}
+		lit := args.Exprs[0].Expr.Unary
+		if lit == nil || lit.Ref.Terminal.Lit == nil || lit.Ref.Terminal.Lit.String == nil {
+			return true
+		}
+		image, ok := lit.Ref.Terminal.Lit.String.Unquoted()
+		if !ok {
+			return true
+		}
+
+		name := image[strings.LastIndex(image, "/")+1:]
+		switch {
+		case strings.Contains(image, "@"):
+		case strings.HasSuffix(name, ":latest"):
+			pass.ReportRangef(lit, "image %s is pinned to latest", image)
+		case !strings.Contains(name, ":"):
+			pass.ReportRangef(lit, "image %s is not pinned to a tag", image)
+		}
+		return true
+	})
+	return nil, nil
+}
+
+func main() {}
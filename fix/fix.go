@@ -0,0 +1,154 @@
+// Package fix applies the text edits of suggested fixes to source.
+package fix
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/format"
+)
+
+// ConflictError is returned when two edits overlap.
+type ConflictError struct {
+	A, B analysis.TextEdit
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflicting edits at %s and %s", e.A.Pos, e.B.Pos)
+}
+
+// Set is a set of non-overlapping edits to a single source.
+type Set struct {
+	edits []analysis.TextEdit
+}
+
+// Add adds all of edits to the set, or none of them if any overlaps an edit
+// already in the set or another of edits. Edits identical to one already in
+// the set are ignored, since fixes for related diagnostics often agree.
+func (s *Set) Add(edits ...analysis.TextEdit) error {
+	merged := append([]analysis.TextEdit{}, s.edits...)
+	for _, edit := range edits {
+		if !contains(merged, edit) {
+			merged = append(merged, edit)
+		}
+	}
+	sortEdits(merged)
+	if err := checkOverlap(merged); err != nil {
+		return err
+	}
+	s.edits = merged
+	return nil
+}
+
+// Edits returns the edits in the set sorted by position.
+func (s *Set) Edits() []analysis.TextEdit {
+	return s.edits
+}
+
+func contains(edits []analysis.TextEdit, edit analysis.TextEdit) bool {
+	for _, e := range edits {
+		if e.Pos.Offset == edit.Pos.Offset && e.End.Offset == edit.End.Offset && bytes.Equal(e.NewText, edit.NewText) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortEdits(edits []analysis.TextEdit) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		if edits[i].Pos.Offset != edits[j].Pos.Offset {
+			return edits[i].Pos.Offset < edits[j].Pos.Offset
+		}
+		return edits[i].End.Offset < edits[j].End.Offset
+	})
+}
+
+// checkOverlap reports edits that overlap in sorted edits. Two insertions at
+// the same position overlap too, since their order is ambiguous.
+func checkOverlap(edits []analysis.TextEdit) error {
+	for i := 1; i < len(edits); i++ {
+		prev, edit := edits[i-1], edits[i]
+		if edit.Pos.Offset < prev.End.Offset || (edit.Pos.Offset == prev.Pos.Offset && edit.Pos.Offset == prev.End.Offset && edit.Pos.Offset == edit.End.Offset) {
+			return &ConflictError{A: prev, B: edit}
+		}
+	}
+	return nil
+}
+
+// Apply applies edits to src. It returns a *ConflictError if any of the
+// edits overlap.
+func Apply(src []byte, edits []analysis.TextEdit) ([]byte, error) {
+	out, _, err := apply(src, edits)
+	return out, err
+}
+
+// region is a range of the edited source.
+type region struct {
+	start, end int
+}
+
+func apply(src []byte, edits []analysis.TextEdit) ([]byte, []region, error) {
+	edits = append([]analysis.TextEdit{}, edits...)
+	sortEdits(edits)
+	if err := checkOverlap(edits); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		out     bytes.Buffer
+		regions []region
+		last    int
+	)
+	for _, edit := range edits {
+		if edit.Pos.Offset < 0 || edit.End.Offset > len(src) || edit.Pos.Offset > edit.End.Offset {
+			return nil, nil, fmt.Errorf("edit at %s is out of range", edit.Pos)
+		}
+		out.Write(src[last:edit.Pos.Offset])
+		start := out.Len()
+		out.Write(edit.NewText)
+		regions = append(regions, region{start, out.Len()})
+		last = edit.End.Offset
+	}
+	out.Write(src[last:])
+	return out.Bytes(), regions, nil
+}
+
+// Fix applies edits to src, checks the result still parses, and formats the
+// top-level declarations that were edited.
+func Fix(filename string, src []byte, edits []analysis.TextEdit) ([]byte, error) {
+	out, regions, err := apply(src, edits)
+	if err != nil {
+		return nil, err
+	}
+
+	f := ast.ParseSource(filename, out, ast.Options{Comments: true, Features: ast.AllFeatures})
+	if err := f.Err(); err != nil {
+		return nil, fmt.Errorf("edits produce invalid source: %w", err)
+	}
+
+	var formats []analysis.TextEdit
+	for _, decl := range f.Module.Decls {
+		if decl.Func == nil && decl.Import == nil {
+			continue
+		}
+		node := ast.Node(decl.Func)
+		if decl.Import != nil {
+			node = decl.Import
+		}
+		start, end := node.Position().Offset, node.EndPosition().Offset
+		for _, r := range regions {
+			if r.start <= end && start <= r.end {
+				formats = append(formats, analysis.TextEdit{
+					Pos:     node.Position(),
+					End:     node.EndPosition(),
+					NewText: []byte(format.String(node)),
+				})
+				break
+			}
+		}
+	}
+	return Apply(out, formats)
+}
@@ -0,0 +1,299 @@
+package fix
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/analysis"
+)
+
+// edit returns an edit replacing src[pos:end] with text.
+func edit(pos, end int, text string) analysis.TextEdit {
+	return analysis.TextEdit{
+		Pos:     lexer.Position{Offset: pos},
+		End:     lexer.Position{Offset: end},
+		NewText: []byte(text),
+	}
+}
+
+func TestSetAdd(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		adds     [][]analysis.TextEdit
+		conflict bool
+		want     []analysis.TextEdit
+	}{{
+		name: "sorted",
+		adds: [][]analysis.TextEdit{
+			{edit(4, 5, "b")},
+			{edit(0, 1, "a"), edit(8, 8, "c")},
+		},
+		want: []analysis.TextEdit{edit(0, 1, "a"), edit(4, 5, "b"), edit(8, 8, "c")},
+	}, {
+		name: "adjacent",
+		adds: [][]analysis.TextEdit{
+			{edit(0, 2, "a")},
+			{edit(2, 4, "b")},
+		},
+		want: []analysis.TextEdit{edit(0, 2, "a"), edit(2, 4, "b")},
+	}, {
+		name: "identical duplicates",
+		adds: [][]analysis.TextEdit{
+			{edit(0, 2, "a"), edit(0, 2, "a")},
+			{edit(0, 2, "a")},
+			{edit(3, 3, "b")},
+			{edit(3, 3, "b")},
+		},
+		want: []analysis.TextEdit{edit(0, 2, "a"), edit(3, 3, "b")},
+	}, {
+		name: "overlapping",
+		adds: [][]analysis.TextEdit{
+			{edit(0, 4, "a")},
+			{edit(2, 6, "b")},
+		},
+		conflict: true,
+		want:     []analysis.TextEdit{edit(0, 4, "a")},
+	}, {
+		name: "conflicting replacements",
+		adds: [][]analysis.TextEdit{
+			{edit(0, 4, "a")},
+			{edit(0, 4, "b")},
+		},
+		conflict: true,
+		want:     []analysis.TextEdit{edit(0, 4, "a")},
+	}, {
+		name: "conflicting insertions",
+		adds: [][]analysis.TextEdit{
+			{edit(2, 2, "a")},
+			{edit(2, 2, "b")},
+		},
+		conflict: true,
+		want:     []analysis.TextEdit{edit(2, 2, "a")},
+	}, {
+		name: "all or none",
+		adds: [][]analysis.TextEdit{
+			{edit(0, 1, "a")},
+			{edit(4, 5, "b"), edit(0, 2, "c")},
+		},
+		conflict: true,
+		want:     []analysis.TextEdit{edit(0, 1, "a")},
+	}, {
+		name: "overlapping within an add",
+		adds: [][]analysis.TextEdit{
+			{edit(4, 8, "a"), edit(6, 7, "b")},
+		},
+		conflict: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				s        Set
+				conflict bool
+			)
+			for _, edits := range tc.adds {
+				err := s.Add(edits...)
+				var cerr *ConflictError
+				if errors.As(err, &cerr) {
+					conflict = true
+				} else if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if conflict != tc.conflict {
+				t.Errorf("got conflict %t, want %t", conflict, tc.conflict)
+			}
+			if got, want := editsString(s.Edits()), editsString(tc.want); got != want {
+				t.Errorf("got edits %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+// editsString returns edits as offsets and new texts.
+func editsString(edits []analysis.TextEdit) string {
+	var parts []string
+	for _, e := range edits {
+		parts = append(parts, fmt.Sprintf("%d:%d:%q", e.Pos.Offset, e.End.Offset, e.NewText))
+	}
+	return "[" + strings.Join(parts, " ") + "]"
+}
+
+func TestCheckOverlap(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		edits    []analysis.TextEdit
+		conflict bool
+	}{{
+		name:  "disjoint",
+		edits: []analysis.TextEdit{edit(0, 1, "a"), edit(2, 3, "b")},
+	}, {
+		name:  "insertion before a replacement",
+		edits: []analysis.TextEdit{edit(2, 2, "a"), edit(2, 4, "b")},
+	}, {
+		name:  "insertion after a replacement",
+		edits: []analysis.TextEdit{edit(0, 2, "a"), edit(2, 2, "b")},
+	}, {
+		name:     "overlapping",
+		edits:    []analysis.TextEdit{edit(0, 3, "a"), edit(2, 4, "b")},
+		conflict: true,
+	}, {
+		name:     "nested",
+		edits:    []analysis.TextEdit{edit(0, 6, "a"), edit(2, 4, "b")},
+		conflict: true,
+	}, {
+		name:     "insertion inside a replacement",
+		edits:    []analysis.TextEdit{edit(0, 4, "a"), edit(2, 2, "b")},
+		conflict: true,
+	}, {
+		name:     "insertions at the same position",
+		edits:    []analysis.TextEdit{edit(2, 2, "a"), edit(2, 2, "b")},
+		conflict: true,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkOverlap(tc.edits)
+			if got := err != nil; got != tc.conflict {
+				t.Errorf("got error %v, want conflict %t", err, tc.conflict)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	const src = "hello, world"
+	for _, tc := range []struct {
+		name  string
+		edits []analysis.TextEdit
+		want  string
+		err   string
+	}{{
+		name: "none",
+		want: src,
+	}, {
+		name:  "unsorted",
+		edits: []analysis.TextEdit{edit(7, 12, "there"), edit(0, 5, "hi")},
+		want:  "hi, there",
+	}, {
+		name:  "insert and delete",
+		edits: []analysis.TextEdit{edit(0, 0, "oh "), edit(5, 6, ""), edit(12, 12, "!")},
+		want:  "oh hello world!",
+	}, {
+		name:  "overlapping",
+		edits: []analysis.TextEdit{edit(0, 5, "hi"), edit(3, 7, "")},
+		err:   "conflicting edits",
+	}, {
+		name:  "past the end",
+		edits: []analysis.TextEdit{edit(7, 13, "there")},
+		err:   "out of range",
+	}, {
+		name:  "negative",
+		edits: []analysis.TextEdit{edit(-1, 2, "")},
+		err:   "out of range",
+	}, {
+		name:  "reversed",
+		edits: []analysis.TextEdit{edit(5, 3, "")},
+		err:   "out of range",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Apply([]byte(src), tc.edits)
+			switch {
+			case tc.err != "":
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("got error %v, want %s", err, tc.err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case string(got) != tc.want:
+				t.Errorf("got %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFix(t *testing.T) {
+	const src = `import  lib from "lib.hlb"
+
+fun a()   fs {
+	image( "alpine" )
+}
+
+fun b()   fs {
+	image( "busybox" )
+}
+`
+	at := func(s string) int { return strings.Index(src, s) }
+	for _, tc := range []struct {
+		name  string
+		edits []analysis.TextEdit
+		want  string
+		err   string
+	}{{
+		name:  "formats edited declaration",
+		edits: []analysis.TextEdit{edit(at("alpine"), at("alpine")+len("alpine"), "debian")},
+		want: `import  lib from "lib.hlb"
+
+fun a() fs {
+	image("debian")
+}
+
+fun b()   fs {
+	image( "busybox" )
+}
+`,
+	}, {
+		name:  "formats edited import",
+		edits: []analysis.TextEdit{edit(at("lib"), at("lib")+len("lib"), "util")},
+		want: `import util from "lib.hlb"
+
+fun a()   fs {
+	image( "alpine" )
+}
+
+fun b()   fs {
+	image( "busybox" )
+}
+`,
+	}, {
+		name: "formats each edited declaration",
+		edits: []analysis.TextEdit{
+			edit(at("alpine"), at("alpine")+len("alpine"), "debian"),
+			edit(at("busybox"), at("busybox")+len("busybox"), "ubuntu"),
+		},
+		want: `import  lib from "lib.hlb"
+
+fun a() fs {
+	image("debian")
+}
+
+fun b() fs {
+	image("ubuntu")
+}
+`,
+	}, {
+		name: "no edits",
+		want: src,
+	}, {
+		name:  "invalid result",
+		edits: []analysis.TextEdit{edit(at("{"), at("{")+1, "")},
+		err:   "edits produce invalid source",
+	}, {
+		name:  "conflicting",
+		edits: []analysis.TextEdit{edit(0, 6, ""), edit(2, 4, "")},
+		err:   "conflicting edits",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Fix("test.hlb", []byte(src), tc.edits)
+			switch {
+			case tc.err != "":
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Errorf("got error %v, want %s", err, tc.err)
+				}
+			case err != nil:
+				t.Fatal(err)
+			case string(got) != tc.want:
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
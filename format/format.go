@@ -0,0 +1,629 @@
+// Package format implements standard formatting of HLB source.
+//
+// The printer makes the layout choices that carry no meaning, such as
+// indentation and spacing, and keeps the ones that do from the positions of
+// the nodes: a single blank line is kept where the source had one or more,
+// parameter and argument lists stay on one line or one per line as written,
+// and comments stay at the end of the line they were written on.
+package format
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+// Source parses src and returns its formatted source.
+func Source(filename string, src []byte) ([]byte, error) {
+	f := ast.ParseSource(filename, src, ast.Options{Comments: true, Features: ast.AllFeatures})
+	if err := f.Err(); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err := Node(&buf, f.Module)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Node writes the formatted source of node to w.
+func Node(w io.Writer, node ast.Node) error {
+	p := &printer{}
+	p.node(node)
+	if _, ok := node.(*ast.Module); ok && p.buf.Len() > 0 {
+		p.buf.WriteByte('\n')
+	}
+	_, err := w.Write(p.buf.Bytes())
+	return err
+}
+
+// String returns the formatted source of node.
+func String(node ast.Node) string {
+	var buf bytes.Buffer
+	_ = Node(&buf, node)
+	return buf.String()
+}
+
+type printer struct {
+	buf    bytes.Buffer
+	indent int
+
+	// pending is true when a newline has been written and the indentation of
+	// the next line hasn't.
+	pending bool
+}
+
+func (p *printer) print(strs ...string) {
+	for _, s := range strs {
+		if s == "" {
+			continue
+		}
+		if p.pending {
+			p.buf.WriteString(strings.Repeat("\t", p.indent))
+			p.pending = false
+		}
+		p.buf.WriteString(s)
+	}
+}
+
+func (p *printer) newline() {
+	p.buf.WriteByte('\n')
+	p.pending = true
+}
+
+// separate starts a new line for a node, keeping a blank line from the
+// source if there was one after the previous line.
+func (p *printer) separate(prevLine int, node ast.Node) {
+	p.newline()
+	if prevLine > 0 && node.Position().Line-prevLine > 1 {
+		p.newline()
+	}
+}
+
+func (p *printer) node(node ast.Node) {
+	switch n := node.(type) {
+	case *ast.Module:
+		p.module(n)
+	case *ast.Decl:
+		p.decl(n)
+	case *ast.ImportDecl:
+		p.importDecl(n)
+	case *ast.FuncDecl:
+		p.funcDecl(n)
+	case *ast.FieldList:
+		p.fieldList(n)
+	case *ast.Field:
+		p.field(n)
+	case *ast.Type:
+		p.print(n.String())
+	case *ast.StmtList:
+		p.stmtList(n)
+	case *ast.Stmt:
+		p.stmt(n)
+	case *ast.Entry:
+		p.entry(n)
+	case *ast.Expr:
+		p.expr(n)
+	case *ast.Unary:
+		p.unary(n)
+	case *ast.Ref:
+		p.ref(n)
+	case *ast.Literal:
+		p.literal(n)
+	case *ast.StringLit:
+		p.stringLit(n)
+	case *ast.ExprList:
+		p.exprList(n)
+	case *ast.Comments:
+		p.comments(n)
+	case *ast.Ident:
+		p.print(n.Text)
+	}
+}
+
+func (p *printer) module(mod *ast.Module) {
+	prevLine := 0
+	if mod.Comments != nil {
+		p.comments(mod.Comments)
+		prevLine = endLine(mod.Comments)
+	}
+
+	var prev *ast.Decl
+	for _, decl := range mod.Decls {
+		if decl.Newline != nil {
+			continue
+		}
+		if prev != nil || prevLine > 0 {
+			p.separate(prevLine, decl)
+			// Functions with a body are always followed by a blank line.
+			if prev != nil && prev.Func != nil && prev.Func.Body != nil && !(decl.Position().Line-prevLine > 1) {
+				p.newline()
+			}
+		}
+		p.decl(decl)
+		prev = decl
+		prevLine = endLine(decl)
+	}
+}
+
+func (p *printer) decl(decl *ast.Decl) {
+	switch {
+	case decl.Import != nil:
+		p.importDecl(decl.Import)
+	case decl.Func != nil:
+		p.funcDecl(decl.Func)
+	case decl.Comments != nil:
+		p.comments(decl.Comments)
+	}
+}
+
+func (p *printer) importDecl(decl *ast.ImportDecl) {
+	p.print("import ", decl.Name.Text, " from ")
+	p.expr(decl.Expr)
+}
+
+func (p *printer) funcDecl(fun *ast.FuncDecl) {
+	for _, mod := range fun.Modifiers {
+		if mod.Public != nil {
+			p.print("pub ")
+		}
+	}
+	p.print("fun ", fun.Name.Text)
+	p.fieldList(fun.Params)
+	p.print(" ", fun.Type.String())
+	if fun.Effects != nil {
+		p.print(" ")
+		p.fieldList(fun.Effects)
+	}
+	if fun.Body != nil {
+		p.print(" ")
+		p.stmtList(fun.Body)
+	}
+}
+
+func (p *printer) fieldList(list *ast.FieldList) {
+	p.print("(")
+	multiline := false
+	for _, stmt := range list.Fields {
+		if stmt.Comments != nil || stmt.Position().Line > list.Position().Line {
+			multiline = true
+		}
+	}
+
+	if !multiline {
+		for i, stmt := range list.Fields {
+			if stmt.Field == nil {
+				continue
+			}
+			if i > 0 {
+				p.print(", ")
+			}
+			p.field(stmt.Field)
+		}
+		p.print(")")
+		return
+	}
+
+	p.indent++
+	prevLine := list.Position().Line
+	for _, stmt := range list.Fields {
+		switch {
+		case stmt.Field != nil:
+			p.separate(prevLine, stmt)
+			p.field(stmt.Field)
+			p.print(",")
+			prevLine = endLine(stmt.Field)
+		case stmt.Comments != nil:
+			prevLine = p.trailingComments(prevLine, stmt.Comments)
+		}
+	}
+	p.indent--
+	p.newline()
+	p.print(")")
+}
+
+func (p *printer) field(field *ast.Field) {
+	p.print(field.Type.String())
+	if field.Variadic != nil {
+		p.print("...")
+	}
+	p.print(" ", field.Name.Text)
+	if field.Default != nil {
+		p.print(" = ")
+		p.unary(field.Default.Unary)
+	}
+}
+
+func (p *printer) stmtList(list *ast.StmtList) {
+	p.print("{")
+	if !hasContent(list.Stmts) {
+		p.print("}")
+		return
+	}
+
+	p.indent++
+	prevLine := list.Position().Line
+	for _, stmt := range list.Stmts {
+		switch {
+		case stmt.Newline != nil:
+			continue
+		case stmt.Comments != nil:
+			prevLine = p.trailingComments(prevLine, stmt.Comments)
+			continue
+		}
+		p.separate(prevLine, stmt)
+		p.stmt(stmt)
+		prevLine = endLine(stmt)
+	}
+	p.indent--
+	p.newline()
+	p.print("}")
+}
+
+// inlineBlock prints a block literal written on a single line as such, unless
+// its statements print on several lines, like if statements do.
+func (p *printer) inlineBlock(list *ast.StmtList) bool {
+	line := list.Position().Line
+	if line == 0 || !hasContent(list.Stmts) || list.CloseBrace.Position().Line != line {
+		return false
+	}
+	for _, stmt := range list.Stmts {
+		if stmt.Comments != nil {
+			return false
+		}
+	}
+
+	inline := &printer{}
+	inline.print("{ ")
+	first := true
+	for _, stmt := range list.Stmts {
+		if stmt.Newline != nil {
+			continue
+		}
+		if !first {
+			inline.print("; ")
+		}
+		inline.stmt(stmt)
+		first = false
+	}
+	inline.print(" }")
+	if bytes.IndexByte(inline.buf.Bytes(), '\n') >= 0 {
+		return false
+	}
+	p.print(inline.buf.String())
+	return true
+}
+
+// trailingComments prints comments that start on prevLine at the end of that
+// line, and the rest on their own lines. It returns the last line printed.
+func (p *printer) trailingComments(prevLine int, comments *ast.Comments) int {
+	for _, c := range comments.Comments {
+		if prevLine > 0 && c.Position().Line == prevLine {
+			p.print(" ")
+		} else {
+			p.separate(prevLine, c)
+		}
+		p.print("#", c.Text)
+		prevLine = c.Position().Line
+	}
+	return prevLine
+}
+
+func (p *printer) comments(comments *ast.Comments) {
+	for i, c := range comments.Comments {
+		if i > 0 {
+			p.separate(comments.Comments[i-1].Position().Line, c)
+		}
+		p.print("#", c.Text)
+	}
+}
+
+func (p *printer) stmt(stmt *ast.Stmt) {
+	switch {
+	case stmt.If != nil:
+		p.ifStmt(stmt.If)
+	case stmt.For != nil:
+		p.forStmt(stmt.For)
+	case stmt.Entry != nil:
+		p.entry(stmt.Entry)
+	case stmt.Expr != nil:
+		p.expr(stmt.Expr)
+	case stmt.Comments != nil:
+		p.comments(stmt.Comments)
+	}
+}
+
+func (p *printer) ifStmt(stmt *ast.IfStmt) {
+	p.print("if (")
+	p.expr(stmt.Condition.Expr)
+	p.print(") ")
+	p.stmtList(stmt.Body)
+	for _, elseIf := range stmt.ElseIfs {
+		p.print(" else if (")
+		p.expr(elseIf.Condition.Expr)
+		p.print(") ")
+		p.stmtList(elseIf.Body)
+	}
+	if stmt.Else != nil {
+		p.print(" else ")
+		p.stmtList(stmt.Else.Body)
+	}
+}
+
+func (p *printer) forStmt(stmt *ast.ForStmt) {
+	p.print("for (")
+	if stmt.Header.Counter != nil {
+		p.print(stmt.Header.Counter.Text, ", ")
+	}
+	p.print(stmt.Header.Var.Text, " in ")
+	p.expr(stmt.Header.Iterable)
+	p.print(") ")
+	p.stmtList(stmt.Body)
+}
+
+func (p *printer) entry(entry *ast.Entry) {
+	for _, key := range entry.Keys {
+		p.print(key.Text, ": ")
+	}
+	p.expr(entry.Value)
+}
+
+func (p *printer) expr(expr *ast.Expr) {
+	if expr.Unary != nil {
+		p.unary(expr.Unary)
+		return
+	}
+	p.expr(expr.Left)
+	p.print(" ", expr.Op.String(), " ")
+	p.expr(expr.Right)
+}
+
+func (p *printer) unary(unary *ast.Unary) {
+	p.print(unary.Op.String())
+	p.ref(unary.Ref)
+}
+
+func (p *printer) ref(ref *ast.Ref) {
+	switch term := ref.Terminal; {
+	case term.Group != nil:
+		p.print("(")
+		p.expr(term.Group.Expr)
+		p.print(")")
+	case term.Lit != nil:
+		p.literal(term.Lit)
+	case term.Ident != nil:
+		p.print(term.Ident.Text)
+	}
+
+	for next := ref.Next; next != nil; next = next.Next {
+		switch {
+		case next.Subscript != nil:
+			p.print("[")
+			if next.Subscript.LeftExpr != nil {
+				p.expr(next.Subscript.LeftExpr)
+			}
+			if next.Subscript.Colon != nil {
+				p.print(":")
+			}
+			if next.Subscript.RightExpr != nil {
+				p.expr(next.Subscript.RightExpr)
+			}
+			p.print("]")
+		case next.Selector != nil:
+			p.print(".", next.Selector.Ident.Text)
+		case next.Call != nil:
+			p.call(next.Call)
+		case next.Splat != nil:
+			p.print("...")
+		}
+	}
+}
+
+func (p *printer) call(call *ast.Call) {
+	if call.Args != nil {
+		p.exprList(call.Args)
+	}
+	if call.At != nil {
+		p.print("@", call.At.Effect.Text)
+	}
+	if call.With != nil {
+		p.print(" with ")
+		p.expr(call.With.Expr)
+	}
+	if call.As != nil {
+		p.print(" as ")
+		p.ref(call.As.Effect)
+	}
+}
+
+func (p *printer) exprList(list *ast.ExprList) {
+	p.print("(")
+	multiline := false
+	for _, stmt := range list.Exprs {
+		if stmt.Comments != nil || stmt.Position().Line > list.Position().Line {
+			multiline = true
+		}
+	}
+
+	if !multiline {
+		first := true
+		for _, stmt := range list.Exprs {
+			if stmt.Newline != nil {
+				continue
+			}
+			if !first {
+				p.print(", ")
+			}
+			p.exprStmt(stmt)
+			first = false
+		}
+		p.print(")")
+		return
+	}
+
+	p.indent++
+	prevLine := list.Position().Line
+	for _, stmt := range list.Exprs {
+		switch {
+		case stmt.Newline != nil:
+			continue
+		case stmt.Comments != nil:
+			prevLine = p.trailingComments(prevLine, stmt.Comments)
+			continue
+		}
+		p.separate(prevLine, stmt)
+		p.exprStmt(stmt)
+		p.print(",")
+		prevLine = endLine(stmt)
+	}
+	p.indent--
+	p.newline()
+	p.print(")")
+}
+
+func (p *printer) exprStmt(stmt *ast.ExprStmt) {
+	switch {
+	case stmt.Entry != nil:
+		p.entry(stmt.Entry)
+	case stmt.Expr != nil:
+		p.expr(stmt.Expr)
+	}
+}
+
+func (p *printer) literal(lit *ast.Literal) {
+	switch {
+	case lit.Block != nil:
+		if lit.Block.Type != nil {
+			p.print(lit.Block.Type.String(), " ")
+		}
+		if !p.inlineBlock(lit.Block.Block) {
+			p.stmtList(lit.Block.Block)
+		}
+	case lit.Decimal != nil:
+		p.print(strconv.Itoa(*lit.Decimal))
+	case lit.Numeric != nil:
+		p.print(numericPrefix[lit.Numeric.Base], strconv.FormatInt(lit.Numeric.Value, lit.Numeric.Base))
+	case lit.Bool != nil:
+		p.print(strconv.FormatBool(*lit.Bool))
+	case lit.String != nil:
+		p.stringLit(lit.String)
+	}
+}
+
+var numericPrefix = map[int]string{
+	2:  "0b",
+	8:  "0o",
+	16: "0x",
+}
+
+func (p *printer) stringLit(lit *ast.StringLit) {
+	switch {
+	case lit.String != nil:
+		p.print(`"`)
+		for _, f := range lit.String.Fragments {
+			switch {
+			case f.Escaped != nil:
+				p.print(*f.Escaped)
+			case f.Interpolated != nil:
+				p.interpolated(f.Interpolated)
+			case f.Text != nil:
+				p.print(*f.Text)
+			}
+		}
+		p.print(`"`)
+	case lit.RawString != nil:
+		p.print("`", lit.RawString.Text, "`")
+	case lit.Heredoc != nil:
+		p.print(lit.Heredoc.Start)
+		p.heredocFragments(lit.Heredoc.Fragments)
+		p.print(lit.Heredoc.End.Text)
+	case lit.RawHeredoc != nil:
+		p.print(lit.RawHeredoc.Start)
+		p.heredocFragments(lit.RawHeredoc.Fragments)
+		p.print(lit.RawHeredoc.End.Text)
+	}
+}
+
+// heredocFragments prints the body of a heredoc verbatim, since its
+// whitespace is part of its value.
+func (p *printer) heredocFragments(fragments []*ast.HeredocFragment) {
+	for _, f := range fragments {
+		switch {
+		case f.Spaces != nil:
+			p.buf.WriteString(*f.Spaces)
+		case f.Escaped != nil:
+			p.buf.WriteString(*f.Escaped)
+		case f.Interpolated != nil:
+			p.interpolated(f.Interpolated)
+		case f.Text != nil:
+			p.buf.WriteString(*f.Text)
+		}
+	}
+}
+
+func (p *printer) interpolated(interp *ast.Interpolated) {
+	p.print("${")
+	if interp.Expr != nil {
+		p.expr(interp.Expr)
+	}
+	p.print("}")
+}
+
+func hasContent(stmts []*ast.Stmt) bool {
+	for _, stmt := range stmts {
+		if stmt.Newline == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// endLine returns the line a node ends on, excluding the statement
+// terminator that wrapper nodes like *ast.Stmt include.
+func endLine(node ast.Node) int {
+	var inner ast.Node
+	switch n := node.(type) {
+	case *ast.Decl:
+		switch {
+		case n.Import != nil:
+			inner = n.Import
+		case n.Func != nil:
+			inner = n.Func
+		case n.Comments != nil:
+			inner = n.Comments
+		}
+	case *ast.Stmt:
+		switch {
+		case n.If != nil:
+			inner = n.If
+		case n.For != nil:
+			inner = n.For
+		case n.Entry != nil:
+			inner = n.Entry
+		case n.Expr != nil:
+			inner = n.Expr
+		case n.Comments != nil:
+			inner = n.Comments
+		}
+	case *ast.ExprStmt:
+		switch {
+		case n.Entry != nil:
+			inner = n.Entry
+		case n.Expr != nil:
+			inner = n.Expr
+		case n.Comments != nil:
+			inner = n.Comments
+		}
+	}
+	if inner != nil {
+		node = inner
+	}
+	if comments, ok := node.(*ast.Comments); ok {
+		return comments.Comments[len(comments.Comments)-1].Position().Line
+	}
+	return node.EndPosition().Line
+}
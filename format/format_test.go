@@ -0,0 +1,138 @@
+package format
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/alecthomas/repr"
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestGolden formats the sources in testdata and compares them with their
+// .golden files. Sources that failed the round trip in FuzzFormat are kept
+// there as regressions. Run with -update to rewrite the golden files.
+func TestGolden(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.hlb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := checkRoundTrip(t, src)
+			if got == nil {
+				t.Fatal("source doesn't parse")
+			}
+
+			golden := path[:len(path)-len(".hlb")] + ".golden"
+			if *update {
+				if err := os.WriteFile(golden, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("got:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+// FuzzFormat checks that any source that parses formats to a source parsing
+// to the same module, which formats to itself.
+func FuzzFormat(f *testing.F) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "*.hlb"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range append(paths, "../bar.hlb", "../build.hlb", "../foo.hlb") {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		checkRoundTrip(t, []byte(src))
+	})
+}
+
+// checkRoundTrip formats src if it parses and checks the formatted source
+// against it, returning the formatted source or nil if src doesn't parse.
+func checkRoundTrip(t *testing.T, src []byte) []byte {
+	f := ast.ParseSource("test.hlb", src, ast.Options{Comments: true, Features: ast.AllFeatures})
+	if f.Err() != nil {
+		return nil
+	}
+	mod := f.Module
+	got := String(mod)
+
+	f = ast.ParseSource("test.hlb", []byte(got), ast.Options{Comments: true, Features: ast.AllFeatures})
+	formatted := f.Module
+	if err := f.Err(); err != nil {
+		t.Fatalf("formatted source doesn't parse: %s\nsource:\n%s\nformatted:\n%s", err, src, got)
+	}
+	if want, got := dump(mod), dump(formatted); want != got {
+		t.Fatalf("formatted module differs\nsource:\n%s\nwant:\n%s\ngot:\n%s", src, want, got)
+	}
+	if again := String(formatted); again != got {
+		t.Fatalf("formatting isn't idempotent\nformatted:\n%s\nformatted again:\n%s", got, again)
+	}
+	return []byte(got)
+}
+
+// dump returns the structure of mod without positions, which change with
+// formatting, nor blank lines, which formatting drops.
+func dump(mod *ast.Module) string {
+	ast.Inspect(mod, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.Module:
+			var decls []*ast.Decl
+			for _, decl := range n.Decls {
+				if decl.Newline == nil {
+					decls = append(decls, decl)
+				}
+			}
+			n.Decls = decls
+		case *ast.StmtList:
+			var stmts []*ast.Stmt
+			for _, stmt := range n.Stmts {
+				if stmt.Newline == nil {
+					stmts = append(stmts, stmt)
+				}
+			}
+			n.Stmts = stmts
+		case *ast.FieldList:
+			var fields []*ast.FieldStmt
+			for _, field := range n.Fields {
+				if field.Newline == nil {
+					fields = append(fields, field)
+				}
+			}
+			n.Fields = fields
+		case *ast.ExprList:
+			var exprs []*ast.ExprStmt
+			for _, expr := range n.Exprs {
+				if expr.Newline == nil {
+					exprs = append(exprs, expr)
+				}
+			}
+			n.Exprs = exprs
+		}
+		return true
+	})
+	return repr.String(mod, repr.Hide(lexer.Position{}))
+}
@@ -0,0 +1,334 @@
+// Package highlight classifies the tokens of a module for syntax
+// highlighting.
+//
+// Tokens are produced by the lexer, so a module is highlighted even if it
+// doesn't parse. When it does, identifiers are classified by what they
+// declare or resolve to, e.g. a parameter that is passed along is still a
+// parameter, and a function referenced without arguments is still a function.
+// Otherwise identifiers are classified by the tokens around them.
+package highlight
+
+import (
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// Class is the syntactic class of a token.
+type Class int
+
+const (
+	Keyword       Class = iota // keyword, modifier or boolean
+	Function                   // function name
+	Parameter                  // function parameter
+	Variable                   // loop variable or unresolved name
+	Namespace                  // import name
+	Type                       // type name
+	Association                // association of a type, e.g. run in option::run
+	Effect                     // function effect
+	String                     // string literal or heredoc
+	Interpolation              // delimiters of an interpolation
+	Number                     // integer literal
+	Comment                    // comment
+	Operator                   // operator
+)
+
+var classNames = [...]string{
+	Keyword:       "keyword",
+	Function:      "function",
+	Parameter:     "parameter",
+	Variable:      "variable",
+	Namespace:     "namespace",
+	Type:          "type",
+	Association:   "association",
+	Effect:        "effect",
+	String:        "string",
+	Interpolation: "interpolation",
+	Number:        "number",
+	Comment:       "comment",
+	Operator:      "operator",
+}
+
+func (c Class) String() string {
+	return classNames[c]
+}
+
+func (c Class) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// Modifier is a set of flags refining the class of a token.
+type Modifier int
+
+const (
+	// Declaration marks the identifier declaring a name.
+	Declaration Modifier = 1 << iota
+
+	// Builtin marks the names of builtin functions.
+	Builtin
+)
+
+// Token is a classified range of source. Tokens never span lines.
+type Token struct {
+	Class     Class
+	Modifiers Modifier
+	Pos       lexer.Position
+	End       lexer.Position
+}
+
+var symbols = ast.Lexer.Symbols()
+
+// classes maps the lexer tokens that are classified by their type alone.
+var classes = map[lexer.TokenType]Class{
+	symbols["Modifier"]:      Keyword,
+	symbols["Keyword"]:       Keyword,
+	symbols["Bool"]:          Keyword,
+	symbols["Numeric"]:       Number,
+	symbols["Decimal"]:       Number,
+	symbols["String"]:        String,
+	symbols["StringEnd"]:     String,
+	symbols["Escaped"]:       String,
+	symbols["Char"]:          String,
+	symbols["RawString"]:     String,
+	symbols["RawStringEnd"]:  String,
+	symbols["RawChar"]:       String,
+	symbols["Heredoc"]:       String,
+	symbols["HeredocEnd"]:    String,
+	symbols["Text"]:          String,
+	symbols["RawHeredoc"]:    String,
+	symbols["RawHeredocEnd"]: String,
+	symbols["RawText"]:       String,
+	symbols["Interpolated"]:  Interpolation,
+	symbols["Operator"]:      Operator,
+	symbols["Comment"]:       Comment,
+	symbols["CommentText"]:   Comment,
+}
+
+// Source parses and resolves src, the source of the module named filename,
+// and returns its tokens.
+func Source(filename, src string) ([]Token, error) {
+	var info *resolve.Info
+	f := ast.ParseSource(filename, []byte(src), ast.Options{Features: ast.AllFeatures})
+	if f.Err() == nil {
+		info = resolve.Resolve(filename, f.Module, nil)
+	} else {
+		f.Module = nil
+	}
+	return Tokens(filename, src, f.Module, info)
+}
+
+// Tokens returns the tokens of src, the source of the module named filename,
+// in order. The module parsed from src and its resolution are used to
+// classify identifiers; either may be nil. If src can't be lexed, the tokens
+// before the error are returned with it.
+func Tokens(filename, src string, mod *ast.Module, info *resolve.Info) ([]Token, error) {
+	lex, err := ast.Scanner.Lex(filename, strings.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+
+	var idents identClasses
+	if mod != nil {
+		idents = classifyIdents(mod, info)
+	}
+
+	var (
+		tokens []lexer.Token
+		lexErr error
+	)
+	for {
+		token, err := lex.Next()
+		if err != nil {
+			lexErr = err
+			break
+		}
+		if token.EOF() {
+			break
+		}
+		if token.Type != symbols["Whitespace"] {
+			tokens = append(tokens, token)
+		}
+	}
+
+	var (
+		result []Token
+		// open are the lexer states that are open, to tell apart the braces
+		// closing interpolations.
+		open []lexer.TokenType
+	)
+	for i, token := range tokens {
+		switch token.Type {
+		case symbols["Brace"], symbols["Interpolated"]:
+			open = append(open, token.Type)
+		case symbols["BraceEnd"]:
+			if len(open) > 0 {
+				if open[len(open)-1] == symbols["Interpolated"] {
+					result = appendToken(result, src, token, Interpolation, 0)
+				}
+				open = open[:len(open)-1]
+			}
+			continue
+		}
+
+		switch {
+		case token.Type == symbols["Ident"]:
+			ic, ok := idents[token.Pos.Offset]
+			if !ok {
+				ic = guessIdent(tokens, i)
+			}
+			result = appendToken(result, src, token, ic.class, ic.modifiers)
+		case token.Value == "@" || token.Value == "." && isSplat(tokens, i):
+			result = appendToken(result, src, token, Operator, 0)
+		default:
+			if class, ok := classes[token.Type]; ok {
+				result = appendToken(result, src, token, class, 0)
+			}
+		}
+	}
+	return result, lexErr
+}
+
+// appendToken appends the token as classified, split into lines and merged
+// with the previous token if it is adjacent and of the same class.
+func appendToken(tokens []Token, src string, token lexer.Token, class Class, modifiers Modifier) []Token {
+	pos := token.Pos
+	text := src[pos.Offset : pos.Offset+len(token.Value)]
+	for _, line := range strings.SplitAfter(text, "\n") {
+		end := pos
+		end.Advance(strings.TrimSuffix(line, "\n"))
+		if end.Offset > pos.Offset {
+			n := len(tokens)
+			if n > 0 && tokens[n-1].End.Offset == pos.Offset && tokens[n-1].End.Line == pos.Line &&
+				tokens[n-1].Class == class && tokens[n-1].Modifiers == modifiers {
+				tokens[n-1].End = end
+			} else {
+				tokens = append(tokens, Token{Class: class, Modifiers: modifiers, Pos: pos, End: end})
+			}
+		}
+		pos.Advance(line)
+	}
+	return tokens
+}
+
+// isSplat reports whether the dot at i is part of an ellipsis.
+func isSplat(tokens []lexer.Token, i int) bool {
+	for j := i - 2; j <= i; j++ {
+		if j >= 0 && j+2 < len(tokens) &&
+			tokens[j].Value == "." && tokens[j+1].Value == "." && tokens[j+2].Value == "." {
+			return true
+		}
+	}
+	return false
+}
+
+type identClass struct {
+	class     Class
+	modifiers Modifier
+}
+
+// identClasses maps the offsets of identifiers to their classes.
+type identClasses map[int]identClass
+
+func (ic identClasses) set(ident *ast.Ident, class Class, modifiers Modifier) {
+	if ident != nil {
+		ic[ident.Pos.Offset] = identClass{class, modifiers}
+	}
+}
+
+// classifyIdents classifies the identifiers of mod from the nodes declaring
+// them and the objects they resolve to.
+func classifyIdents(mod *ast.Module, info *resolve.Info) identClasses {
+	ic := identClasses{}
+	// Identifiers naming called functions, for when they can't be resolved.
+	calls := map[*ast.Ident]bool{}
+
+	ast.Inspect(mod, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.ImportDecl:
+			ic.set(n.Name, Namespace, Declaration)
+		case *ast.FuncDecl:
+			ic.set(n.Name, Function, Declaration)
+			for _, field := range resolve.Fields(n.Params) {
+				ic.set(field.Name, Parameter, Declaration)
+			}
+			for _, field := range resolve.Fields(n.Effects) {
+				ic.set(field.Name, Effect, Declaration)
+			}
+		case *ast.Type:
+			ic.set(n.Scalar, Type, 0)
+		case *ast.Association:
+			ic.set(n.Ident, Association, 0)
+		case *ast.ForHeader:
+			ic.set(n.Counter, Variable, Declaration)
+			ic.set(n.Var, Variable, Declaration)
+		case *ast.AtClause:
+			ic.set(n.Effect, Effect, 0)
+		case *ast.AsClause:
+			if ident := n.Effect.Terminal.Ident; ident != nil && ident.Text == "return" {
+				ic.set(ident, Keyword, 0)
+			}
+		case *ast.Ref:
+			ident := n.Terminal.Ident
+			for next := n.Next; next != nil; next = next.Next {
+				switch {
+				case next.Call != nil && ident != nil:
+					calls[ident] = true
+				case next.Selector != nil:
+					ident = next.Selector.Ident
+					continue
+				}
+				ident = nil
+			}
+		case *ast.Ident:
+			if _, ok := ic[n.Pos.Offset]; ok {
+				break
+			}
+			var obj *resolve.Object
+			if info != nil {
+				obj = info.ObjectOf(n)
+			}
+			switch {
+			case obj != nil:
+				ic[n.Pos.Offset] = objectClass(obj)
+			case calls[n]:
+				ic.set(n, Function, 0)
+			default:
+				ic.set(n, Variable, 0)
+			}
+		}
+		return true
+	})
+	return ic
+}
+
+func objectClass(obj *resolve.Object) identClass {
+	switch obj.Kind {
+	case resolve.Builtin:
+		return identClass{Function, Builtin}
+	case resolve.Func:
+		return identClass{Function, 0}
+	case resolve.Import:
+		return identClass{Namespace, 0}
+	case resolve.Param:
+		return identClass{Parameter, 0}
+	case resolve.Effect:
+		return identClass{Effect, 0}
+	}
+	return identClass{Variable, 0}
+}
+
+// guessIdent classifies the identifier at i from the tokens around it, for
+// modules that don't parse.
+func guessIdent(tokens []lexer.Token, i int) identClass {
+	switch {
+	case i > 0 && tokens[i-1].Value == "fun":
+		return identClass{Function, Declaration}
+	case i > 1 && tokens[i-1].Value == ":" && tokens[i-2].Value == ":":
+		return identClass{Association, 0}
+	case i+1 < len(tokens) && tokens[i+1].Type == symbols["Paren"]:
+		return identClass{Function, 0}
+	}
+	return identClass{Variable, 0}
+}
@@ -0,0 +1,96 @@
+package highlight
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	src := `# builds
+pub fun build(string pkg) fs (string digest) {
+	image("golang:${pkg}")
+	run("go build", pkg) with {
+		mount(scratch, "/out") as digest
+	}
+	node
+	dockerPush("ref")@digest
+	mkfile("f", 0o644, <<~EOF
+		a
+	EOF)
+}
+
+fun node() fs
+`
+	tokens, err := Source("test.hlb", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, token := range tokens {
+		s := fmt.Sprintf("%s %s", token.Class, src[token.Pos.Offset:token.End.Offset])
+		if token.Modifiers&Declaration != 0 {
+			s += " decl"
+		}
+		if token.Modifiers&Builtin != 0 {
+			s += " builtin"
+		}
+		got = append(got, s)
+	}
+
+	want := []string{
+		"comment # builds",
+		"keyword pub",
+		"keyword fun",
+		"function build decl",
+		"type string",
+		"parameter pkg decl",
+		"type fs",
+		"type string",
+		"effect digest decl",
+		"function image builtin",
+		`string "golang:`,
+		"interpolation ${",
+		"parameter pkg",
+		"interpolation }",
+		`string "`,
+		"function run builtin",
+		`string "go build"`,
+		"parameter pkg",
+		"keyword with",
+		"function mount builtin",
+		"function scratch builtin",
+		`string "/out"`,
+		"keyword as",
+		"effect digest",
+		"function node",
+		"function dockerPush builtin",
+		`string "ref"`,
+		"operator @",
+		"effect digest",
+		"function mkfile builtin",
+		`string "f"`,
+		"number 0o644",
+		"string <<~EOF",
+		"string a",
+		"string EOF",
+		"keyword fun",
+		"function node decl",
+		"type fs",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got tokens:\n%s\n\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSourceSyntaxError(t *testing.T) {
+	src := "fun build() fs {\n\timage(\"golang\" +)\n\trun(\"x\")\n}\n"
+	tokens, err := Source("test.hlb", src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 8 || tokens[1].Class != Function || tokens[3].Class != Function || tokens[6].Class != Function {
+		t.Errorf("unexpected tokens %+v", tokens)
+	}
+}
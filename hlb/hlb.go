@@ -2,9 +2,7 @@ package hlb
 
 import (
 	"fmt"
-	"strings"
 
-	participle "github.com/alecthomas/participle/v2"
 	"github.com/hinshun/hlb-parser/ast"
 )
 
@@ -80,7 +78,7 @@ func (id *importDecl) toDecl() *ast.Decl {
 
 type funcDecl struct {
 	name    string
-	export  bool
+	public  bool
 	params  []*ast.FieldStmt
 	returns *ast.Type
 	effects []*ast.FieldStmt
@@ -91,8 +89,8 @@ func Func(name string) *funcDecl {
 	return &funcDecl{name: name}
 }
 
-func (fd *funcDecl) Export() *funcDecl {
-	fd.export = true
+func (fd *funcDecl) Public() *funcDecl {
+	fd.public = true
 	return fd
 }
 
@@ -129,9 +127,7 @@ func (fd *funcDecl) Params(nodes ...interface{}) *funcDecl {
 }
 
 func (fd *funcDecl) Returns(t string) *funcDecl {
-	fd.returns = &ast.Type{
-		Scalar: &ast.Ident{Text: t},
-	}
+	fd.returns = astType(t)
 	return fd
 }
 
@@ -142,13 +138,13 @@ func (fd *funcDecl) Body(nodes ...interface{}) *funcDecl {
 
 func (fd *funcDecl) toDecl() *ast.Decl {
 	fun := &ast.FuncDecl{
-		Func: &ast.Func{Text: "func"},
+		Func: &ast.Func{Text: "fun"},
 		Name: &ast.Ident{Text: fd.name},
 		Type: fd.returns,
 	}
-	if fd.export {
+	if fd.public {
 		fun.Modifiers = append(fun.Modifiers, &ast.Modifier{
-			Export: &ast.Export{Text: "export"},
+			Public: &ast.Public{Text: "pub"},
 		})
 	}
 	if len(fd.params) > 0 {
@@ -214,8 +210,12 @@ func (i *ident) With(node interface{}) *ident {
 
 func (i *ident) As(s string) *ident {
 	i.as = &ast.AsClause{
-		As:     &ast.As{Text: "as"},
-		Effect: &ast.Ident{Text: s},
+		As: &ast.As{Text: "as"},
+		Effect: &ast.Ref{
+			Terminal: &ast.Terminal{
+				Ident: &ast.Ident{Text: s},
+			},
+		},
 	}
 	return i
 }
@@ -289,28 +289,15 @@ func parseLiteral(str string) *ast.Expr {
 }
 
 func _parseLiteral(str string) (*ast.Expr, error) {
-	parser := participle.MustBuild(
-		&ast.Literal{},
-		participle.Lexer(ast.Lexer),
-	)
-
-	lit := &ast.Literal{}
-	err := parser.Parse("", strings.NewReader(str), lit)
+	lit, err := ast.ParseLiteral(str)
 	if err != nil {
 		return nil, err
 	}
-
 	return literalExpr(lit), nil
 }
 
 func astType(str string) *ast.Type {
-	parser := participle.MustBuild(
-		&ast.Type{},
-		participle.Lexer(ast.Lexer),
-	)
-
-	t := &ast.Type{}
-	err := parser.Parse("", strings.NewReader(str), t)
+	t, err := ast.ParseType(str)
 	if err != nil {
 		panic(err)
 	}
@@ -329,7 +316,7 @@ func Comments(cs ...string) *ast.Stmt {
 	var comments []*ast.Comment
 	for _, c := range cs {
 		comments = append(comments, &ast.Comment{
-			Text: fmt.Sprintf("# %s\n", c),
+			Text: " " + c,
 		})
 	}
 	return &ast.Stmt{Comments: &ast.Comments{Comments: comments}}
@@ -350,16 +337,13 @@ func (a *array) Items(nodes ...interface{}) *array {
 }
 
 func (a *array) toExpr() *ast.Expr {
-	lit := &ast.ArrayLit{
-		Type: &ast.Ident{Text: a.t},
-	}
-	if len(a.items) > 0 {
-		lit.Block = &ast.StmtList{
-			Stmts: a.items,
-		}
-	}
 	return literalExpr(&ast.Literal{
-		ArrayLit: lit,
+		Block: &ast.BlockLit{
+			Type: astType(a.t),
+			Block: &ast.StmtList{
+				Stmts: a.items,
+			},
+		},
 	})
 }
 
@@ -0,0 +1,229 @@
+// Package index indexes the declarations of modules and the sites that refer
+// to them.
+//
+// Declarations are identified by the position of their identifier, so that
+// indexes built from the resolutions of different modules agree on modules
+// that both load, and references found by each are merged.
+package index
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// Symbol is a declaration in a module.
+type Symbol struct {
+	Name string
+
+	// Kind is Func or Import for top-level symbols, and Param or Effect for
+	// their children.
+	Kind   resolve.Kind
+	Public bool
+
+	// Decl is the declaring node and Ident the identifier naming it.
+	Decl  ast.Node
+	Ident *ast.Ident
+
+	// Children are the parameters followed by the effects of a function.
+	Children []*Symbol
+
+	// Refs are the identifiers referring to the symbol, sorted by position.
+	Refs []*ast.Ident
+}
+
+// Filename returns the name of the file declaring the symbol.
+func (s *Symbol) Filename() string {
+	return s.Ident.Pos.Filename
+}
+
+// Index is an index of the symbols of a set of modules.
+type Index struct {
+	// Files are the indexed files, in the order they were loaded.
+	Files []string
+
+	modules map[string][]*Symbol
+	symbols map[key]*Symbol
+	refs    map[key]map[key]*ast.Ident
+}
+
+// key identifies an identifier by its position.
+type key struct {
+	filename string
+	offset   int
+}
+
+func keyOf(ident *ast.Ident) key {
+	return key{ident.Pos.Filename, ident.Pos.Offset}
+}
+
+// Build indexes the modules loaded by each resolution, except builtins.
+func Build(infos ...*resolve.Info) *Index {
+	idx := &Index{
+		modules: make(map[string][]*Symbol),
+		symbols: make(map[key]*Symbol),
+		refs:    make(map[key]map[key]*ast.Ident),
+	}
+	for _, info := range infos {
+		for _, mod := range info.Modules {
+			if _, ok := idx.modules[mod.Filename]; !ok {
+				idx.addModule(mod)
+			}
+		}
+		for ident, obj := range info.Uses {
+			if obj.Module == resolve.Universe {
+				continue
+			}
+			k := keyOf(obj.Ident)
+			if idx.refs[k] == nil {
+				idx.refs[k] = make(map[key]*ast.Ident)
+			}
+			idx.refs[k][keyOf(ident)] = ident
+		}
+	}
+	for k, sym := range idx.symbols {
+		sym.Refs = idx.references(k)
+	}
+	return idx
+}
+
+func (idx *Index) addModule(mod *resolve.Module) {
+	idx.Files = append(idx.Files, mod.Filename)
+	symbols := []*Symbol{}
+	for _, decl := range mod.AST.Decls {
+		switch {
+		case decl.Import != nil:
+			symbols = append(symbols, idx.add(&Symbol{
+				Name:  decl.Import.Name.Text,
+				Kind:  resolve.Import,
+				Decl:  decl.Import,
+				Ident: decl.Import.Name,
+			}))
+		case decl.Func != nil:
+			fun := decl.Func
+			sym := idx.add(&Symbol{
+				Name:   fun.Name.Text,
+				Kind:   resolve.Func,
+				Public: resolve.IsPublic(fun),
+				Decl:   fun,
+				Ident:  fun.Name,
+			})
+			for _, field := range resolve.Fields(fun.Params) {
+				sym.Children = append(sym.Children, idx.add(fieldSymbol(resolve.Param, field)))
+			}
+			for _, field := range resolve.Fields(fun.Effects) {
+				sym.Children = append(sym.Children, idx.add(fieldSymbol(resolve.Effect, field)))
+			}
+			symbols = append(symbols, sym)
+		}
+	}
+	idx.modules[mod.Filename] = symbols
+}
+
+func fieldSymbol(kind resolve.Kind, field *ast.Field) *Symbol {
+	return &Symbol{
+		Name:  field.Name.Text,
+		Kind:  kind,
+		Decl:  field,
+		Ident: field.Name,
+	}
+}
+
+func (idx *Index) add(sym *Symbol) *Symbol {
+	idx.symbols[keyOf(sym.Ident)] = sym
+	return sym
+}
+
+// Symbols returns the top-level symbols of a file in the order they are
+// declared.
+func (idx *Index) Symbols(filename string) []*Symbol {
+	return idx.modules[filename]
+}
+
+// Lookup returns the symbol of obj, or nil if it isn't a function, import,
+// parameter or effect of an indexed module.
+func (idx *Index) Lookup(obj *resolve.Object) *Symbol {
+	return idx.symbols[keyOf(obj.Ident)]
+}
+
+// References returns the identifiers referring to obj sorted by position. Unlike
+// the refs of symbols, they include the references to loop variables.
+func (idx *Index) References(obj *resolve.Object) []*ast.Ident {
+	return idx.references(keyOf(obj.Ident))
+}
+
+func (idx *Index) references(k key) []*ast.Ident {
+	var refs []*ast.Ident
+	for _, ident := range idx.refs[k] {
+		refs = append(refs, ident)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return less(refs[i].Pos, refs[j].Pos)
+	})
+	return refs
+}
+
+func less(a, b lexer.Position) bool {
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	return a.Offset < b.Offset
+}
+
+// Search returns the top-level symbols whose names fuzzily match query, best
+// matches first. Every character of the query must appear in the name in
+// order, ignoring case. Exact matches rank before prefixes, prefixes before
+// substrings, and substrings before scattered matches. An empty query
+// matches every symbol.
+func (idx *Index) Search(query string) []*Symbol {
+	type match struct {
+		sym   *Symbol
+		score int
+	}
+	var matches []match
+	for _, filename := range idx.Files {
+		for _, sym := range idx.modules[filename] {
+			if score, ok := fuzzy(query, sym.Name); ok {
+				matches = append(matches, match{sym, score})
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score < b.score
+		}
+		if len(a.sym.Name) != len(b.sym.Name) {
+			return len(a.sym.Name) < len(b.sym.Name)
+		}
+		return a.sym.Name < b.sym.Name
+	})
+	symbols := make([]*Symbol, len(matches))
+	for i, m := range matches {
+		symbols[i] = m.sym
+	}
+	return symbols
+}
+
+// fuzzy scores how well name matches query, lower is better.
+func fuzzy(query, name string) (int, bool) {
+	q, n := strings.ToLower(query), strings.ToLower(name)
+	switch {
+	case q == n:
+		return 0, true
+	case strings.HasPrefix(n, q):
+		return 1, true
+	case strings.Contains(n, q):
+		return 2, true
+	}
+	i := 0
+	for j := 0; j < len(n) && i < len(q); j++ {
+		if n[j] == q[i] {
+			i++
+		}
+	}
+	return 3, i == len(q)
+}
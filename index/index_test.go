@@ -0,0 +1,85 @@
+package index
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+func TestIndex(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.hlb": "pub fun build(string pkg) fs (string digest) {\n\timage(pkg)\n}\n",
+		"a.hlb":  "import go from \"./go.hlb\"\n\nfun test() fs {\n\tgo.build(\"a\")\n}\n",
+		"b.hlb":  "import go from \"./go.hlb\"\n\nfun bundle() fs {\n\tgo.build(\"b\")\n\tgo.build(\"c\")\n}\n",
+	}
+	var infos []*resolve.Info
+	for _, name := range []string{"go.hlb", "a.hlb", "b.hlb"} {
+		filename := filepath.Join(dir, name)
+		if err := os.WriteFile(filename, []byte(files[name]), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		f := ast.ParseSource(filename, []byte(files[name]), ast.Options{Comments: true, Features: ast.AllFeatures})
+		if err := f.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if name != "go.hlb" {
+			infos = append(infos, resolve.Resolve(filename, f.Module, resolve.FileImporter{}))
+		}
+	}
+
+	idx := Build(infos...)
+	if len(idx.Files) != 3 {
+		t.Fatalf("got files %v, want a.hlb, go.hlb and b.hlb", idx.Files)
+	}
+
+	symbols := idx.Symbols(filepath.Join(dir, "go.hlb"))
+	if len(symbols) != 1 {
+		t.Fatalf("got symbols %+v, want build", symbols)
+	}
+	build := symbols[0]
+	if build.Name != "build" || build.Kind != resolve.Func || !build.Public || len(build.Children) != 2 {
+		t.Errorf("unexpected symbol %+v", build)
+	}
+	if pkg := build.Children[0]; pkg.Kind != resolve.Param || len(pkg.Refs) != 1 {
+		t.Errorf("unexpected param %+v", pkg)
+	}
+	// The references from both modules loading go.hlb are merged.
+	if len(build.Refs) != 3 {
+		t.Errorf("got %d references to build, want 3", len(build.Refs))
+	}
+
+	var names []string
+	for _, sym := range idx.Search("bu") {
+		names = append(names, sym.Name)
+	}
+	if got := strings.Join(names, ","); got != "build,bundle" {
+		t.Errorf("got search results %s, want build,bundle", got)
+	}
+	if got := idx.Search("tst"); len(got) != 1 || got[0].Name != "test" {
+		t.Errorf("got fuzzy search results %+v, want test", got)
+	}
+
+	var buf bytes.Buffer
+	if err := idx.WriteCtags(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "build\t" + filepath.Join(dir, "go.hlb") + "\t1;\"\tf\tline:1\taccess:public\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("ctags missing %q:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	if err := idx.WriteEtags(&buf, os.ReadFile); err != nil {
+		t.Fatal(err)
+	}
+	want = "\x0c\n" + filepath.Join(dir, "go.hlb") + ",24\npub fun build\x7fbuild\x011,0\n"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("etags missing %q:\n%q", want, buf.String())
+	}
+}
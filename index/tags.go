@@ -0,0 +1,69 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// tagKinds are the single letter kinds of top-level symbols in tags files.
+var tagKinds = map[resolve.Kind]string{
+	resolve.Func:   "f",
+	resolve.Import: "i",
+}
+
+// WriteCtags writes the top-level symbols as a sorted ctags file in the
+// extended format, addressing each symbol by line.
+func (idx *Index) WriteCtags(w io.Writer) error {
+	var symbols []*Symbol
+	for _, filename := range idx.Files {
+		symbols = append(symbols, idx.modules[filename]...)
+	}
+	sort.SliceStable(symbols, func(i, j int) bool {
+		return symbols[i].Name < symbols[j].Name
+	})
+
+	var buf bytes.Buffer
+	buf.WriteString("!_TAG_FILE_FORMAT\t2\t/extended format/\n")
+	buf.WriteString("!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/\n")
+	buf.WriteString("!_TAG_PROGRAM_NAME\thlb\t//\n")
+	for _, sym := range symbols {
+		fmt.Fprintf(&buf, "%s\t%s\t%d;\"\t%s\tline:%d", sym.Name, sym.Filename(), sym.Ident.Pos.Line, tagKinds[sym.Kind], sym.Ident.Pos.Line)
+		if sym.Public {
+			buf.WriteString("\taccess:public")
+		}
+		buf.WriteString("\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteEtags writes the top-level symbols as an etags file. Since etags
+// locate symbols by the text preceding them, the source of each file is read
+// with readFile.
+func (idx *Index) WriteEtags(w io.Writer, readFile func(filename string) ([]byte, error)) error {
+	var buf bytes.Buffer
+	for _, filename := range idx.Files {
+		src, err := readFile(filename)
+		if err != nil {
+			return err
+		}
+
+		var section bytes.Buffer
+		for _, sym := range idx.modules[filename] {
+			pos, end := sym.Ident.Pos, sym.Ident.EndPos
+			if end.Offset > len(src) {
+				return fmt.Errorf("%s: source changed since it was indexed", pos)
+			}
+			start := bytes.LastIndexByte(src[:pos.Offset], '\n') + 1
+			fmt.Fprintf(&section, "%s\x7f%s\x01%d,%d\n", src[start:end.Offset], sym.Name, pos.Line, start)
+		}
+		fmt.Fprintf(&buf, "\x0c\n%s,%d\n", filename, section.Len())
+		buf.Write(section.Bytes())
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
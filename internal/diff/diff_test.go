@@ -0,0 +1,124 @@
+package diff
+
+import "testing"
+
+func TestUnified(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		a, b string
+		want string
+	}{{
+		name: "equal",
+		a:    "a\nb\n",
+		b:    "a\nb\n",
+	}, {
+		name: "empty",
+	}, {
+		name: "insert only",
+		a:    "a\nb\n",
+		b:    "a\nx\ny\nb\n",
+		want: `--- a
++++ b
+@@ -1,2 +1,4 @@
+ a
++x
++y
+ b
+`,
+	}, {
+		name: "insert into empty",
+		b:    "x\n",
+		want: `--- a
++++ b
+@@ -0,0 +1 @@
++x
+`,
+	}, {
+		name: "delete only",
+		a:    "a\nx\nb\n",
+		b:    "a\nb\n",
+		want: `--- a
++++ b
+@@ -1,3 +1,2 @@
+ a
+-x
+ b
+`,
+	}, {
+		name: "delete all",
+		a:    "x\ny\n",
+		want: `--- a
++++ b
+@@ -1,2 +0,0 @@
+-x
+-y
+`,
+	}, {
+		name: "no trailing newline",
+		a:    "a\nb",
+		b:    "a\nc",
+		want: `--- a
++++ b
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++c
+\ No newline at end of file
+`,
+	}, {
+		name: "trailing newline added",
+		a:    "a",
+		b:    "a\n",
+		want: `--- a
++++ b
+@@ -1 +1 @@
+-a
+\ No newline at end of file
++a
+`,
+	}, {
+		name: "context merged",
+		a:    "1\n2\n3\n4\n5\n6\n7\n8\n",
+		b:    "1\nx\n3\n4\n5\n6\ny\n8\n",
+		want: `--- a
++++ b
+@@ -1,8 +1,8 @@
+ 1
+-2
++x
+ 3
+ 4
+ 5
+ 6
+-7
++y
+ 8
+`,
+	}, {
+		name: "separate hunks",
+		a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+		b:    "x\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ny\n",
+		want: `--- a
++++ b
+@@ -1,4 +1,4 @@
+-1
++x
+ 2
+ 3
+ 4
+@@ -9,4 +9,4 @@
+ 9
+ 10
+ 11
+-12
++y
+`,
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Unified("a", "b", []byte(tc.a), []byte(tc.b)); got != tc.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
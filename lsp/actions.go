@@ -0,0 +1,62 @@
+package lsp
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/hinshun/hlb-parser/refactor"
+)
+
+var codeActionKinds = []string{
+	string(refactor.QuickFix),
+	string(refactor.Extract),
+	string(refactor.Inline),
+	string(refactor.Rewrite),
+}
+
+func (s *Server) codeAction(params json.RawMessage) (interface{}, error) {
+	var p CodeActionParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	actions := []CodeAction{}
+	if !ok || doc.Info == nil {
+		return actions, nil
+	}
+
+	start, end := doc.Offset(p.Range.Start), doc.Offset(p.Range.End)
+	for _, action := range refactor.Actions([]byte(doc.Text), doc.Module, doc.Info, start, end) {
+		if !kindRequested(string(action.Kind), p.Context.Only) {
+			continue
+		}
+		var edits []TextEdit
+		for _, edit := range action.Edits {
+			edits = append(edits, TextEdit{
+				Range:   doc.Range(edit.Pos, edit.End),
+				NewText: string(edit.NewText),
+			})
+		}
+		actions = append(actions, CodeAction{
+			Title: action.Title,
+			Kind:  string(action.Kind),
+			Edit:  &WorkspaceEdit{Changes: map[string][]TextEdit{doc.URI: edits}},
+		})
+	}
+	return actions, nil
+}
+
+// kindRequested reports whether a code action of kind was requested by a
+// client asking for the kinds in only, where "refactor" includes
+// "refactor.extract".
+func kindRequested(kind string, only []string) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, prefix := range only {
+		if kind == prefix || strings.HasPrefix(kind, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
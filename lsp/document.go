@@ -0,0 +1,249 @@
+package lsp
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/analysis/checker"
+	"github.com/hinshun/hlb-parser/analysis/passes"
+	"github.com/hinshun/hlb-parser/analysis/passes/names"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// document is an open text document and the result of checking it.
+type document struct {
+	URI      string
+	Filename string
+	Version  int
+	Text     string
+
+	// lines are the offsets of the start of each line.
+	lines []int
+
+	// Module is nil if the document couldn't be parsed.
+	Module *ast.Module
+	Info   *resolve.Info
+
+	Diagnostics []Diagnostic
+}
+
+func newDocument(uri string, version int, text string) *document {
+	doc := &document{
+		URI:      uri,
+		Filename: uriToFilename(uri),
+		Version:  version,
+	}
+	doc.setText(text)
+	doc.check(nil)
+	return doc
+}
+
+// changeDocument returns prev changed to text. Its module is reparsed from the
+// one of prev, which can't be used afterwards.
+func changeDocument(prev *document, version int, text string) *document {
+	doc := &document{
+		URI:      prev.URI,
+		Filename: prev.Filename,
+		Version:  version,
+	}
+	doc.setText(text)
+	doc.check(prev)
+	return doc
+}
+
+func (doc *document) setText(text string) {
+	doc.Text = text
+	doc.lines = []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			doc.lines = append(doc.lines, i+1)
+		}
+	}
+}
+
+// check parses, resolves and analyzes the document. The declarations of
+// prev untouched by the change to the document are reused if it was parsed.
+func (doc *document) check(prev *document) {
+	doc.Diagnostics = []Diagnostic{}
+
+	var (
+		mod *ast.Module
+		err error
+	)
+	if prev != nil && prev.Module != nil {
+		src := []byte(doc.Text)
+		mod, err = ast.Reparse(prev.Module, src, ast.Diff([]byte(prev.Text), src))
+		if err != nil {
+			doc.Diagnostics = append(doc.Diagnostics, doc.errorDiagnostic(err))
+			return
+		}
+	} else {
+		f := ast.ParseSource(doc.Filename, []byte(doc.Text), ast.Options{Comments: true, Features: ast.AllFeatures})
+		for _, d := range f.Diagnostics {
+			doc.Diagnostics = append(doc.Diagnostics, doc.syntaxDiagnostic(d))
+		}
+		if f.Module == nil {
+			return
+		}
+		mod = f.Module
+	}
+	doc.Module = mod
+	doc.Info = resolve.Resolve(doc.Filename, mod, resolve.FileImporter{})
+
+	res, err := checker.AnalyzeModule(doc.Filename, []byte(doc.Text), mod, passes.Analyzers)
+	if err != nil {
+		doc.Diagnostics = append(doc.Diagnostics, doc.errorDiagnostic(err))
+		return
+	}
+	for _, d := range res.Diagnostics {
+		severity := SeverityWarning
+		if d.Analyzer == names.Analyzer {
+			severity = SeverityError
+		}
+		doc.Diagnostics = append(doc.Diagnostics, Diagnostic{
+			Range:    doc.Range(d.Pos, d.End),
+			Severity: severity,
+			Code:     d.Code,
+			Source:   d.Analyzer.Name,
+			Message:  d.Message,
+		})
+	}
+}
+
+// errorDiagnostic converts a syntax error to a diagnostic.
+func (doc *document) errorDiagnostic(err error) Diagnostic {
+	return doc.syntaxDiagnostic(ast.SyntaxDiagnostic([]byte(doc.Text), err))
+}
+
+// syntaxDiagnostic converts d, a diagnostic of the parser.
+func (doc *document) syntaxDiagnostic(d *ast.Diagnostic) Diagnostic {
+	diag := Diagnostic{
+		Severity: SeverityError,
+		Code:     d.Code,
+		Source:   "syntax",
+		Message:  d.Message,
+	}
+	if d.Hint != "" {
+		diag.Message += "\nhint: " + d.Hint
+	}
+	if d.Pos.Line > 0 {
+		diag.Range = doc.Range(d.Pos, d.End)
+	}
+	return diag
+}
+
+// Offset returns the byte offset of pos, clamped to the document.
+func (doc *document) Offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(doc.lines) {
+		return len(doc.Text)
+	}
+	offset := doc.lines[pos.Line]
+	for units := 0; units < pos.Character && offset < len(doc.Text); {
+		r, size := utf8.DecodeRuneInString(doc.Text[offset:])
+		if r == '\n' {
+			break
+		}
+		units += len(utf16.Encode([]rune{r}))
+		offset += size
+	}
+	return offset
+}
+
+// Position returns the protocol position of pos.
+func (doc *document) Position(pos lexer.Position) Position {
+	offset := pos.Offset
+	if offset > len(doc.Text) {
+		offset = len(doc.Text)
+	}
+	line := sort.Search(len(doc.lines), func(i int) bool {
+		return doc.lines[i] > offset
+	}) - 1
+	return Position{
+		Line:      line,
+		Character: utf16Len(doc.Text[doc.lines[line]:offset]),
+	}
+}
+
+// Range returns the protocol range between two positions.
+func (doc *document) Range(start, end lexer.Position) Range {
+	return Range{Start: doc.Position(start), End: doc.Position(end)}
+}
+
+// NodeRange returns the protocol range of node.
+func (doc *document) NodeRange(node ast.Node) Range {
+	return doc.Range(node.Position(), node.EndPosition())
+}
+
+// PathEnclosing returns the nodes enclosing pos, innermost first.
+func (doc *document) PathEnclosing(pos Position) []ast.Node {
+	if doc.Module == nil {
+		return nil
+	}
+	return ast.PathEnclosing(doc.Module, doc.Offset(pos))
+}
+
+// IdentAt returns the identifier at pos, or just before it as when the cursor
+// is at the end of a word, or nil if there isn't one.
+func (doc *document) IdentAt(pos Position) *ast.Ident {
+	if doc.Module == nil {
+		return nil
+	}
+	offset := doc.Offset(pos)
+	for _, o := range []int{offset, offset - 1} {
+		path := ast.PathEnclosing(doc.Module, o)
+		if len(path) == 0 {
+			continue
+		}
+		if ident, ok := path[0].(*ast.Ident); ok {
+			return ident
+		}
+	}
+	return nil
+}
+
+func uriToFilename(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func filenameToURI(filename string) string {
+	if strings.Contains(filename, "://") {
+		return filename
+	}
+	abs, err := filepath.Abs(filename)
+	if err == nil {
+		filename = abs
+	}
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(filename)}
+	return u.String()
+}
+
+// readDocument reads a document that isn't open from the filesystem. Unlike
+// open documents, it isn't checked.
+func readDocument(uri, filename string) (*document, error) {
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	doc := &document{URI: uri, Filename: filename}
+	doc.setText(string(text))
+	return doc, nil
+}
+
+// utf16Len returns the number of UTF-16 code units encoding s.
+func utf16Len(s string) int {
+	return len(utf16.Encode([]rune(s)))
+}
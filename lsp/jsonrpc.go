@@ -0,0 +1,146 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeInternalError  = -32603
+
+	// codeRequestFailed is defined by the Language Server Protocol for
+	// requests that are valid but fail.
+	codeRequestFailed = -32803
+)
+
+// maxContentLength is the length of the largest message read by default.
+const maxContentLength = 64 << 20
+
+// message is a JSON-RPC 2.0 request, notification or response. Requests have
+// an ID and a method, notifications only a method and responses only an ID.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *rpcError        `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// conn reads and writes messages framed by a Content-Length header, as
+// specified by the base protocol.
+type conn struct {
+	r *textproto.Reader
+
+	// maxLength is the length of the largest message read. The bodies of
+	// larger messages are skipped without being read into memory.
+	maxLength int
+
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{
+		r:         textproto.NewReader(bufio.NewReader(r)),
+		w:         w,
+		maxLength: maxContentLength,
+	}
+}
+
+// read returns the next message. Messages that can't be decoded are skipped
+// and returned as an *rpcError to reply with, as the next message can still
+// be read.
+func (c *conn) read() (*message, error) {
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length: %w", err)
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length: %d", length)
+	}
+	if length > c.maxLength {
+		_, err = io.CopyN(io.Discard, c.r.R, int64(length))
+		if err != nil {
+			return nil, err
+		}
+		return nil, &rpcError{Code: codeInvalidRequest, Message: fmt.Sprintf("message of %d bytes exceeds the limit of %d bytes", length, c.maxLength)}
+	}
+	body := make([]byte, length)
+	_, err = io.ReadFull(c.r.R, body)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &message{}
+	err = json.Unmarshal(body, msg)
+	if err != nil {
+		return nil, &rpcError{Code: codeParseError, Message: err.Error()}
+	}
+	return msg, nil
+}
+
+func (c *conn) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
+
+// nullID is the ID of the replies to messages whose ID can't be read.
+var nullID = json.RawMessage("null")
+
+func (c *conn) reply(id *json.RawMessage, result interface{}, err error) error {
+	msg := &message{ID: id}
+	if err != nil {
+		rerr, ok := err.(*rpcError)
+		if !ok {
+			rerr = &rpcError{Code: codeInternalError, Message: err.Error()}
+		}
+		msg.Error = rerr
+	} else {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = data
+	}
+	return c.write(msg)
+}
+
+func (c *conn) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{Method: method, Params: data})
+}
@@ -0,0 +1,101 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/rename"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+func (s *Server) prepareRename(params json.RawMessage) (interface{}, error) {
+	doc, pos, err := s.document(params)
+	if err != nil {
+		return nil, err
+	}
+	ident, obj := objectAt(doc, pos)
+	if obj == nil {
+		return nil, nil
+	}
+	if obj.Kind == resolve.Builtin || obj.Module == resolve.Universe {
+		return nil, &rpcError{Code: codeRequestFailed, Message: fmt.Sprintf("cannot rename builtin %s", obj.Name)}
+	}
+	return doc.NodeRange(ident), nil
+}
+
+func (s *Server) rename(params json.RawMessage) (interface{}, error) {
+	doc, pos, err := s.document(params)
+	if err != nil {
+		return nil, err
+	}
+	var p RenameParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	ident := doc.IdentAt(pos)
+	if ident == nil || doc.Info == nil {
+		return nil, &rpcError{Code: codeRequestFailed, Message: "no identifier to rename"}
+	}
+
+	importers, err := s.importers(doc)
+	if err != nil {
+		return nil, err
+	}
+	edits, err := rename.Rename(doc.Info, ident, p.NewName, importers)
+	if err != nil {
+		return nil, &rpcError{Code: codeRequestFailed, Message: err.Error()}
+	}
+	result := &WorkspaceEdit{Changes: make(map[string][]TextEdit)}
+	for filename, fileEdits := range edits {
+		target, err := s.documentOf(filename)
+		if err != nil {
+			return nil, err
+		}
+		for _, edit := range fileEdits {
+			result.Changes[target.URI] = append(result.Changes[target.URI], TextEdit{
+				Range:   target.Range(edit.Pos, edit.End),
+				NewText: string(edit.NewText),
+			})
+		}
+	}
+	return result, nil
+}
+
+// importers resolves the modules that may import the one of doc: the other
+// open documents, and the modules in the workspace folders that aren't open.
+// It returns nil without workspace folders, as importers could be anywhere.
+func (s *Server) importers(doc *document) ([]*resolve.Info, error) {
+	if len(s.folders) == 0 {
+		return nil, nil
+	}
+	infos := []*resolve.Info{}
+	open := make(map[string]bool)
+	for _, other := range s.docs {
+		open[other.Filename] = true
+		if other != doc && other.Info != nil {
+			infos = append(infos, other.Info)
+		}
+	}
+	for _, folder := range s.folders {
+		err := filepath.WalkDir(folder, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() || filepath.Ext(path) != ".hlb" || open[path] {
+				return err
+			}
+			f, err := ast.ParseFile(path, ast.Options{Recover: true, Features: ast.AllFeatures})
+			if err != nil {
+				return err
+			}
+			if f.Module != nil {
+				infos = append(infos, resolve.Resolve(path, f.Module, resolve.FileImporter{}))
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
@@ -0,0 +1,105 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hinshun/hlb-parser/highlight"
+)
+
+// semanticTypes maps token classes to the standard semantic token types,
+// whose indices in the legend encode them.
+var semanticTypes = []struct {
+	class highlight.Class
+	name  string
+}{
+	{highlight.Keyword, "keyword"},
+	{highlight.Function, "function"},
+	{highlight.Parameter, "parameter"},
+	{highlight.Variable, "variable"},
+	{highlight.Namespace, "namespace"},
+	{highlight.Type, "type"},
+	{highlight.Association, "typeParameter"},
+	{highlight.Effect, "property"},
+	{highlight.String, "string"},
+	{highlight.Interpolation, "macro"},
+	{highlight.Number, "number"},
+	{highlight.Comment, "comment"},
+	{highlight.Operator, "operator"},
+}
+
+// semanticModifiers maps token modifiers to the standard semantic token
+// modifiers, whose bits in the legend encode them.
+var semanticModifiers = []struct {
+	modifier highlight.Modifier
+	name     string
+}{
+	{highlight.Declaration, "declaration"},
+	{highlight.Builtin, "defaultLibrary"},
+}
+
+var semanticLegend = newSemanticLegend()
+
+func newSemanticLegend() SemanticTokensLegend {
+	legend := SemanticTokensLegend{
+		TokenTypes:     []string{},
+		TokenModifiers: []string{},
+	}
+	for _, t := range semanticTypes {
+		legend.TokenTypes = append(legend.TokenTypes, t.name)
+	}
+	for _, m := range semanticModifiers {
+		legend.TokenModifiers = append(legend.TokenModifiers, m.name)
+	}
+	return legend
+}
+
+func (s *Server) semanticTokens(params json.RawMessage) (interface{}, error) {
+	var p SemanticTokensParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("document not open: %s", p.TextDocument.URI)}
+	}
+
+	// Tokens up to a lexing error are still highlighted, and the error is
+	// already published as a diagnostic.
+	tokens, _ := highlight.Tokens(doc.Filename, doc.Text, doc.Module, doc.Info)
+	return &SemanticTokens{Data: encodeTokens(doc, tokens)}, nil
+}
+
+// encodeTokens encodes tokens relative to each other as the protocol
+// requires.
+func encodeTokens(doc *document, tokens []highlight.Token) []uint32 {
+	types := make(map[highlight.Class]uint32)
+	for i, t := range semanticTypes {
+		types[t.class] = uint32(i)
+	}
+
+	data := []uint32{}
+	var prev Position
+	for _, token := range tokens {
+		rng := doc.Range(token.Pos, token.End)
+		start := rng.Start.Character
+		if rng.Start.Line == prev.Line {
+			start -= prev.Character
+		}
+		var modifiers uint32
+		for i, m := range semanticModifiers {
+			if token.Modifiers&m.modifier != 0 {
+				modifiers |= 1 << i
+			}
+		}
+		data = append(data,
+			uint32(rng.Start.Line-prev.Line),
+			uint32(start),
+			uint32(rng.End.Character-rng.Start.Character),
+			types[token.Class],
+			modifiers,
+		)
+		prev = rng.Start
+	}
+	return data
+}
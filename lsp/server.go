@@ -0,0 +1,415 @@
+// Package lsp implements a Language Server Protocol server for HLB.
+//
+// The server keeps the open documents in memory, publishing the syntax errors
+// and analyzer diagnostics of a document whenever it is opened or changed, and
+// answers hover and definition requests from the resolved module.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/completion"
+	"github.com/hinshun/hlb-parser/format"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// Server is a language server communicating over a single stream.
+type Server struct {
+	conn *conn
+	docs map[string]*document
+
+	// folders are the directories of the workspace folders, or of the root
+	// of the workspace for clients without folders.
+	folders []string
+
+	shutdown bool
+}
+
+// NewServer returns a server reading requests from r and writing responses
+// and notifications to w.
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		conn: newConn(r, w),
+		docs: make(map[string]*document),
+	}
+}
+
+// handler handles the params of a method, returning the result of requests.
+type handler func(s *Server, params json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":                       (*Server).initialize,
+	"initialized":                      nil,
+	"shutdown":                         (*Server).shutdownRequest,
+	"textDocument/didOpen":             (*Server).didOpen,
+	"textDocument/didChange":           (*Server).didChange,
+	"textDocument/didClose":            (*Server).didClose,
+	"textDocument/hover":               (*Server).hover,
+	"textDocument/definition":          (*Server).definition,
+	"textDocument/completion":          (*Server).completion,
+	"textDocument/signatureHelp":       (*Server).signatureHelp,
+	"textDocument/semanticTokens/full": (*Server).semanticTokens,
+	"textDocument/prepareRename":       (*Server).prepareRename,
+	"textDocument/rename":              (*Server).rename,
+	"textDocument/references":          (*Server).references,
+	"textDocument/documentSymbol":      (*Server).documentSymbol,
+	"textDocument/codeAction":          (*Server).codeAction,
+	"textDocument/foldingRange":        (*Server).foldingRange,
+	"textDocument/selectionRange":      (*Server).selectionRange,
+	"textDocument/documentLink":        (*Server).documentLink,
+	"workspace/symbol":                 (*Server).workspaceSymbol,
+}
+
+// errExit is returned by the exit notification to stop the server.
+var errExit = errors.New("exit")
+
+// Run serves requests until the exit notification is received, the input is
+// closed or ctx is done. It returns an error if the client exits without a
+// shutdown request.
+func (s *Server) Run(ctx context.Context) error {
+	msgs := make(chan *message)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := s.conn.read()
+			var rerr *rpcError
+			if errors.As(err, &rerr) {
+				err = s.conn.reply(&nullID, nil, rerr)
+				if err == nil {
+					continue
+				}
+			}
+			if err != nil {
+				errs <- err
+				return
+			}
+			msgs <- msg
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-errs:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case msg := <-msgs:
+			err := s.handle(msg)
+			if err == errExit {
+				if !s.shutdown {
+					return fmt.Errorf("exit without shutdown")
+				}
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *Server) handle(msg *message) error {
+	if msg.Method == "exit" {
+		return errExit
+	}
+	// Responses to requests sent by the server are ignored.
+	if msg.Method == "" {
+		return nil
+	}
+
+	h, ok := handlers[msg.Method]
+	var (
+		result interface{}
+		err    error
+	)
+	switch {
+	case !ok:
+		err = &rpcError{Code: codeMethodNotFound, Message: fmt.Sprintf("method not found: %s", msg.Method)}
+	case h != nil:
+		result, err = h(s, msg.Params)
+	}
+
+	if msg.ID == nil {
+		// Notifications have no response, so errors for unknown
+		// notifications are dropped as the protocol requires.
+		if rerr, ok := err.(*rpcError); ok && rerr.Code == codeMethodNotFound {
+			return nil
+		}
+		return err
+	}
+	return s.conn.reply(msg.ID, result, err)
+}
+
+func unmarshal(params json.RawMessage, v interface{}) error {
+	err := json.Unmarshal(params, v)
+	if err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (interface{}, error) {
+	var p InitializeParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	for _, folder := range p.WorkspaceFolders {
+		s.folders = append(s.folders, uriToFilename(folder.URI))
+	}
+	if len(s.folders) == 0 && p.RootURI != "" {
+		s.folders = append(s.folders, uriToFilename(p.RootURI))
+	}
+
+	return &InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:   SyncFull,
+			HoverProvider:      true,
+			DefinitionProvider: true,
+			CompletionProvider: &CompletionOptions{
+				TriggerCharacters: []string{".", "@"},
+			},
+			SignatureHelpProvider: &SignatureHelpOptions{
+				TriggerCharacters: []string{"(", ","},
+			},
+			SemanticTokensProvider: &SemanticTokensOptions{
+				Legend: semanticLegend,
+				Full:   true,
+			},
+			RenameProvider:          &RenameOptions{PrepareProvider: true},
+			ReferencesProvider:      true,
+			DocumentSymbolProvider:  true,
+			WorkspaceSymbolProvider: true,
+			CodeActionProvider:      &CodeActionOptions{CodeActionKinds: codeActionKinds},
+			FoldingRangeProvider:    true,
+			SelectionRangeProvider:  true,
+			DocumentLinkProvider:    &DocumentLinkOptions{},
+		},
+		ServerInfo: &ServerInfo{Name: "hlb-lsp"},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(params json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) (interface{}, error) {
+	var p DidOpenTextDocumentParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	return nil, s.update(newDocument(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text))
+}
+
+func (s *Server) didChange(params json.RawMessage) (interface{}, error) {
+	var p DidChangeTextDocumentParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil, nil
+	}
+	text := p.ContentChanges[len(p.ContentChanges)-1].Text
+	if prev, ok := s.docs[p.TextDocument.URI]; ok {
+		return nil, s.update(changeDocument(prev, p.TextDocument.Version, text))
+	}
+	return nil, s.update(newDocument(p.TextDocument.URI, p.TextDocument.Version, text))
+}
+
+func (s *Server) didClose(params json.RawMessage) (interface{}, error) {
+	var p DidCloseTextDocumentParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	delete(s.docs, p.TextDocument.URI)
+	return nil, s.conn.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+// update stores doc and publishes its diagnostics.
+func (s *Server) update(doc *document) error {
+	s.docs[doc.URI] = doc
+	return s.conn.notify("textDocument/publishDiagnostics", &PublishDiagnosticsParams{
+		URI:         doc.URI,
+		Version:     doc.Version,
+		Diagnostics: doc.Diagnostics,
+	})
+}
+
+// document returns the open document at the position in params.
+func (s *Server) document(params json.RawMessage) (*document, Position, error) {
+	var p TextDocumentPositionParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, Position{}, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, Position{}, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("document not open: %s", p.TextDocument.URI)}
+	}
+	return doc, p.Position, nil
+}
+
+// documentOf returns the document of filename. Modules that aren't open, like
+// the imports of open documents, are read to convert positions.
+func (s *Server) documentOf(filename string) (*document, error) {
+	for _, doc := range s.docs {
+		if doc.Filename == filename {
+			return doc, nil
+		}
+	}
+	return readDocument(filenameToURI(filename), filename)
+}
+
+// objectAt returns the identifier at pos and the object it declares or
+// refers to.
+func objectAt(doc *document, pos Position) (*ast.Ident, *resolve.Object) {
+	ident := doc.IdentAt(pos)
+	if ident == nil || doc.Info == nil {
+		return nil, nil
+	}
+	return ident, doc.Info.ObjectOf(ident)
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	doc, pos, err := s.document(params)
+	if err != nil {
+		return nil, err
+	}
+	ident, obj := objectAt(doc, pos)
+	if obj == nil {
+		return nil, nil
+	}
+	rng := doc.NodeRange(ident)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: describe(obj)},
+		Range:    &rng,
+	}, nil
+}
+
+// describe renders the declaration of obj and its documentation as
+// markdown.
+func describe(obj *resolve.Object) string {
+	var (
+		decl string
+		doc  string
+	)
+	switch obj.Kind {
+	case resolve.Builtin, resolve.Func:
+		fun := *obj.FuncDecl()
+		fun.Body = nil
+		decl = format.String(&fun)
+		doc = obj.Module.AST.Doc(obj.FuncDecl())
+	case resolve.Import:
+		decl = format.String(obj.Decl.(*ast.ImportDecl))
+	case resolve.Param, resolve.Effect:
+		decl = fmt.Sprintf("%s # %s of %s", format.String(obj.Decl.(*ast.Field)), obj.Kind, obj.Func.Name.Text)
+	case resolve.Var:
+		decl = fmt.Sprintf("%s # loop variable", obj.Name)
+	}
+
+	value := fmt.Sprintf("```hlb\n%s\n```", decl)
+	if doc != "" {
+		value += "\n\n" + doc
+	}
+	return value
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	doc, pos, err := s.document(params)
+	if err != nil {
+		return nil, err
+	}
+	_, obj := objectAt(doc, pos)
+	if obj == nil || obj.Kind == resolve.Builtin || obj.Module == resolve.Universe {
+		return nil, nil
+	}
+
+	target, err := s.documentOf(obj.Ident.Pos.Filename)
+	if err != nil {
+		return nil, err
+	}
+	return []Location{{
+		URI:   target.URI,
+		Range: target.NodeRange(obj.Ident),
+	}}, nil
+}
+
+var completionKinds = map[completion.Kind]CompletionItemKind{
+	completion.Function: CompletionFunction,
+	completion.Builtin:  CompletionFunction,
+	completion.Variable: CompletionVariable,
+	completion.Effect:   CompletionField,
+	completion.Module:   CompletionModule,
+	completion.Keyword:  CompletionKeyword,
+	completion.Type:     CompletionTypeParameter,
+}
+
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	doc, pos, err := s.document(params)
+	if err != nil {
+		return nil, err
+	}
+	list := completion.Complete(doc.Filename, doc.Text, doc.Offset(pos), resolve.FileImporter{})
+
+	// Items replace the word at the cursor, so that clients don't need to
+	// agree with the server on what a word is.
+	rng := doc.Range(lexer.Position{Offset: list.Start}, lexer.Position{Offset: list.End})
+	result := &CompletionList{Items: []CompletionItem{}}
+	for i, item := range list.Items {
+		ci := CompletionItem{
+			Label:            item.Label,
+			Kind:             completionKinds[item.Kind],
+			Detail:           item.Detail,
+			SortText:         fmt.Sprintf("%04d", i),
+			InsertTextFormat: SnippetFormat,
+			TextEdit:         &TextEdit{Range: rng, NewText: item.Insert},
+		}
+		if item.Doc != "" {
+			ci.Documentation = &MarkupContent{Kind: "markdown", Value: item.Doc}
+		}
+		result.Items = append(result.Items, ci)
+	}
+	return result, nil
+}
+
+func (s *Server) signatureHelp(params json.RawMessage) (interface{}, error) {
+	doc, pos, err := s.document(params)
+	if err != nil {
+		return nil, err
+	}
+	sig := completion.SignatureHelp(doc.Filename, doc.Text, doc.Offset(pos), resolve.FileImporter{})
+	if sig == nil {
+		return nil, nil
+	}
+
+	info := SignatureInformation{Label: sig.Label, Parameters: []ParameterInformation{}}
+	if sig.Doc != "" {
+		info.Documentation = &MarkupContent{Kind: "markdown", Value: sig.Doc}
+	}
+	for _, param := range sig.Params {
+		start := utf16Len(sig.Label[:param.Start])
+		info.Parameters = append(info.Parameters, ParameterInformation{
+			Label: [2]int{start, start + utf16Len(sig.Label[param.Start:param.End])},
+		})
+	}
+	// An active parameter outside the parameters highlights none of them.
+	active := sig.Active
+	if active < 0 {
+		active = len(sig.Params)
+	}
+	return &SignatureHelp{
+		Signatures:      []SignatureInformation{info},
+		ActiveParameter: active,
+	}, nil
+}
@@ -0,0 +1,435 @@
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/lexer"
+)
+
+// client drives a server over pipes, as an editor would.
+type client struct {
+	t      *testing.T
+	conn   *conn
+	server *Server
+	id     int
+	done   chan error
+}
+
+func startServer(t *testing.T) *client {
+	t.Helper()
+	clientR, serverW := io.Pipe()
+	serverR, clientW := io.Pipe()
+
+	c := &client{
+		t:      t,
+		conn:   newConn(clientR, clientW),
+		server: NewServer(serverR, serverW),
+		done:   make(chan error, 1),
+	}
+	go func() {
+		c.done <- c.server.Run(context.Background())
+		serverW.Close()
+	}()
+	return c
+}
+
+// call sends a request and returns its result, skipping notifications.
+func (c *client) call(method string, params, result interface{}) {
+	c.t.Helper()
+	if err := c.request(method, params, result); err != nil {
+		c.t.Fatalf("%s: %s", method, err.Message)
+	}
+}
+
+// request sends a request and returns its error, or its result in result.
+func (c *client) request(method string, params, result interface{}) *rpcError {
+	c.t.Helper()
+	c.id++
+	id := json.RawMessage(strconv.Itoa(c.id))
+	data, err := json.Marshal(params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	err = c.conn.write(&message{ID: &id, Method: method, Params: data})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for {
+		msg := c.read()
+		if msg.ID == nil {
+			continue
+		}
+		if string(*msg.ID) != string(id) {
+			c.t.Fatalf("got response to %s, want %s", *msg.ID, id)
+		}
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			err = json.Unmarshal(msg.Result, result)
+			if err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	err := c.conn.notify(method, params)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// diagnostics waits for the diagnostics published for uri.
+func (c *client) diagnostics(uri string) []Diagnostic {
+	c.t.Helper()
+	for {
+		msg := c.read()
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var p PublishDiagnosticsParams
+		err := json.Unmarshal(msg.Params, &p)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+		if p.URI == uri {
+			return p.Diagnostics
+		}
+	}
+}
+
+// send writes body framed as a message, whether or not it's valid.
+func (c *client) send(body string) {
+	c.t.Helper()
+	_, err := fmt.Fprintf(c.conn.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// readError reads the reply to a message that couldn't be decoded, whose ID
+// is null.
+func (c *client) readError(code int) {
+	c.t.Helper()
+	msg := c.read()
+	if msg.ID != nil || msg.Error == nil || msg.Error.Code != code {
+		c.t.Fatalf("got %+v, want error %d with a null ID", msg, code)
+	}
+}
+
+func (c *client) read() *message {
+	c.t.Helper()
+	msg, err := c.conn.read()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+const testSource = `# build compiles the binary.
+fun build() fs {
+	image("golang")
+	run("go build")
+}
+
+fun test(string pkg) fs {
+	build()
+	run(pkg)
+	missing()
+}
+`
+
+// positionOf returns the position of the nth occurrence of substr in
+// testSource.
+func positionOf(t *testing.T, substr string, n int) Position {
+	t.Helper()
+	offset := -1
+	for i := 0; i < n; i++ {
+		next := strings.Index(testSource[offset+1:], substr)
+		if next < 0 {
+			t.Fatalf("%q not found", substr)
+		}
+		offset += next + 1
+	}
+	doc := &document{}
+	doc.setText(testSource)
+	return doc.Position(lexer.Position{Offset: offset})
+}
+
+func TestServer(t *testing.T) {
+	c := startServer(t)
+	uri := filenameToURI(filepath.Join(t.TempDir(), "test.hlb"))
+
+	var init InitializeResult
+	c.call("initialize", &InitializeParams{}, &init)
+	if !init.Capabilities.HoverProvider || !init.Capabilities.DefinitionProvider || init.Capabilities.CompletionProvider == nil {
+		t.Fatalf("missing capabilities: %+v", init.Capabilities)
+	}
+	c.notify("initialized", struct{}{})
+
+	c.notify("textDocument/didOpen", &DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: uri, LanguageID: "hlb", Version: 1, Text: testSource},
+	})
+	diags := c.diagnostics(uri)
+	if len(diags) != 1 || diags[0].Message != "undefined: missing" {
+		t.Fatalf("got diagnostics %+v, want undefined: missing", diags)
+	}
+	if want := positionOf(t, "missing", 1); diags[0].Range.Start != want {
+		t.Errorf("got diagnostic at %+v, want %+v", diags[0].Range.Start, want)
+	}
+
+	var hover Hover
+	c.call("textDocument/hover", &TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     positionOf(t, "build", 4),
+	}, &hover)
+	if !strings.Contains(hover.Contents.Value, "fun build() fs") || !strings.Contains(hover.Contents.Value, "build compiles the binary.") {
+		t.Errorf("unexpected hover %q", hover.Contents.Value)
+	}
+
+	var locs []Location
+	c.call("textDocument/definition", &TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     positionOf(t, "pkg", 2),
+	}, &locs)
+	want := Range{Start: positionOf(t, "pkg", 1), End: positionOf(t, "pkg", 1)}
+	want.End.Character += len("pkg")
+	if len(locs) != 1 || locs[0].URI != uri || locs[0].Range != want {
+		t.Errorf("got definition %+v, want %s %+v", locs, uri, want)
+	}
+
+	var list CompletionList
+	pos := positionOf(t, "image", 1)
+	pos.Character += len("ima")
+	c.call("textDocument/completion", &TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     pos,
+	}, &list)
+	if len(list.Items) != 1 || list.Items[0].TextEdit.NewText != "image(${1:ref})" {
+		t.Fatalf("got completions %+v, want image", list.Items)
+	}
+	if got := list.Items[0].TextEdit.Range.Start; got != positionOf(t, "image", 1) {
+		t.Errorf("got completion replacing from %+v, want the start of the word", got)
+	}
+
+	var help SignatureHelp
+	c.call("textDocument/signatureHelp", &TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     positionOf(t, "pkg", 2),
+	}, &help)
+	if len(help.Signatures) != 1 || help.ActiveParameter != 0 {
+		t.Fatalf("got signature help %+v, want run with its first parameter active", help)
+	}
+	if sig := help.Signatures[0]; !strings.HasPrefix(sig.Label, "run(") || len(sig.Parameters) == 0 {
+		t.Errorf("got signature %+v, want run", sig)
+	}
+
+	var actions []CodeAction
+	pos = positionOf(t, "missing", 1)
+	c.call("textDocument/codeAction", &CodeActionParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Range:        Range{Start: pos, End: pos},
+		Context:      CodeActionContext{Only: []string{"quickfix"}},
+	}, &actions)
+	if len(actions) != 1 || actions[0].Title != "Add function missing" || len(actions[0].Edit.Changes[uri]) != 1 {
+		t.Fatalf("got code actions %+v, want to add missing", actions)
+	}
+	if got := actions[0].Edit.Changes[uri][0].NewText; got != "\n\nfun missing() fs {}" {
+		t.Errorf("got stub %q", got)
+	}
+
+	var tokens SemanticTokens
+	c.call("textDocument/semanticTokens/full", &SemanticTokensParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+	}, &tokens)
+	// The first tokens are the doc comment and fun keyword on the next line.
+	if len(tokens.Data)%5 != 0 || len(tokens.Data) < 10 {
+		t.Fatalf("got semantic tokens %v", tokens.Data)
+	}
+	comment := []uint32{0, 0, uint32(len("# build compiles the binary.")), 11, 0}
+	keyword := []uint32{1, 0, uint32(len("fun")), 0, 0}
+	if got := tokens.Data[:10]; fmt.Sprint(got) != fmt.Sprint(append(comment, keyword...)) {
+		t.Errorf("got first semantic tokens %v, want %v %v", got, comment, keyword)
+	}
+
+	var edit WorkspaceEdit
+	c.call("textDocument/rename", &RenameParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     positionOf(t, "build", 4),
+		NewName:      "compile",
+	}, &edit)
+	if edits := edit.Changes[uri]; len(edits) != 2 || edits[0].NewText != "compile" || edits[1].Range.Start != positionOf(t, "build", 4) {
+		t.Errorf("got rename edits %+v", edit.Changes)
+	}
+
+	var refs []Location
+	c.call("textDocument/references", &ReferenceParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Position:     positionOf(t, "pkg", 1),
+		Context:      ReferenceContext{IncludeDeclaration: true},
+	}, &refs)
+	if len(refs) != 2 || refs[1].Range.Start != positionOf(t, "pkg", 2) {
+		t.Errorf("got references %+v", refs)
+	}
+
+	var outline []DocumentSymbol
+	c.call("textDocument/documentSymbol", &DocumentSymbolParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+	}, &outline)
+	if len(outline) != 2 || outline[1].Name != "test" || outline[1].Detail != "(string pkg) fs" ||
+		len(outline[1].Children) != 1 || outline[1].Children[0].Name != "pkg" {
+		t.Errorf("got document symbols %+v", outline)
+	}
+
+	var symbols []SymbolInformation
+	c.call("workspace/symbol", &WorkspaceSymbolParams{Query: "bld"}, &symbols)
+	if len(symbols) != 1 || symbols[0].Name != "build" || symbols[0].Location.URI != uri {
+		t.Errorf("got workspace symbols %+v", symbols)
+	}
+
+	var folds []FoldingRange
+	c.call("textDocument/foldingRange", &FoldingRangeParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+	}, &folds)
+	if len(folds) != 2 || folds[0] != (FoldingRange{StartLine: 1, EndLine: 3}) {
+		t.Errorf("got folding ranges %+v, want the bodies of build and test", folds)
+	}
+
+	var selections []SelectionRange
+	c.call("textDocument/selectionRange", &SelectionRangeParams{
+		TextDocument: TextDocumentIdentifier{URI: uri},
+		Positions:    []Position{positionOf(t, "pkg", 2)},
+	}, &selections)
+	if len(selections) != 1 || selections[0].Range.Start != positionOf(t, "pkg", 2) {
+		t.Fatalf("got selection ranges %+v, want pkg first", selections)
+	}
+	var steps []Range
+	for sr := &selections[0]; sr != nil; sr = sr.Parent {
+		steps = append(steps, sr.Range)
+	}
+	if run := steps[len(steps)-4]; run.Start != positionOf(t, "run(pkg)", 1) || run.End.Character != run.Start.Character+len("run(pkg)") {
+		t.Errorf("got selection steps %+v, want run(pkg) before the body", steps)
+	}
+
+	importURI := "file:///tmp/imports.hlb"
+	c.notify("textDocument/didOpen", &DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: importURI, LanguageID: "hlb", Version: 1, Text: "import go from image(\"openllb/go.hlb:latest\")\nimport util from \"./util.hlb\"\n"},
+	})
+	c.diagnostics(importURI)
+	var links []DocumentLink
+	c.call("textDocument/documentLink", &DocumentLinkParams{
+		TextDocument: TextDocumentIdentifier{URI: importURI},
+	}, &links)
+	if len(links) != 2 || links[0].Target != "https://hub.docker.com/r/openllb/go.hlb" || links[1].Target != "file:///tmp/util.hlb" {
+		t.Errorf("got document links %+v", links)
+	}
+	if got := links[1].Range; got.Start.Character != len("import util from \"") || got.End.Character != got.Start.Character+len("./util.hlb") {
+		t.Errorf("got link range %+v, want the path", got)
+	}
+
+	// Messages that can't be decoded are answered with an error, and the
+	// server keeps reading.
+	c.send(`{"jsonrpc": "2.0", "id": `)
+	c.readError(codeParseError)
+	c.server.conn.maxLength = 64
+	c.send(`{"jsonrpc": "2.0", "id": 100, "method": "workspace/symbol", "params": {"query": "build"}}`)
+	c.readError(codeInvalidRequest)
+	c.server.conn.maxLength = maxContentLength
+	c.call("workspace/symbol", &WorkspaceSymbolParams{Query: "bld"}, &symbols)
+	if len(symbols) != 1 || symbols[0].Name != "build" {
+		t.Errorf("got workspace symbols %+v after errors", symbols)
+	}
+
+	c.notify("textDocument/didChange", &DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: uri, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "fun build() fs {\n\timage(\n}\n"}},
+	})
+	diags = c.diagnostics(uri)
+	if len(diags) != 1 || diags[0].Source != "syntax" {
+		t.Fatalf("got diagnostics %+v, want a syntax error", diags)
+	}
+
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenameWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.hlb")
+	main := filepath.Join(dir, "sub", "main.hlb")
+	for filename, src := range map[string]string{
+		lib:  "pub fun build() fs {\n\timage(\"alpine\")\n}\n",
+		main: "import lib from \"../lib.hlb\"\n\nfun test() fs {\n\tlib.build()\n}\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	libURI := filenameToURI(lib)
+	params := &RenameParams{
+		TextDocument: TextDocumentIdentifier{URI: libURI},
+		Position:     Position{Line: 0, Character: 8},
+		NewName:      "make",
+	}
+
+	for _, tc := range []struct {
+		name string
+		init InitializeParams
+		err  string
+	}{{
+		name: "folders",
+		init: InitializeParams{WorkspaceFolders: []WorkspaceFolder{{URI: filenameToURI(dir), Name: "test"}}},
+	}, {
+		name: "root",
+		init: InitializeParams{RootURI: filenameToURI(dir)},
+	}, {
+		name: "no workspace",
+		err:  "cannot rename build without the modules that may import",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := startServer(t)
+			c.call("initialize", &tc.init, nil)
+			c.notify("textDocument/didOpen", &DidOpenTextDocumentParams{
+				TextDocument: TextDocumentItem{URI: libURI, LanguageID: "hlb", Version: 1, Text: "pub fun build() fs {\n\timage(\"alpine\")\n}\n"},
+			})
+			c.diagnostics(libURI)
+
+			var edit WorkspaceEdit
+			err := c.request("textDocument/rename", params, &edit)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Message, tc.err) {
+					t.Fatalf("got error %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err.Message)
+			}
+			want := Range{Start: Position{Line: 3, Character: 5}, End: Position{Line: 3, Character: 10}}
+			if edits := edit.Changes[filenameToURI(main)]; len(edits) != 1 || edits[0].Range != want {
+				t.Errorf("got edits to main.hlb %+v, want lib.make at %+v", edits, want)
+			}
+			if edits := edit.Changes[libURI]; len(edits) != 1 {
+				t.Errorf("got edits to lib.hlb %+v, want the declaration", edits)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+func (s *Server) foldingRange(params json.RawMessage) (interface{}, error) {
+	var p FoldingRangeParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	ranges := []FoldingRange{}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok || doc.Module == nil {
+		return ranges, nil
+	}
+
+	fold := func(startLine, endLine int, kind string) {
+		if endLine > startLine {
+			ranges = append(ranges, FoldingRange{StartLine: startLine, EndLine: endLine, Kind: kind})
+		}
+	}
+	// Blocks and lists fold up to the line closing them, which stays visible.
+	block := func(start, end lexer.Position) {
+		fold(doc.Position(start).Line, doc.Position(end).Line-1, "")
+	}
+	ast.Inspect(doc.Module, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.StmtList:
+			block(n.Pos, n.CloseBrace.Pos)
+		case *ast.FieldList:
+			block(n.Pos, n.CloseParen.Pos)
+		case *ast.Heredoc:
+			block(n.Pos, n.End.Pos)
+		case *ast.RawHeredoc:
+			block(n.Pos, n.End.Pos)
+		case *ast.Comments:
+			// Comments fold by runs of consecutive lines.
+			start := 0
+			for i, c := range n.Comments {
+				if i+1 == len(n.Comments) || n.Comments[i+1].Pos.Line != c.Pos.Line+1 {
+					fold(doc.Position(n.Comments[start].Pos).Line, doc.Position(c.Pos).Line, "comment")
+					start = i + 1
+				}
+			}
+		}
+		return true
+	})
+	return ranges, nil
+}
+
+func (s *Server) selectionRange(params json.RawMessage) (interface{}, error) {
+	var p SelectionRangeParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, &rpcError{Code: codeInvalidParams, Message: fmt.Sprintf("document not open: %s", p.TextDocument.URI)}
+	}
+
+	ranges := []SelectionRange{}
+	for _, pos := range p.Positions {
+		var rngs []Range
+		for _, node := range doc.PathEnclosing(pos) {
+			if rng := doc.trimmedRange(node); len(rngs) == 0 || rng != rngs[len(rngs)-1] {
+				rngs = append(rngs, rng)
+			}
+		}
+		// Each position selects at least itself, even where the document
+		// can't be parsed.
+		if len(rngs) == 0 {
+			rngs = []Range{{Start: pos, End: pos}}
+		}
+		var sr *SelectionRange
+		for i := len(rngs) - 1; i >= 0; i-- {
+			sr = &SelectionRange{Range: rngs[i], Parent: sr}
+		}
+		ranges = append(ranges, *sr)
+	}
+	return ranges, nil
+}
+
+// trimmedRange returns the range of node without the newlines, semicolons
+// and commas that terminate statements and arguments, so that expanding the
+// selection steps between ranges that read as syntax.
+func (doc *document) trimmedRange(node ast.Node) Range {
+	start, end := node.Position().Offset, node.EndPosition().Offset
+	for end > start && strings.ContainsRune(" \t\r\n;,", rune(doc.Text[end-1])) {
+		end--
+	}
+	return doc.Range(lexer.Position{Offset: start}, lexer.Position{Offset: end})
+}
+
+func (s *Server) documentLink(params json.RawMessage) (interface{}, error) {
+	var p DocumentLinkParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	links := []DocumentLink{}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok || doc.Module == nil {
+		return links, nil
+	}
+
+	for _, decl := range doc.Module.Decls {
+		if decl.Import == nil {
+			continue
+		}
+		src := resolve.ImportSource(decl.Import)
+		if src == nil {
+			continue
+		}
+		var target string
+		switch src.Kind {
+		case resolve.LocalSource:
+			path := src.Ref
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(doc.Filename), path)
+			}
+			target = filenameToURI(path)
+		case resolve.ImageSource:
+			target = imageURL(src.Ref)
+		}
+		if target == "" {
+			continue
+		}
+
+		// Links cover the text of the string, without its quotes.
+		start, end := src.Lit.Pos, src.Lit.EndPos
+		if src.Lit.String != nil || src.Lit.RawString != nil {
+			start.Offset++
+			end.Offset--
+		}
+		links = append(links, DocumentLink{Range: doc.Range(start, end), Target: target})
+	}
+	return links, nil
+}
+
+// imageURL returns the web page of the repository of an image reference,
+// which is on Docker Hub unless the reference names a registry host, or an
+// empty string if the reference is empty.
+func imageURL(ref string) string {
+	if i := strings.IndexByte(ref, '@'); i >= 0 {
+		ref = ref[:i]
+	}
+	if i := strings.LastIndexByte(ref, ':'); i > strings.LastIndexByte(ref, '/') {
+		ref = ref[:i]
+	}
+	if ref == "" {
+		return ""
+	}
+
+	parts := strings.SplitN(ref, "/", 2)
+	host := parts[0]
+	if len(parts) == 2 && (strings.ContainsAny(host, ".:") || host == "localhost") && host != "docker.io" {
+		return "https://" + ref
+	}
+	if host == "docker.io" {
+		ref = parts[1]
+	}
+	if name := strings.TrimPrefix(ref, "library/"); !strings.Contains(name, "/") {
+		return "https://hub.docker.com/_/" + name
+	}
+	return "https://hub.docker.com/r/" + ref
+}
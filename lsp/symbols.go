@@ -0,0 +1,138 @@
+package lsp
+
+import (
+	"encoding/json"
+
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/format"
+	"github.com/hinshun/hlb-parser/index"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+var symbolKinds = map[resolve.Kind]SymbolKind{
+	resolve.Func:   SymbolFunction,
+	resolve.Import: SymbolModule,
+	resolve.Param:  SymbolVariable,
+	resolve.Effect: SymbolField,
+}
+
+// index indexes the open documents and the modules they import.
+func (s *Server) index() *index.Index {
+	var infos []*resolve.Info
+	for _, doc := range s.docs {
+		if doc.Info != nil {
+			infos = append(infos, doc.Info)
+		}
+	}
+	return index.Build(infos...)
+}
+
+// location returns the location of node in the file named filename.
+func (s *Server) location(filename string, node ast.Node) (Location, error) {
+	doc, err := s.documentOf(filename)
+	if err != nil {
+		return Location{}, err
+	}
+	return Location{URI: doc.URI, Range: doc.NodeRange(node)}, nil
+}
+
+func (s *Server) references(params json.RawMessage) (interface{}, error) {
+	doc, pos, err := s.document(params)
+	if err != nil {
+		return nil, err
+	}
+	var p ReferenceParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	_, obj := objectAt(doc, pos)
+	if obj == nil || obj.Module == resolve.Universe {
+		return nil, nil
+	}
+
+	idents := s.index().References(obj)
+	if p.Context.IncludeDeclaration {
+		idents = append([]*ast.Ident{obj.Ident}, idents...)
+	}
+	locs := []Location{}
+	for _, ident := range idents {
+		loc, err := s.location(ident.Pos.Filename, ident)
+		if err != nil {
+			return nil, err
+		}
+		locs = append(locs, loc)
+	}
+	return locs, nil
+}
+
+func (s *Server) documentSymbol(params json.RawMessage) (interface{}, error) {
+	var p DocumentSymbolParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	symbols := []DocumentSymbol{}
+	if !ok || doc.Info == nil {
+		return symbols, nil
+	}
+	for _, sym := range index.Build(doc.Info).Symbols(doc.Filename) {
+		symbols = append(symbols, documentSymbol(doc, sym))
+	}
+	return symbols, nil
+}
+
+func documentSymbol(doc *document, sym *index.Symbol) DocumentSymbol {
+	ds := DocumentSymbol{
+		Name:           sym.Name,
+		Detail:         symbolDetail(sym),
+		Kind:           symbolKinds[sym.Kind],
+		Range:          doc.NodeRange(sym.Decl),
+		SelectionRange: doc.NodeRange(sym.Ident),
+	}
+	for _, child := range sym.Children {
+		ds.Children = append(ds.Children, documentSymbol(doc, child))
+	}
+	return ds
+}
+
+// symbolDetail describes the signature of functions, the source of imports
+// and the types of parameters and effects.
+func symbolDetail(sym *index.Symbol) string {
+	switch decl := sym.Decl.(type) {
+	case *ast.FuncDecl:
+		detail := format.String(decl.Params) + " " + decl.Type.String()
+		if decl.Effects != nil {
+			detail += " " + format.String(decl.Effects)
+		}
+		if sym.Public {
+			detail = "pub " + detail
+		}
+		return detail
+	case *ast.ImportDecl:
+		return format.String(decl.Expr)
+	case *ast.Field:
+		return decl.Type.String()
+	}
+	return ""
+}
+
+func (s *Server) workspaceSymbol(params json.RawMessage) (interface{}, error) {
+	var p WorkspaceSymbolParams
+	if err := unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	infos := []SymbolInformation{}
+	for _, sym := range s.index().Search(p.Query) {
+		loc, err := s.location(sym.Filename(), sym.Decl)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, SymbolInformation{
+			Name:          sym.Name,
+			Kind:          symbolKinds[sym.Kind],
+			Location:      loc,
+			ContainerName: sym.Filename(),
+		})
+	}
+	return infos, nil
+}
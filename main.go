@@ -1,10 +1,11 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 
-	"github.com/alecthomas/repr"
 	"github.com/hinshun/hlb-parser/ast"
 )
 
@@ -17,14 +18,27 @@ func main() {
 }
 
 func run() error {
-	f, err := os.Open("./bar.hlb")
+	depth := flag.Int("depth", 0, "maximum depth of the nodes dumped, 0 for all")
+	kinds := flag.String("kinds", "", "comma separated kinds of the nodes dumped, like FuncDecl,Call, defaults to all")
+	flag.Parse()
+	filename := "./bar.hlb"
+	if flag.NArg() > 0 {
+		filename = flag.Arg(0)
+	}
+
+	f, err := ast.ParseFile(filename, ast.Options{Comments: true, Features: ast.AllFeatures})
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-
-	mod := &ast.Module{}
-	err = ast.Parser.Parse(f.Name(), f, mod)
-	repr.Println(mod)
-	return err
+	for _, d := range f.Diagnostics {
+		fmt.Fprintln(os.Stderr, d.Report())
+	}
+	if len(f.Diagnostics) > 0 {
+		return fmt.Errorf("found %d problems", len(f.Diagnostics))
+	}
+	opts := ast.DumpOptions{MaxDepth: *depth}
+	if *kinds != "" {
+		opts.Kinds = strings.Split(*kinds, ",")
+	}
+	return ast.Dump(os.Stdout, f.Module, opts)
 }
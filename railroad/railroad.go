@@ -0,0 +1,268 @@
+// Package railroad draws railroad diagrams of grammars written in the EBNF
+// of the participle ebnf package, as SVG.
+//
+// A diagram is read from left to right along its rails. Literals are drawn
+// in rounded boxes, tokens in italics, and productions in square boxes
+// linking to their own diagram. Alternatives branch below the first one, and
+// repetitions loop back below what they repeat.
+package railroad
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/ebnf"
+)
+
+const (
+	radius   = 10.0 // radius of the arcs of the rails
+	gap      = 10.0 // space between the elements of a sequence or choice
+	boxUp    = 11.0 // height of a box above its rail
+	charW    = 7.5  // width of a character of the monospace font
+	boxPad   = 10.0 // space between the text of a box and its sides
+	margin   = 10.0 // space around a diagram
+	endWidth = 10.0 // width of the bars at the start and end of a diagram
+)
+
+// Link returns the URL the box of the production name links to, like
+// "#name" or "name.svg".
+type Link func(name string) string
+
+// SVG returns the diagram of the production p as an SVG document.
+func SVG(p *ebnf.Production, link Link) string {
+	e := layout(p.Expression, link)
+	width := e.width + 2*margin + 2*endWidth
+	height := e.up + e.down + 2*margin
+	x, y := margin, margin+e.up
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g">`+"\n", width, height, width, height)
+	fmt.Fprintf(&sb, `<g fill="none" stroke="black" stroke-width="1.5" font-family="monospace" font-size="12">`+"\n")
+	fmt.Fprintf(&sb, `<path d="M%g %gv20M%g %gh%g"/>`+"\n", x, y-10, x, y, endWidth)
+	e.draw(&sb, x+endWidth, y)
+	fmt.Fprintf(&sb, `<path d="M%g %gh%gm0 -10v20"/>`+"\n", x+endWidth+e.width, y, endWidth)
+	sb.WriteString("</g>\n</svg>\n")
+	return sb.String()
+}
+
+// HTML writes a page titled title with the diagram of each production of g
+// under its EBNF, linking the boxes of productions to their diagram.
+func HTML(w io.Writer, title string, g *ebnf.EBNF) error {
+	link := func(name string) string { return "#" + name }
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\"/>\n<title>%s</title>\n", html.EscapeString(title))
+	sb.WriteString("<style>\nbody { font-family: sans-serif; }\nh2 { font-size: 1em; }\npre { color: #555; white-space: pre-wrap; }\n</style>\n")
+	fmt.Fprintf(&sb, "</head>\n<body>\n<h1>%s</h1>\n", html.EscapeString(title))
+	for _, p := range g.Productions {
+		fmt.Fprintf(&sb, "<h2 id=\"%s\">%s</h2>\n", html.EscapeString(p.Production), html.EscapeString(p.Production))
+		fmt.Fprintf(&sb, "<pre>%s = %s .</pre>\n", html.EscapeString(p.Production), html.EscapeString(p.Expression.String()))
+		sb.WriteString(SVG(p, link))
+	}
+	sb.WriteString("</body>\n</html>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// element is a laid out part of a diagram, whose rail enters on the left and
+// leaves on the right at the same height.
+type element struct {
+	// width is the length of the rail, and up and down the space taken above
+	// and below it.
+	width, up, down float64
+
+	// draw writes the element with its rail entering at x, y.
+	draw func(sb *strings.Builder, x, y float64)
+}
+
+func layout(n ebnf.Node, link Link) *element {
+	switch n := n.(type) {
+	case *ebnf.Expression:
+		var alts []*element
+		for _, seq := range n.Alternatives {
+			alts = append(alts, layout(seq, link))
+		}
+		return choice(alts)
+	case *ebnf.SubExpression:
+		// Lookahead assertions don't consume tokens, so only the expression
+		// they look for is drawn.
+		return layout(n.Expr, link)
+	case *ebnf.Sequence:
+		var elems []*element
+		for _, t := range n.Terms {
+			elems = append(elems, layout(t, link))
+		}
+		return sequence(elems)
+	case *ebnf.Term:
+		var e *element
+		switch {
+		case n.Name != "":
+			e = box(n.Name, link(n.Name), "", false)
+		case n.Literal != "":
+			text, err := strconv.Unquote(n.Literal)
+			if err != nil {
+				text = n.Literal
+			}
+			e = box(text, "", "", true)
+		case n.Token != "":
+			e = box(n.Token, "", "italic", false)
+		default:
+			e = layout(n.Group, link)
+		}
+		if n.Negation {
+			e = sequence([]*element{box("not", "", "italic", true), e})
+		}
+		// A "!" group must match at least one of its optional terms, which
+		// the diagram doesn't tell apart from matching none.
+		switch n.Repetition {
+		case "?":
+			e = optional(e)
+		case "+":
+			e = oneOrMore(e)
+		case "*":
+			e = optional(oneOrMore(e))
+		}
+		return e
+	}
+	panic(fmt.Sprintf("unexpected EBNF node %T", n))
+}
+
+// box returns a box with text, linking to href if it's not empty, rounded for
+// literals.
+func box(text, href, style string, rounded bool) *element {
+	width := float64(len(text))*charW + 2*boxPad
+	return &element{
+		width: width,
+		up:    boxUp,
+		down:  boxUp,
+		draw: func(sb *strings.Builder, x, y float64) {
+			if href != "" {
+				fmt.Fprintf(sb, `<a href="%s">`, html.EscapeString(href))
+			}
+			rx := 0.0
+			if rounded {
+				rx = boxUp
+			}
+			fmt.Fprintf(sb, `<rect x="%g" y="%g" width="%g" height="%g" rx="%g" fill="#ffffdd"/>`, x, y-boxUp, width, 2*boxUp, rx)
+			fmt.Fprintf(sb, `<text x="%g" y="%g" text-anchor="middle" fill="black" stroke="none"`, x+width/2, y+4)
+			if style != "" {
+				fmt.Fprintf(sb, ` font-style="%s"`, style)
+			}
+			fmt.Fprintf(sb, ">%s</text>", html.EscapeString(text))
+			if href != "" {
+				sb.WriteString("</a>")
+			}
+			sb.WriteString("\n")
+		},
+	}
+}
+
+// skip returns an empty rail.
+func skip() *element {
+	return &element{draw: func(*strings.Builder, float64, float64) {}}
+}
+
+func sequence(elems []*element) *element {
+	if len(elems) == 1 {
+		return elems[0]
+	}
+	e := &element{}
+	for i, elem := range elems {
+		if i > 0 {
+			e.width += gap
+		}
+		e.width += elem.width
+		e.up = max(e.up, elem.up)
+		e.down = max(e.down, elem.down)
+	}
+	e.draw = func(sb *strings.Builder, x, y float64) {
+		for i, elem := range elems {
+			if i > 0 {
+				fmt.Fprintf(sb, `<path d="M%g %gh%g"/>`+"\n", x, y, gap)
+				x += gap
+			}
+			elem.draw(sb, x, y)
+			x += elem.width
+		}
+	}
+	return e
+}
+
+// choice returns the alternatives alts, the first on the rail and the others
+// branching below it.
+func choice(alts []*element) *element {
+	if len(alts) == 1 {
+		return alts[0]
+	}
+	e := &element{up: alts[0].up}
+	// rails are the offsets of the rails of the alternatives below the rail
+	// of the choice.
+	rails := make([]float64, len(alts))
+	bottom := alts[0].down
+	for i, alt := range alts {
+		e.width = max(e.width, alt.width+4*radius)
+		if i > 0 {
+			rails[i] = max(bottom+gap+alt.up, rails[i-1]+2*radius)
+			bottom = rails[i] + alt.down
+		}
+	}
+	e.down = bottom
+	e.draw = func(sb *strings.Builder, x, y float64) {
+		for i, alt := range alts {
+			inner := e.width - 4*radius
+			if i == 0 {
+				fmt.Fprintf(sb, `<path d="M%g %gh%g"/>`+"\n", x, y, 2*radius)
+			} else {
+				fmt.Fprintf(sb, `<path d="M%g %ga%g %g 0 0 1 %g %gv%ga%g %g 0 0 0 %g %g"/>`+"\n",
+					x, y, radius, radius, radius, radius, rails[i]-2*radius, radius, radius, radius, radius)
+			}
+			alt.draw(sb, x+2*radius, y+rails[i])
+			fmt.Fprintf(sb, `<path d="M%g %gh%g`, x+2*radius+alt.width, y+rails[i], inner-alt.width)
+			if i == 0 {
+				fmt.Fprintf(sb, `h%g"/>`+"\n", 2*radius)
+			} else {
+				fmt.Fprintf(sb, `a%g %g 0 0 0 %g %gv%ga%g %g 0 0 1 %g %g"/>`+"\n",
+					radius, radius, radius, -radius, -(rails[i] - 2*radius), radius, radius, radius, -radius)
+			}
+		}
+	}
+	return e
+}
+
+func optional(e *element) *element {
+	return choice([]*element{e, skip()})
+}
+
+// oneOrMore returns e with a rail looping back below it.
+func oneOrMore(e *element) *element {
+	loop := max(e.down+gap, 2*radius)
+	return &element{
+		width: e.width + 4*radius,
+		up:    e.up,
+		down:  loop,
+		draw: func(sb *strings.Builder, x, y float64) {
+			fmt.Fprintf(sb, `<path d="M%g %gh%g"/>`+"\n", x, y, 2*radius)
+			e.draw(sb, x+2*radius, y)
+			fmt.Fprintf(sb, `<path d="M%g %gh%g"/>`+"\n", x+2*radius+e.width, y, 2*radius)
+			fmt.Fprintf(sb, `<path d="M%g %ga%g %g 0 0 1 %g %gv%ga%g %g 0 0 1 %g %gh%ga%g %g 0 0 1 %g %gv%ga%g %g 0 0 1 %g %g"/>`+"\n",
+				x+2*radius+e.width, y,
+				radius, radius, radius, radius,
+				loop-2*radius,
+				radius, radius, -radius, radius,
+				-e.width,
+				radius, radius, -radius, -radius,
+				-(loop - 2*radius),
+				radius, radius, radius, -radius)
+		},
+	}
+}
+
+func max(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,73 @@
+package railroad
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/participle/v2/ebnf"
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+func TestSVG(t *testing.T) {
+	g, err := ebnf.ParseString(`Call = Ident ("(" (Expr ("," Expr)*)? ")")? <newline>+ .`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := SVG(g.Productions[0], func(name string) string { return name + ".svg" })
+	checkXML(t, got)
+	for _, want := range []string{
+		`<a href="Ident.svg">`,
+		`<a href="Expr.svg">`,
+		`>(</text>`,
+		`>,</text>`,
+		`font-style="italic">newline</text>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("diagram doesn't contain %s:\n%s", want, got)
+		}
+	}
+}
+
+// TestHTML checks that the page of the HLB grammar has a diagram for each
+// production, and that the boxes of productions link to one of them.
+func TestHTML(t *testing.T) {
+	g, err := ebnf.ParseString(ast.GrammarEBNF())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sb strings.Builder
+	if err := HTML(&sb, "HLB grammar", g); err != nil {
+		t.Fatal(err)
+	}
+	page := sb.String()
+	checkXML(t, strings.TrimPrefix(page, "<!DOCTYPE html>\n"))
+
+	for _, p := range g.Productions {
+		if !strings.Contains(page, `<h2 id="`+p.Production+`">`) {
+			t.Errorf("no diagram of %s", p.Production)
+		}
+	}
+	for _, s := range strings.Split(page, `<a href="#`)[1:] {
+		name := s[:strings.Index(s, `"`)]
+		if !strings.Contains(page, `id="`+name+`"`) {
+			t.Errorf("link to %s has no diagram", name)
+		}
+	}
+}
+
+// checkXML checks that doc is well-formed.
+func checkXML(t *testing.T, doc string) {
+	t.Helper()
+	d := xml.NewDecoder(strings.NewReader(doc))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("%s:\n%s", err, doc)
+		}
+	}
+}
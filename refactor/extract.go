@@ -0,0 +1,179 @@
+package refactor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// extract offers to move the statements in the selection into a new function
+// declared after the enclosing one, and to call it in their place. The
+// parameters and loop variables the statements refer to become parameters of
+// the new function, and the effects they bind become its effects.
+func (r *refactorer) extract() []*Action {
+	start, end := r.trim()
+	if start >= end {
+		return nil
+	}
+	path := ast.PathEnclosing(r.mod, start)
+	list, i := stmtList(path, start, end)
+	if list == nil {
+		return nil
+	}
+	typ := r.blockType(path[i+1:])
+	fun := r.funcDecl()
+	if typ == "" || fun == nil {
+		return nil
+	}
+
+	var first, last ast.Node
+	for _, stmt := range list.Stmts {
+		node := stmtNode(stmt)
+		if node == nil {
+			continue
+		}
+		pos, endPos := node.Position().Offset, node.EndPosition().Offset
+		if endPos <= start || pos >= end {
+			continue
+		}
+		if pos < start || endPos > end {
+			// The selection splits a statement.
+			return nil
+		}
+		if first == nil {
+			first = node
+		}
+		last = node
+	}
+	if first == nil {
+		return nil
+	}
+	from, to := first.Position().Offset, last.EndPosition().Offset
+
+	var (
+		params  []string
+		args    []string
+		effects = make(map[*resolve.Object]bool)
+		seen    = make(map[*resolve.Object]bool)
+	)
+	ast.Inspect(list, func(node ast.Node) bool {
+		if node == nil || node.EndPosition().Offset <= from || node.Position().Offset >= to {
+			return false
+		}
+		ident, ok := node.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj := r.info.Uses[ident]
+		if obj == nil || seen[obj] || (obj.Ident.Pos.Offset >= from && obj.Ident.Pos.Offset < to) {
+			return true
+		}
+		switch {
+		case obj.Kind == resolve.Param && obj.Func == fun, obj.Kind == resolve.Var:
+			seen[obj] = true
+			typ, _ := r.objectType(obj)
+			if typ == "" {
+				typ = defaultType
+			}
+			params = append(params, fmt.Sprintf("%s %s", typ, obj.Name))
+			args = append(args, obj.Name)
+		case obj.Kind == resolve.Effect && obj.Func == fun:
+			seen[obj] = true
+			effects[obj] = true
+		}
+		return true
+	})
+
+	name := uniqueName("extracted", r.declared)
+	call := name
+	if len(args) > 0 {
+		call += "(" + strings.Join(args, ", ") + ")"
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n\nfun %s(%s) %s", name, strings.Join(params, ", "), typ)
+	if len(effects) > 0 {
+		var fields []string
+		for _, field := range resolve.Fields(fun.Effects) {
+			if effects[r.info.Defs[field.Name]] {
+				fields = append(fields, fmt.Sprintf("%s %s", field.Type.String(), field.Name.Text))
+			}
+		}
+		fmt.Fprintf(&sb, " (%s)", strings.Join(fields, ", "))
+	}
+	body := string(r.src[from:to])
+	fmt.Fprintf(&sb, " {\n\t%s\n}", reindent(body, r.indent(from), "\t"))
+
+	return []*Action{{
+		Title: "Extract statements into function",
+		Kind:  Extract,
+		Edits: []analysis.TextEdit{
+			edit(first.Position(), last.EndPosition(), call),
+			insert(fun.EndPos.Offset, sb.String()),
+		},
+	}}
+}
+
+// trim returns the selection without surrounding whitespace.
+func (r *refactorer) trim() (int, int) {
+	start, end := r.start, r.end
+	for start < end && isSpace(r.src[start]) {
+		start++
+	}
+	for end > start && isSpace(r.src[end-1]) {
+		end--
+	}
+	return start, end
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+// stmtList returns the innermost statement list in path enclosing the
+// selection from start to end, and its index in path.
+func stmtList(path []ast.Node, start, end int) (*ast.StmtList, int) {
+	for i, node := range path {
+		list, ok := node.(*ast.StmtList)
+		if ok && list.OpenBrace.EndPos.Offset <= start && end <= list.CloseBrace.Pos.Offset {
+			return list, i
+		}
+	}
+	return nil, 0
+}
+
+// stmtNode returns the node of the statement stmt, without the terminating
+// semicolon, or nil if it's a newline or comment.
+func stmtNode(stmt *ast.Stmt) ast.Node {
+	switch {
+	case stmt.If != nil:
+		return stmt.If
+	case stmt.For != nil:
+		return stmt.For
+	case stmt.Entry != nil:
+		return stmt.Entry
+	case stmt.Expr != nil:
+		return stmt.Expr
+	}
+	return nil
+}
+
+// blockType returns the type of a block enclosed by path, the nodes
+// enclosing the block innermost first, or an empty string if it's unknown.
+func (r *refactorer) blockType(path []ast.Node) string {
+	for i, node := range path {
+		switch n := node.(type) {
+		case *ast.FuncDecl:
+			return n.Type.String()
+		case *ast.BlockLit:
+			if n.Type != nil {
+				return n.Type.String()
+			}
+			return r.expectedType(path[i+1:])
+		}
+	}
+	return ""
+}
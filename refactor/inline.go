@@ -0,0 +1,299 @@
+package refactor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/fix"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// inline offers to replace the only call of a function in the module with
+// its body and to remove the function. The call must be a statement of a
+// block of the function's type, and the function must be private, without
+// effects or variadic parameters. The arguments, or the defaults of the
+// parameters not passed, replace the references to the parameters in the
+// body.
+func (r *refactorer) inline() []*Action {
+	if len(r.path) < 7 {
+		return nil
+	}
+	ident, ok := r.path[0].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	ref, ok := r.path[2].(*ast.Ref)
+	if !ok || ref.Terminal.Ident != ident {
+		return nil
+	}
+	if ref.Next != nil {
+		call := ref.Next.Call
+		if call == nil || call.At != nil || call.With != nil || call.As != nil || ref.Next.Next != nil {
+			return nil
+		}
+	}
+	unary, ok := r.path[3].(*ast.Unary)
+	if !ok || unary.Op != ast.OpNone {
+		return nil
+	}
+	expr, ok := r.path[4].(*ast.Expr)
+	if !ok {
+		return nil
+	}
+	stmt, ok := r.path[5].(*ast.Stmt)
+	if !ok || stmt.Expr != expr {
+		return nil
+	}
+
+	obj := r.info.ObjectOf(ident)
+	if obj == nil || obj.Kind != resolve.Func || obj.Module != r.info.Modules[0] || r.uses(obj) != 1 {
+		return nil
+	}
+	fun := obj.FuncDecl()
+	caller := r.funcDecl()
+	if resolve.IsPublic(fun) || fun.Effects != nil || fun.Body == nil || fun == caller ||
+		fun.Type.String() != r.blockType(r.path[6:]) {
+		return nil
+	}
+	params := resolve.Fields(fun.Params)
+	if len(params) > 0 && params[len(params)-1].Variadic != nil {
+		return nil
+	}
+
+	var args []*ast.ExprStmt
+	if ref.Next != nil && ref.Next.Call.Args != nil {
+		args = ref.Next.Call.Args.Exprs
+	}
+	values, ok := r.bindArgs(params, args)
+	if !ok {
+		return nil
+	}
+	scope := r.scope()
+	if scope == nil || !r.canInline(fun, values, scope) {
+		return nil
+	}
+
+	body, ok := r.inlineBody(fun, values)
+	if !ok {
+		return nil
+	}
+	indent := r.indent(expr.Pos.Offset)
+	from, to := r.declRange(fun)
+	return []*Action{{
+		Title: fmt.Sprintf("Inline function %s", fun.Name.Text),
+		Kind:  Inline,
+		Edits: sortEdits([]analysis.TextEdit{
+			edit(expr.Pos, expr.EndPos, reindent(body, r.indent(bodyStart(fun)), indent)),
+			edit(atOffset(from), atOffset(to), ""),
+		}),
+	}}
+}
+
+// uses returns the number of references to obj.
+func (r *refactorer) uses(obj *resolve.Object) int {
+	n := 0
+	for _, used := range r.info.Uses {
+		if used == obj {
+			n++
+		}
+	}
+	return n
+}
+
+// scope returns the innermost scope enclosing the cursor.
+func (r *refactorer) scope() *resolve.Scope {
+	for _, node := range r.path {
+		if scope, ok := r.info.Scopes[node]; ok {
+			return scope
+		}
+	}
+	return nil
+}
+
+// bindArgs returns the expression passed to each parameter in params by
+// args, or by default. It reports false if a parameter is passed nothing, or
+// an argument is a splat or doesn't match a parameter.
+func (r *refactorer) bindArgs(params []*ast.Field, args []*ast.ExprStmt) (map[*ast.Field]ast.Node, bool) {
+	values := make(map[*ast.Field]ast.Node)
+	i := 0
+	for _, arg := range args {
+		switch {
+		case arg.Entry != nil:
+			var param *ast.Field
+			for _, p := range params {
+				if p.Name.Text == arg.Entry.Keys[0].Text {
+					param = p
+				}
+			}
+			if param == nil || len(arg.Entry.Keys) > 1 || values[param] != nil {
+				return nil, false
+			}
+			values[param] = arg.Entry.Value
+		case arg.Expr != nil:
+			if i >= len(params) || isSplat(arg.Expr) || values[params[i]] != nil {
+				return nil, false
+			}
+			values[params[i]] = arg.Expr
+			i++
+		}
+	}
+	for _, param := range params {
+		if values[param] != nil {
+			continue
+		}
+		if param.Default == nil {
+			return nil, false
+		}
+		values[param] = param.Default.Unary
+	}
+	return values, true
+}
+
+// canInline reports whether the references in the body of fun and in the
+// values passed to it resolve to the same objects once inlined in scope. The
+// body may not refer to functions, imports or builtins shadowed at the call,
+// and the loop variables of the body may not shadow the references in the
+// values.
+func (r *refactorer) canInline(fun *ast.FuncDecl, values map[*ast.Field]ast.Node, scope *resolve.Scope) bool {
+	vars := make(map[string]bool)
+	ok := true
+	check := func(node ast.Node) {
+		selectors := make(map[*ast.Ident]bool)
+		ast.Inspect(node, func(node ast.Node) bool {
+			switch n := node.(type) {
+			case *ast.Selector:
+				selectors[n.Ident] = true
+			case *ast.ForHeader:
+				for _, ident := range []*ast.Ident{n.Counter, n.Var} {
+					if ident != nil {
+						vars[ident.Text] = true
+					}
+				}
+			case *ast.Ident:
+				obj := r.info.Uses[n]
+				if obj == nil || selectors[n] {
+					return true
+				}
+				switch obj.Kind {
+				case resolve.Func, resolve.Import:
+					ok = ok && scope.Lookup(n.Text) == obj
+				case resolve.Builtin:
+					ok = ok && scope.Lookup(n.Text) == nil
+				}
+			}
+			return ok
+		})
+	}
+	check(fun.Body)
+	for _, value := range values {
+		if _, isDefault := value.(*ast.Unary); isDefault {
+			check(value)
+		}
+	}
+	for _, value := range values {
+		ast.Inspect(value, func(node ast.Node) bool {
+			if ident, isIdent := node.(*ast.Ident); isIdent && vars[ident.Text] {
+				ok = false
+			}
+			return ok
+		})
+	}
+	return ok
+}
+
+// inlineBody returns the statements of the body of fun with the references to
+// its parameters replaced by the values passed to them.
+func (r *refactorer) inlineBody(fun *ast.FuncDecl, values map[*ast.Field]ast.Node) (string, bool) {
+	var first, last ast.Node
+	for _, stmt := range fun.Body.Stmts {
+		if node := stmtNode(stmt); node != nil {
+			if first == nil {
+				first = node
+			}
+			last = node
+		}
+	}
+	if first == nil {
+		return "", false
+	}
+	from, to := first.Position().Offset, last.EndPosition().Offset
+
+	var edits []analysis.TextEdit
+	ast.Inspect(fun.Body, func(node ast.Node) bool {
+		ident, ok := node.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		obj := r.info.Uses[ident]
+		if obj == nil || obj.Kind != resolve.Param || obj.Func != fun {
+			return true
+		}
+		value := values[obj.Decl.(*ast.Field)]
+		text := r.text(value)
+		if needsParens(value) {
+			text = "(" + text + ")"
+		}
+		edits = append(edits, edit(atOffset(ident.Pos.Offset-from), atOffset(ident.EndPos.Offset-from), text))
+		return true
+	})
+	body, err := fix.Apply(r.src[from:to], edits)
+	if err != nil {
+		return "", false
+	}
+	return string(body), true
+}
+
+// needsParens reports whether value must be grouped to replace an
+// identifier.
+func needsParens(value ast.Node) bool {
+	switch v := value.(type) {
+	case *ast.Expr:
+		return v.Unary == nil || v.Unary.Op != ast.OpNone
+	case *ast.Unary:
+		return v.Op != ast.OpNone
+	}
+	return false
+}
+
+// bodyStart returns the offset of the first statement of the body of fun.
+func bodyStart(fun *ast.FuncDecl) int {
+	for _, stmt := range fun.Body.Stmts {
+		if node := stmtNode(stmt); node != nil {
+			return node.Position().Offset
+		}
+	}
+	return fun.Body.Pos.Offset
+}
+
+// declRange returns the range of the declaration of fun along with its doc
+// comment and the blank line following it.
+func (r *refactorer) declRange(fun *ast.FuncDecl) (int, int) {
+	from := strings.LastIndexByte(string(r.src[:fun.Pos.Offset]), '\n') + 1
+	for from > 0 {
+		prev := strings.LastIndexByte(string(r.src[:from-1]), '\n') + 1
+		if !strings.HasPrefix(strings.TrimLeft(string(r.src[prev:from]), " \t"), "#") {
+			break
+		}
+		from = prev
+	}
+
+	to := fun.EndPos.Offset
+	for i := 0; i < 2 && to < len(r.src) && r.src[to] == '\n'; i++ {
+		to++
+	}
+	// The last declaration takes the blank line before it instead.
+	if to == len(r.src) && from > 1 && r.src[from-1] == '\n' && r.src[from-2] == '\n' {
+		from--
+	}
+	return from, to
+}
+
+func sortEdits(edits []analysis.TextEdit) []analysis.TextEdit {
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].Pos.Offset < edits[j].Pos.Offset
+	})
+	return edits
+}
@@ -0,0 +1,148 @@
+// Package refactor computes code actions, the rewrites most often done by
+// hand: converting a string literal between its forms, adding a stub for an
+// undefined function, extracting statements into a new function, inlining a
+// function called once, and wrapping a single option in a with block.
+//
+// Actions are offered only when they preserve the meaning of the module, so a
+// string is only converted to a form that keeps its value, and a function is
+// only inlined if no reference in its body or arguments would resolve
+// differently at the call site.
+package refactor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// Kind is the kind of a code action, named like the kinds of the language
+// server protocol.
+type Kind string
+
+const (
+	QuickFix Kind = "quickfix"
+	Extract  Kind = "refactor.extract"
+	Inline   Kind = "refactor.inline"
+	Rewrite  Kind = "refactor.rewrite"
+)
+
+// Action is a code action on a module.
+type Action struct {
+	Title string
+	Kind  Kind
+
+	// Edits are the edits to the source of the module, sorted by position.
+	Edits []analysis.TextEdit
+}
+
+// Actions returns the code actions available for the selection from start to
+// end in src, the source of mod as resolved in info. An empty selection is a
+// cursor.
+func Actions(src []byte, mod *ast.Module, info *resolve.Info, start, end int) []*Action {
+	if start > end {
+		start, end = end, start
+	}
+	r := &refactorer{
+		src:   src,
+		mod:   mod,
+		info:  info,
+		start: start,
+		end:   end,
+		path:  ast.PathEnclosing(mod, start),
+	}
+	var actions []*Action
+	for _, f := range []func() []*Action{
+		r.addFunc,
+		r.convertString,
+		r.wrapWith,
+		r.extract,
+		r.inline,
+	} {
+		actions = append(actions, f()...)
+	}
+	return actions
+}
+
+type refactorer struct {
+	src        []byte
+	mod        *ast.Module
+	info       *resolve.Info
+	start, end int
+
+	// path are the nodes enclosing start, innermost first.
+	path []ast.Node
+}
+
+// text returns the source of node.
+func (r *refactorer) text(node ast.Node) string {
+	return string(r.src[node.Position().Offset:node.EndPosition().Offset])
+}
+
+// indent returns the indentation of the line containing offset.
+func (r *refactorer) indent(offset int) string {
+	line := strings.LastIndexByte(string(r.src[:offset]), '\n') + 1
+	end := line
+	for end < len(r.src) && (r.src[end] == ' ' || r.src[end] == '\t') {
+		end++
+	}
+	return string(r.src[line:end])
+}
+
+// funcDecl returns the function declaration enclosing the start of the
+// selection.
+func (r *refactorer) funcDecl() *ast.FuncDecl {
+	for _, node := range r.path {
+		if fun, ok := node.(*ast.FuncDecl); ok {
+			return fun
+		}
+	}
+	return nil
+}
+
+// declared reports whether name is declared at the top level of the module or
+// is a builtin.
+func (r *refactorer) declared(name string) bool {
+	return r.info.Modules[0].Scope.Lookup(name) != nil || resolve.LookupBuiltin(name, "") != nil
+}
+
+// uniqueName returns base, or base followed by the smallest number from 2
+// that makes it distinct from the names that taken reports.
+func uniqueName(base string, taken func(string) bool) string {
+	name := base
+	for i := 2; taken(name); i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+	return name
+}
+
+// reindent moves the lines of text after the first from the indentation from
+// to to, leaving blank lines empty.
+func reindent(text, from, to string) string {
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		switch line := lines[i]; {
+		case strings.TrimSpace(line) == "":
+			lines[i] = ""
+		default:
+			lines[i] = to + strings.TrimPrefix(line, from)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func edit(pos, end lexer.Position, text string) analysis.TextEdit {
+	return analysis.TextEdit{Pos: pos, End: end, NewText: []byte(text)}
+}
+
+// insert returns an edit inserting text at offset.
+func insert(offset int, text string) analysis.TextEdit {
+	return edit(atOffset(offset), atOffset(offset), text)
+}
+
+func atOffset(offset int) lexer.Position {
+	return lexer.Position{Offset: offset}
+}
@@ -0,0 +1,149 @@
+package refactor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/fix"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+func TestActions(t *testing.T) {
+	// The selection is between the [[ and ]] in each source, or at the | if
+	// it's a cursor.
+	for _, tc := range []struct {
+		name  string
+		src   string
+		title string
+		want  string
+	}{{
+		name:  "string to raw string",
+		src:   "fun f() fs {\n\timage(\"a|lpine\")\n}\n",
+		title: "Convert to raw string",
+		want:  "fun f() fs {\n\timage(`alpine`)\n}\n",
+	}, {
+		name:  "string to heredoc",
+		src:   "fun f() fs {\n\tmkfile(\"a\", 0o644, \"x=|${f}\\ny\\n\")\n}\n",
+		title: "Convert to heredoc",
+		want:  "fun f() fs {\n\tmkfile(\"a\", 0o644, <<~EOF\n\t\tx=${f}\n\t\ty\n\tEOF)\n}\n",
+	}, {
+		name:  "heredoc to string",
+		src:   "fun f() fs {\n\tmkfile(\"a\", 0o644, <<~EOF\n\t\tx=${f} \"q\"\n\tE|OF)\n}\n",
+		title: "Convert to string",
+		want:  "fun f() fs {\n\tmkfile(\"a\", 0o644, \"x=${f} \\\"q\\\"\\n\")\n}\n",
+	}, {
+		name:  "raw heredoc to raw string",
+		src:   "fun f() fs {\n\trun(<<~`EOF`\n\t\techo $HOME|\n\tEOF)\n}\n",
+		title: "Convert to raw string",
+		want:  "fun f() fs {\n\trun(`echo $HOME\n`)\n}\n",
+	}, {
+		name:  "add function",
+		src:   "fun f(string pkg) fs {\n\tbui|ld(image(\"golang\"), pkg, \"-v\", 1)\n}\n",
+		title: "Add function build",
+		want:  "fun f(string pkg) fs {\n\tbuild(image(\"golang\"), pkg, \"-v\", 1)\n}\n\nfun build(fs arg1, string pkg, string arg3, int arg4) fs {}\n",
+	}, {
+		name:  "add option",
+		src:   "fun f(string... flags) fs {\n\trun(\"make\") with {\n\t\tgo|Cache(flags...)\n\t}\n}\n",
+		title: "Add function goCache",
+		want:  "fun f(string... flags) fs {\n\trun(\"make\") with {\n\t\tgoCache(flags...)\n\t}\n}\n\nfun goCache(string... flags) option::run {}\n",
+	}, {
+		name:  "wrap with",
+		src:   "fun f() fs {\n\trun(\"make\") with re|adonly\n}\n",
+		title: "Wrap option in with block",
+		want:  "fun f() fs {\n\trun(\"make\") with {\n\t\treadonly\n\t}\n}\n",
+	}, {
+		name:  "extract",
+		src:   "fun f(string pkg, string... xs) fs (string out) {\n\timage(\"golang\")\n\tfor (x in xs) {\n\t\t[[run(pkg)\n\t\trun(x) with {\n\t\t\tmount(scratch, \"/out\") as out\n\t\t}]]\n\t}\n}\n",
+		title: "Extract statements into function",
+		want:  "fun f(string pkg, string... xs) fs (string out) {\n\timage(\"golang\")\n\tfor (x in xs) {\n\t\textracted(pkg, x)\n\t}\n}\n\nfun extracted(string pkg, string x) fs (string out) {\n\trun(pkg)\n\trun(x) with {\n\t\tmount(scratch, \"/out\") as out\n\t}\n}\n",
+	}, {
+		name:  "inline",
+		src:   "fun f() fs {\n\tco|mpile(\"./cmd\", flags: \"-v\")\n}\n\n# compile builds a package.\nfun compile(string pkg, string flags, string out = \"/out\") fs {\n\timage(\"golang\")\n\trun(\"go build ${flags} -o ${out} ${pkg}\")\n}\n",
+		title: "Inline function compile",
+		want:  "fun f() fs {\n\timage(\"golang\")\n\trun(\"go build ${\"-v\"} -o ${\"/out\"} ${\"./cmd\"}\")\n}\n",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			src, start, end := selection(tc.src)
+			actions := actionsOf(t, src, start, end)
+			var titles []string
+			for _, action := range actions {
+				titles = append(titles, action.Title)
+				if action.Title != tc.title {
+					continue
+				}
+				got, err := fix.Apply([]byte(src), action.Edits)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != tc.want {
+					t.Errorf("got:\n%s\nwant:\n%s", got, tc.want)
+				}
+				return
+			}
+			t.Errorf("got actions %q, want %q", titles, tc.title)
+		})
+	}
+}
+
+func TestNoActions(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		src   string
+		title string
+	}{{
+		name:  "string without trailing newline to heredoc",
+		src:   "fun f() fs {\n\timage(\"a|lpine\")\n}\n",
+		title: "Convert to heredoc",
+	}, {
+		name:  "interpolated string to raw string",
+		src:   "fun f(string v) fs {\n\timage(\"alpine:|${v}\")\n}\n",
+		title: "Convert to raw string",
+	}, {
+		name:  "split statement",
+		src:   "fun f() fs {\n\timage([[\"golang\")\n\trun(\"make\")]]\n}\n",
+		title: "Extract statements into function",
+	}, {
+		name:  "inline called twice",
+		src:   "fun f() fs {\n\tg|\n\tg\n}\n\nfun g() fs {\n\timage(\"a\")\n}\n",
+		title: "Inline function g",
+	}, {
+		name:  "inline shadowed builtin",
+		src:   "fun f(string image) fs {\n\tg|\n}\n\nfun g() fs {\n\timage(\"a\")\n}\n",
+		title: "Inline function g",
+	}, {
+		name:  "inline captured argument",
+		src:   "fun f(string x) fs {\n\tg|(x)\n}\n\nfun g(string v) fs {\n\tfor (x in list) {\n\t\trun(v)\n\t}\n}\n\nfun list() []string\n",
+		title: "Inline function g",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			src, start, end := selection(tc.src)
+			for _, action := range actionsOf(t, src, start, end) {
+				if action.Title == tc.title {
+					t.Errorf("got action %q", tc.title)
+				}
+			}
+		})
+	}
+}
+
+// selection removes the markers of the selection from src.
+func selection(src string) (string, int, int) {
+	if i := strings.Index(src, "|"); i >= 0 {
+		return src[:i] + src[i+1:], i, i
+	}
+	start := strings.Index(src, "[[")
+	src = src[:start] + src[start+2:]
+	end := strings.Index(src, "]]")
+	return src[:end] + src[end+2:], start, end
+}
+
+func actionsOf(t *testing.T, src string, start, end int) []*Action {
+	t.Helper()
+	f := ast.ParseSource("test.hlb", []byte(src), ast.Options{Comments: true, Features: ast.AllFeatures})
+	if err := f.Err(); err != nil {
+		t.Fatal(err)
+	}
+	info := resolve.Resolve("test.hlb", f.Module, nil)
+	return Actions([]byte(src), f.Module, info, start, end)
+}
@@ -0,0 +1,238 @@
+package refactor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+// stringForm is one of the forms of string literals.
+type stringForm int
+
+const (
+	quoted stringForm = iota
+	raw
+	heredoc
+	rawHeredoc
+)
+
+var formNames = [...]string{
+	quoted:     "string",
+	raw:        "raw string",
+	heredoc:    "heredoc",
+	rawHeredoc: "raw heredoc",
+}
+
+// heredocMarkers are the markers tried in turn to delimit heredocs, since a
+// marker can't appear as a word in the body.
+var heredocMarkers = []string{"EOF", "EOT", "END", "TEXT"}
+
+// part is a piece of the value of a string literal, either text or the
+// source of an interpolation.
+type part struct {
+	text   string
+	interp string
+}
+
+// convertString offers to convert the string literal at the cursor to each
+// of the other forms that can hold its value.
+func (r *refactorer) convertString() []*Action {
+	var lit *ast.StringLit
+	for _, node := range r.path {
+		if lit, _ = node.(*ast.StringLit); lit != nil {
+			break
+		}
+	}
+	if lit == nil {
+		return nil
+	}
+	parts, ok := r.parts(lit)
+	if !ok {
+		return nil
+	}
+
+	var actions []*Action
+	indent := r.indent(lit.Pos.Offset)
+	for form := quoted; form <= rawHeredoc; form++ {
+		if form == formOf(lit) {
+			continue
+		}
+		text, ok := renderString(form, parts, indent)
+		if !ok {
+			continue
+		}
+		actions = append(actions, &Action{
+			Title: fmt.Sprintf("Convert to %s", formNames[form]),
+			Kind:  Rewrite,
+			Edits: []analysis.TextEdit{edit(lit.Pos, lit.EndPos, text)},
+		})
+	}
+	return actions
+}
+
+func formOf(lit *ast.StringLit) stringForm {
+	switch {
+	case lit.RawString != nil:
+		return raw
+	case lit.Heredoc != nil:
+		return heredoc
+	case lit.RawHeredoc != nil:
+		return rawHeredoc
+	}
+	return quoted
+}
+
+// parts returns the value of lit split around its interpolations. The value
+// is computed by replacing interpolations with marks, which can't otherwise
+// appear in the value.
+func (r *refactorer) parts(lit *ast.StringLit) ([]part, bool) {
+	if strings.ContainsRune(r.text(lit), 0) {
+		return nil, false
+	}
+	var interps []string
+	mark := func(interp *ast.Interpolated) *string {
+		s := fmt.Sprintf("\x00%d\x00", len(interps))
+		interps = append(interps, r.text(interp))
+		return &s
+	}
+
+	marked := *lit
+	switch {
+	case lit.String != nil:
+		s := *lit.String
+		s.Fragments = nil
+		for _, f := range lit.String.Fragments {
+			if f.Interpolated != nil {
+				f = &ast.StringFragment{Text: mark(f.Interpolated)}
+			}
+			s.Fragments = append(s.Fragments, f)
+		}
+		marked.String = &s
+	case lit.Heredoc != nil:
+		h := *lit.Heredoc
+		h.Fragments = nil
+		for _, f := range lit.Heredoc.Fragments {
+			if f.Interpolated != nil {
+				f = &ast.HeredocFragment{Text: mark(f.Interpolated)}
+			}
+			h.Fragments = append(h.Fragments, f)
+		}
+		marked.Heredoc = &h
+	}
+	value, ok := marked.Unquoted()
+	if !ok {
+		return nil, false
+	}
+
+	var parts []part
+	for i, s := range strings.Split(value, "\x00") {
+		if i%2 == 0 {
+			parts = append(parts, part{text: s})
+			continue
+		}
+		var n int
+		fmt.Sscan(s, &n)
+		parts = append(parts, part{interp: interps[n]})
+	}
+	return parts, true
+}
+
+// renderString renders parts as a literal of form, where heredoc bodies are
+// indented one level more than indent. It reports false if the form can't
+// hold the value, which is checked by parsing the rendered literal.
+func renderString(form stringForm, parts []part, indent string) (string, bool) {
+	var (
+		value  strings.Builder
+		interp bool
+	)
+	for _, p := range parts {
+		value.WriteString(p.text)
+		interp = interp || p.interp != ""
+	}
+	if interp && (form == raw || form == rawHeredoc) {
+		return "", false
+	}
+
+	switch form {
+	case quoted:
+		var sb strings.Builder
+		sb.WriteString(`"`)
+		for _, p := range parts {
+			sb.WriteString(quoteReplacer.Replace(p.text))
+			sb.WriteString(p.interp)
+		}
+		sb.WriteString(`"`)
+		return checkString(sb.String(), parts)
+	case raw:
+		return checkString("`"+value.String()+"`", parts)
+	}
+
+	// Heredocs hold values of whole lines.
+	if !strings.HasSuffix(value.String(), "\n") {
+		return "", false
+	}
+	var body strings.Builder
+	for _, p := range parts {
+		if form == heredoc {
+			body.WriteString(heredocReplacer.Replace(p.text))
+		} else {
+			body.WriteString(p.text)
+		}
+		body.WriteString(p.interp)
+	}
+	lines := strings.Split(strings.TrimSuffix(body.String(), "\n"), "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = indent + "\t" + line
+		}
+	}
+	for _, marker := range heredocMarkers {
+		start := "<<~" + marker
+		if form == rawHeredoc {
+			start = "<<~`" + marker + "`"
+		}
+		text := start + "\n" + strings.Join(lines, "\n") + "\n" + indent + marker
+		if text, ok := checkString(text, parts); ok {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+var (
+	quoteReplacer   = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`, "${", `\${`)
+	heredocReplacer = strings.NewReplacer(`\`, `\\`, "${", `\${`)
+)
+
+// checkString reports whether text parses as a literal with the value
+// split into parts.
+func checkString(text string, parts []part) (string, bool) {
+	src := "fun f() string {\n" + text + "\n}\n"
+	f := ast.ParseSource("", []byte(src), ast.Options{})
+	if f.Err() != nil {
+		return "", false
+	}
+	var lit *ast.StringLit
+	ast.Inspect(f.Module, func(node ast.Node) bool {
+		if l, ok := node.(*ast.StringLit); ok && lit == nil {
+			lit = l
+		}
+		return lit == nil
+	})
+	if lit == nil {
+		return "", false
+	}
+	checked := &refactorer{src: []byte(src)}
+	got, ok := checked.parts(lit)
+	if !ok || len(got) != len(parts) {
+		return "", false
+	}
+	for i := range got {
+		if got[i] != parts[i] {
+			return "", false
+		}
+	}
+	return text, true
+}
@@ -0,0 +1,133 @@
+package refactor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+// defaultType is the type of stub parameters and results that can't be
+// inferred, since most functions operate on filesystems.
+const defaultType = "fs"
+
+// addFunc offers to declare an undefined function called at the cursor as a
+// stub after the enclosing function. The types of its parameters are those of
+// the arguments, and its type is the one expected at the call.
+func (r *refactorer) addFunc() []*Action {
+	if len(r.path) < 4 {
+		return nil
+	}
+	ident, ok := r.path[0].(*ast.Ident)
+	if !ok || r.info.ObjectOf(ident) != nil || reserved[ident.Text] {
+		return nil
+	}
+	ref, ok := r.path[2].(*ast.Ref)
+	if !ok || ref.Terminal.Ident != ident || (ref.Next != nil && ref.Next.Call == nil) {
+		return nil
+	}
+	if _, ok := r.path[3].(*ast.AsClause); ok {
+		return nil
+	}
+	fun := r.funcDecl()
+	if fun == nil {
+		return nil
+	}
+
+	var params []string
+	if ref.Next != nil && ref.Next.Call.Args != nil {
+		params = r.stubParams(ref.Next.Call.Args)
+	}
+	typ := r.expectedType(r.path[3:])
+	if typ == "" {
+		typ = defaultType
+	}
+	stub := fmt.Sprintf("\n\nfun %s(%s) %s {}", ident.Text, strings.Join(params, ", "), typ)
+	return []*Action{{
+		Title: fmt.Sprintf("Add function %s", ident.Text),
+		Kind:  QuickFix,
+		Edits: []analysis.TextEdit{insert(fun.EndPos.Offset, stub)},
+	}}
+}
+
+// stubParams returns the parameters of a stub called with args. They are
+// named after the keys of entries and the identifiers passed, or numbered
+// otherwise. A splat as the last argument passes a variadic parameter.
+func (r *refactorer) stubParams(args *ast.ExprList) []string {
+	var (
+		names    = make(map[string]bool)
+		params   []string
+		variadic bool
+	)
+	for _, arg := range args.Exprs {
+		var (
+			name string
+			expr = arg.Expr
+		)
+		switch {
+		case arg.Entry != nil:
+			name, expr = arg.Entry.Keys[0].Text, arg.Entry.Value
+		case arg.Expr != nil:
+			if ref := arg.Expr.Unary; ref != nil && ref.Op == ast.OpNone && ref.Ref.Terminal.Ident != nil && !isCall(ref.Ref) {
+				name = ref.Ref.Terminal.Ident.Text
+			}
+		default:
+			continue
+		}
+		if name == "" {
+			name = fmt.Sprintf("arg%d", len(params)+1)
+		}
+		name = uniqueName(name, func(name string) bool { return names[name] })
+		names[name] = true
+
+		typ := r.typeOf(expr)
+		if typ == "" {
+			typ = defaultType
+		}
+		variadic = isSplat(expr) && strings.HasPrefix(typ, "[]")
+		if variadic {
+			typ = strings.TrimPrefix(typ, "[]") + "..."
+		}
+		params = append(params, fmt.Sprintf("%s %s", typ, name))
+	}
+	// Only the last parameter can be variadic, so other splats pass arrays.
+	for i, param := range params {
+		if variadic && i == len(params)-1 {
+			break
+		}
+		if typ := strings.Fields(param)[0]; strings.HasSuffix(typ, "...") {
+			params[i] = "[]" + strings.TrimSuffix(typ, "...") + param[len(typ):]
+		}
+	}
+	return params
+}
+
+// isCall reports whether ref is more than a reference to an identifier, so
+// that its value isn't named by it.
+func isCall(ref *ast.Ref) bool {
+	for next := ref.Next; next != nil; next = next.Next {
+		if next.Splat == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// isSplat reports whether expr spreads an array over variadic parameters, as
+// in `args...`.
+func isSplat(expr *ast.Expr) bool {
+	if expr == nil || expr.Unary == nil {
+		return false
+	}
+	next := expr.Unary.Ref.Next
+	for next != nil && next.Next != nil {
+		next = next.Next
+	}
+	return next != nil && next.Splat != nil
+}
+
+// reserved are the words that can't name a function.
+var reserved = map[string]bool{
+	"_": true, "return": true,
+}
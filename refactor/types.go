@@ -0,0 +1,217 @@
+package refactor
+
+import (
+	"strings"
+
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// typeOf returns the type of expr, or an empty string if it can't be
+// inferred.
+func (r *refactorer) typeOf(expr *ast.Expr) string {
+	switch {
+	case expr == nil:
+		return ""
+	case expr.Unary == nil:
+		switch expr.Op {
+		case ast.OpGe, ast.OpLe, ast.OpAnd, ast.OpOr, ast.OpEq, ast.OpNe, ast.OpLt, ast.OpGt:
+			return "bool"
+		}
+		if typ := r.typeOf(expr.Left); typ != "" {
+			return typ
+		}
+		return r.typeOf(expr.Right)
+	case expr.Unary.Op == ast.OpNot:
+		return "bool"
+	case expr.Unary.Op == ast.OpSub:
+		return "int"
+	}
+	return r.refType(expr.Unary.Ref)
+}
+
+func (r *refactorer) refType(ref *ast.Ref) string {
+	var (
+		typ string
+		fun *ast.FuncDecl
+	)
+	switch term := ref.Terminal; {
+	case term.Group != nil:
+		typ = r.typeOf(term.Group.Expr)
+	case term.Lit != nil:
+		typ = literalType(term.Lit)
+	case term.Ident != nil:
+		typ, fun = r.objectType(r.info.ObjectOf(term.Ident))
+	}
+
+	for next := ref.Next; next != nil; next = next.Next {
+		switch {
+		case next.Selector != nil:
+			typ, fun = r.objectType(r.info.ObjectOf(next.Selector.Ident))
+		case next.Call != nil && next.Call.At != nil:
+			typ = ""
+			if effect := r.info.ObjectOf(next.Call.At.Effect); effect != nil {
+				typ, _ = r.objectType(effect)
+			} else if fun != nil {
+				for _, field := range resolve.Fields(fun.Effects) {
+					if field.Name.Text == next.Call.At.Effect.Text {
+						typ = field.Type.String()
+					}
+				}
+			}
+			fun = nil
+		case next.Subscript != nil && next.Subscript.Colon == nil:
+			typ = strings.TrimPrefix(typ, "[]")
+		}
+	}
+	return typ
+}
+
+// objectType returns the type of obj, and its declaration if it's a
+// function.
+func (r *refactorer) objectType(obj *resolve.Object) (string, *ast.FuncDecl) {
+	if obj == nil {
+		return "", nil
+	}
+	switch obj.Kind {
+	case resolve.Builtin, resolve.Func:
+		return obj.FuncDecl().Type.String(), obj.FuncDecl()
+	case resolve.Param, resolve.Effect:
+		field := obj.Decl.(*ast.Field)
+		if field.Variadic != nil {
+			return "[]" + field.Type.String(), nil
+		}
+		return field.Type.String(), nil
+	case resolve.Var:
+		header := obj.Decl.(*ast.ForHeader)
+		if obj.Ident == header.Counter {
+			return "int", nil
+		}
+		return strings.TrimPrefix(r.typeOf(header.Iterable), "[]"), nil
+	}
+	return "", nil
+}
+
+func literalType(lit *ast.Literal) string {
+	switch {
+	case lit.Block != nil && lit.Block.Type != nil:
+		return lit.Block.Type.String()
+	case lit.Decimal != nil, lit.Numeric != nil:
+		return "int"
+	case lit.Bool != nil:
+		return "bool"
+	case lit.String != nil:
+		return "string"
+	}
+	return ""
+}
+
+// expectedType returns the type expected of the expression enclosed by path,
+// the nodes enclosing it innermost first, or an empty string if it's unknown.
+// The elements of blocks of array type are expected to be of the element
+// type.
+func (r *refactorer) expectedType(path []ast.Node) string {
+	for i, node := range path {
+		switch n := node.(type) {
+		case *ast.ExprStmt:
+			list, ok := path[i+1].(*ast.ExprList)
+			if !ok {
+				return ""
+			}
+			call, ok := path[i+2].(*ast.Call)
+			if !ok {
+				return ""
+			}
+			return r.paramType(r.callee(path[i+2:], call), list, n)
+		case *ast.WithClause:
+			ident := calleeIdent(path[i+1:], path[i+1].(*ast.Call))
+			if ident == nil {
+				return ""
+			}
+			return "option::" + ident.Text
+		case *ast.BlockLit:
+			// The type of untyped blocks, like those of with clauses, is
+			// the one expected of the block.
+			if n.Type != nil {
+				return strings.TrimPrefix(n.Type.String(), "[]")
+			}
+		case *ast.FuncDecl:
+			return strings.TrimPrefix(n.Type.String(), "[]")
+		case *ast.Condition, *ast.ForHeader, *ast.Subscript, *ast.Interpolated,
+			*ast.FieldDefault, *ast.Group, *ast.ImportDecl, *ast.Entry, *ast.AsClause:
+			return ""
+		}
+	}
+	return ""
+}
+
+// paramType returns the type of the parameter of fun receiving arg, one of
+// the arguments in list.
+func (r *refactorer) paramType(fun *ast.FuncDecl, list *ast.ExprList, arg *ast.ExprStmt) string {
+	if fun == nil {
+		return ""
+	}
+	params := resolve.Fields(fun.Params)
+	if arg.Entry != nil {
+		for _, param := range params {
+			if param.Name.Text == arg.Entry.Keys[0].Text {
+				return param.Type.String()
+			}
+		}
+		return ""
+	}
+	i := 0
+	for _, other := range list.Exprs {
+		if other == arg {
+			break
+		}
+		if other.Expr != nil {
+			i++
+		}
+	}
+	switch {
+	case i < len(params):
+		return params[i].Type.String()
+	case len(params) > 0 && params[len(params)-1].Variadic != nil:
+		return params[len(params)-1].Type.String()
+	}
+	return ""
+}
+
+// callee returns the declaration of the function called by call, where path
+// are the nodes enclosing it starting with call.
+func (r *refactorer) callee(path []ast.Node, call *ast.Call) *ast.FuncDecl {
+	ident := calleeIdent(path, call)
+	if ident == nil {
+		return nil
+	}
+	obj := r.info.ObjectOf(ident)
+	if obj == nil {
+		return nil
+	}
+	return obj.FuncDecl()
+}
+
+// calleeIdent returns the identifier naming the function called by call,
+// which is the last one before it in the enclosing reference.
+func calleeIdent(path []ast.Node, call *ast.Call) *ast.Ident {
+	for _, node := range path {
+		ref, ok := node.(*ast.Ref)
+		if !ok {
+			continue
+		}
+		ident := ref.Terminal.Ident
+		for next := ref.Next; next != nil; next = next.Next {
+			switch {
+			case next.Call == call:
+				return ident
+			case next.Selector != nil:
+				ident = next.Selector.Ident
+			default:
+				ident = nil
+			}
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package refactor
+
+import (
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+// wrapWith offers to wrap the single option of the with clause at the cursor
+// in a block, so that more options can be added. It is the inverse of the fix
+// suggested by the simplifywith analyzer.
+func (r *refactorer) wrapWith() []*Action {
+	var with *ast.WithClause
+	for _, node := range r.path {
+		if with, _ = node.(*ast.WithClause); with != nil {
+			break
+		}
+	}
+	if with == nil || isBlock(with.Expr) {
+		return nil
+	}
+	indent := r.indent(with.Pos.Offset)
+	text := "{\n" + indent + "\t" + reindent(r.text(with.Expr), indent, indent+"\t") + "\n" + indent + "}"
+	return []*Action{{
+		Title: "Wrap option in with block",
+		Kind:  Rewrite,
+		Edits: []analysis.TextEdit{edit(with.Expr.Pos, with.Expr.EndPos, text)},
+	}}
+}
+
+// isBlock reports whether expr is a block literal.
+func isBlock(expr *ast.Expr) bool {
+	return expr.Unary != nil && expr.Unary.Op == ast.OpNone && expr.Unary.Ref.Next == nil &&
+		expr.Unary.Ref.Terminal.Lit != nil && expr.Unary.Ref.Terminal.Lit.Block != nil
+}
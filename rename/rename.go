@@ -0,0 +1,252 @@
+// Package rename renames functions, imports, parameters, effects and loop
+// variables along with every reference to them.
+//
+// References are found with the resolver, so they include calls in with
+// clauses and string interpolations, as targets, named arguments, effects
+// accessed with @, and selectors like go.build in the modules that were loaded
+// by resolving, and in the modules importing the renamed one that the caller
+// resolved. Public functions and their parameters and effects aren't renamed
+// without those importers, as their references would be missed. A rename is
+// refused if the new name collides with another
+// declaration in the same scope, if a reference would be shadowed by a
+// declaration of the new name in an inner scope, or if the renamed
+// declaration would capture references to an outer declaration of the new
+// name, such as a parameter named after a function the body calls.
+package rename
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/analysis"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+// Edits maps filenames to the edits to make in them, sorted by position.
+type Edits map[string][]analysis.TextEdit
+
+var identRegexp = regexp.MustCompile(`^[[:alpha:]_]\w*$`)
+
+// reserved are the words that aren't identifiers, or that have a special
+// meaning where an identifier is expected.
+var reserved = map[string]bool{
+	"if": true, "else": true, "for": true, "in": true, "with": true, "as": true,
+	"import": true, "fun": true, "pub": true, "true": true, "false": true,
+	"return": true, "_": true,
+}
+
+// Rename returns the edits renaming the object that ident declares or refers
+// to as newName. importers are the resolutions of the modules that may import
+// the module declaring the object, like the other modules of its directory.
+// Their references to it are renamed and checked too. Objects that other
+// modules can refer to are only renamed if importers isn't nil.
+func Rename(info *resolve.Info, ident *ast.Ident, newName string, importers []*resolve.Info) (Edits, error) {
+	obj := info.ObjectOf(ident)
+	if obj == nil {
+		return nil, fmt.Errorf("%s: %s is not declared", ident.Pos, ident.Text)
+	}
+	if obj.Kind == resolve.Builtin || obj.Module == resolve.Universe {
+		return nil, fmt.Errorf("%s: cannot rename builtin %s", ident.Pos, obj.Name)
+	}
+	if importers == nil && exported(obj) {
+		return nil, fmt.Errorf("%s: cannot rename %s without the modules that may import %s", ident.Pos, obj.Name, obj.Module.Filename)
+	}
+	if !identRegexp.MatchString(newName) || reserved[newName] {
+		return nil, fmt.Errorf("%q is not a valid name", newName)
+	}
+	if newName == obj.Name {
+		return Edits{}, nil
+	}
+
+	edits := Edits{}
+	renamed := make(map[lexer.Position]bool)
+	for _, info := range append([]*resolve.Info{info}, importers...) {
+		obj := declared(info, obj.Ident.Pos)
+		if obj == nil {
+			continue
+		}
+		r := &renamer{
+			info:    info,
+			obj:     obj,
+			newName: newName,
+			scopes:  make(map[*ast.Ident]*resolve.Scope),
+		}
+		if err := r.check(); err != nil {
+			return nil, err
+		}
+
+		// The modules loaded by several resolutions have references in
+		// each.
+		for _, ident := range r.refs() {
+			if renamed[ident.Pos] {
+				continue
+			}
+			renamed[ident.Pos] = true
+			filename := ident.Pos.Filename
+			edits[filename] = append(edits[filename], analysis.TextEdit{
+				Pos:     ident.Pos,
+				End:     ident.EndPos,
+				NewText: []byte(newName),
+			})
+		}
+	}
+	for _, e := range edits {
+		sort.Slice(e, func(i, j int) bool {
+			return e[i].Pos.Offset < e[j].Pos.Offset
+		})
+	}
+	return edits, nil
+}
+
+// exported reports whether obj can be referred to by other modules: a public
+// function, or a parameter or effect of one, as named arguments and with @.
+func exported(obj *resolve.Object) bool {
+	switch obj.Kind {
+	case resolve.Func:
+		return resolve.IsPublic(obj.FuncDecl())
+	case resolve.Param, resolve.Effect:
+		return obj.Func != nil && resolve.IsPublic(obj.Func)
+	}
+	return false
+}
+
+// declared returns the object of info declared by the identifier at pos, or
+// nil if info didn't load its module. Each resolution parses the modules it
+// loads, so objects are identified by position across resolutions.
+func declared(info *resolve.Info, pos lexer.Position) *resolve.Object {
+	for ident, obj := range info.Defs {
+		if ident.Pos == pos {
+			return obj
+		}
+	}
+	return nil
+}
+
+type renamer struct {
+	info    *resolve.Info
+	obj     *resolve.Object
+	newName string
+
+	// scopes caches the scopes that identifiers are looked up in.
+	scopes map[*ast.Ident]*resolve.Scope
+}
+
+// refs returns the declaring identifier of the object and every identifier
+// referring to it.
+func (r *renamer) refs() []*ast.Ident {
+	refs := []*ast.Ident{r.obj.Ident}
+	for ident, obj := range r.info.Uses {
+		if obj == r.obj {
+			refs = append(refs, ident)
+		}
+	}
+	return refs
+}
+
+func (r *renamer) check() error {
+	scope := r.declScope(r.obj)
+	if scope == nil {
+		return fmt.Errorf("%s: cannot find the scope of %s", r.obj.Ident.Pos, r.obj.Name)
+	}
+	if alt := scope.Objects[r.newName]; alt != nil {
+		return fmt.Errorf("renaming %s to %s conflicts with %s %s declared at %s", r.obj.Name, r.newName, alt.Kind, alt.Name, alt.Ident.Pos)
+	}
+
+	// References to the object must not find another object of the new name
+	// in a scope nested between theirs and the declaring one.
+	for _, ident := range r.refs() {
+		if ident == r.obj.Ident {
+			continue
+		}
+		lookup := r.lookupScope(ident)
+		if lookup == nil {
+			continue
+		}
+		for s := lookup; s != nil && s != scope; s = s.Parent {
+			if alt := s.Objects[r.newName]; alt != nil {
+				return fmt.Errorf("renaming %s to %s would shadow the reference at %s with %s %s declared at %s", r.obj.Name, r.newName, ident.Pos, alt.Kind, alt.Name, alt.Ident.Pos)
+			}
+		}
+	}
+
+	// References to other objects of the new name must not find the renamed
+	// object first.
+	for ident, alt := range r.info.Uses {
+		if ident.Text != r.newName || alt == r.obj {
+			continue
+		}
+		lookup := r.lookupScope(ident)
+		if lookup == nil || !encloses(scope, lookup) {
+			continue
+		}
+		altScope := r.declScope(alt)
+		if altScope == nil || altScope != scope && encloses(altScope, scope) {
+			return fmt.Errorf("renaming %s to %s would capture the reference at %s to %s %s", r.obj.Name, r.newName, ident.Pos, alt.Kind, alt.Name)
+		}
+	}
+	return nil
+}
+
+// declScope returns the scope declaring obj, or nil for builtins and for the
+// parameters of a call's named arguments, which aren't declared in a scope.
+func (r *renamer) declScope(obj *resolve.Object) *resolve.Scope {
+	for _, scope := range r.info.Scopes {
+		if scope.Objects[obj.Name] == obj {
+			return scope
+		}
+	}
+	return nil
+}
+
+// lookupScope returns the innermost scope enclosing ident if it is looked up
+// by name, or nil if it refers to an object some other way, like a selector of
+// an import, a named argument or an effect accessed with @.
+func (r *renamer) lookupScope(ident *ast.Ident) *resolve.Scope {
+	if scope, ok := r.scopes[ident]; ok {
+		return scope
+	}
+	var scope *resolve.Scope
+	if mod := r.info.ModuleOf(ident.Pos.Filename); mod != nil {
+		path := ast.PathEnclosing(mod.AST, ident.Pos.Offset)
+		if len(path) > 1 && path[0] == ident {
+			if _, ok := path[1].(*ast.Terminal); ok {
+				scope = r.innermost(path)
+			}
+		}
+	}
+	r.scopes[ident] = scope
+	return scope
+}
+
+// innermost returns the innermost scope of the nodes in path. The header of
+// a for loop is resolved outside the loop's scope.
+func (r *renamer) innermost(path []ast.Node) *resolve.Scope {
+	header := false
+	for _, node := range path {
+		if _, ok := node.(*ast.ForHeader); ok {
+			header = true
+			continue
+		}
+		if _, ok := node.(*ast.ForStmt); ok && header {
+			header = false
+			continue
+		}
+		if scope, ok := r.info.Scopes[node]; ok {
+			return scope
+		}
+	}
+	return nil
+}
+
+// encloses reports whether outer is inner or one of its parents.
+func encloses(outer, inner *resolve.Scope) bool {
+	for s := inner; s != nil; s = s.Parent {
+		if s == outer {
+			return true
+		}
+	}
+	return false
+}
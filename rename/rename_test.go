@@ -0,0 +1,205 @@
+package rename
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/fix"
+	"github.com/hinshun/hlb-parser/resolve"
+)
+
+const goSource = `pub fun build(string pkg) fs (string digest) {
+	image("golang")
+	run("go build ${pkg}") with {
+		mount(scratch, "/out") as digest
+	}
+}
+`
+
+const mainSource = `import go from "./go.hlb"
+
+fun test(string pkg) fs {
+	go.build(pkg: pkg)@digest
+	for (region in regions) {
+		run(region, pkg)
+	}
+}
+
+fun regions() []string {
+	"us-east-1"
+}
+`
+
+func TestRename(t *testing.T) {
+	dir := t.TempDir()
+	goFile := filepath.Join(dir, "go.hlb")
+	mainFile := filepath.Join(dir, "main.hlb")
+	for filename, src := range map[string]string{goFile: goSource, mainFile: mainSource} {
+		if err := os.WriteFile(filename, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := ast.ParseSource(mainFile, []byte(mainSource), ast.Options{Comments: true, Features: ast.AllFeatures})
+	if err := f.Err(); err != nil {
+		t.Fatal(err)
+	}
+	mod := f.Module
+	mainInfo := resolve.Resolve(mainFile, mod, resolve.FileImporter{})
+	goMod := mainInfo.ModuleOf(goFile).AST
+
+	// goInfo resolves go.hlb on its own, with main.hlb as its importer.
+	f = ast.ParseSource(goFile, []byte(goSource), ast.Options{Comments: true, Features: ast.AllFeatures})
+	if err := f.Err(); err != nil {
+		t.Fatal(err)
+	}
+	goAST := f.Module
+	goInfo := resolve.Resolve(goFile, goAST, resolve.FileImporter{})
+
+	// identAt returns the nth identifier named name in module m.
+	identAt := func(m *ast.Module, name string, n int) *ast.Ident {
+		var found *ast.Ident
+		ast.Inspect(m, func(node ast.Node) bool {
+			if ident, ok := node.(*ast.Ident); ok && ident.Text == name && found == nil {
+				if n--; n == 0 {
+					found = ident
+				}
+			}
+			return found == nil
+		})
+		if found == nil {
+			t.Fatalf("identifier %s not found", name)
+		}
+		return found
+	}
+
+	for _, tc := range []struct {
+		name    string
+		ident   *ast.Ident
+		newName string
+		want    map[string]string
+		err     string
+
+		// info is the resolution of the module of ident, main.hlb if nil,
+		// and importers those of the modules that may import it.
+		info      *resolve.Info
+		importers []*resolve.Info
+	}{{
+		name:    "function across modules",
+		ident:   identAt(mod, "build", 1),
+		newName: "compile",
+		want: map[string]string{
+			goFile:   strings.Replace(goSource, "build(", "compile(", 1),
+			mainFile: strings.Replace(mainSource, "go.build", "go.compile", 1),
+		},
+	}, {
+		name:      "function from the declaring module",
+		ident:     identAt(goAST, "build", 1),
+		newName:   "compile",
+		info:      goInfo,
+		importers: []*resolve.Info{mainInfo},
+		want: map[string]string{
+			goFile:   strings.Replace(goSource, "build(", "compile(", 1),
+			mainFile: strings.Replace(mainSource, "go.build", "go.compile", 1),
+		},
+	}, {
+		name:      "param from the declaring module",
+		ident:     identAt(goAST, "pkg", 1),
+		newName:   "path",
+		info:      goInfo,
+		importers: []*resolve.Info{mainInfo},
+		want: map[string]string{
+			goFile:   strings.ReplaceAll(goSource, "pkg", "path"),
+			mainFile: strings.Replace(mainSource, "pkg: pkg", "path: pkg", 1),
+		},
+	}, {
+		name:    "public function without importers",
+		ident:   identAt(goAST, "build", 1),
+		newName: "compile",
+		info:    goInfo,
+		err:     "without the modules that may import",
+	}, {
+		name:    "param in interpolation and named argument",
+		ident:   identAt(goMod, "pkg", 1),
+		newName: "path",
+		want: map[string]string{
+			goFile:   strings.ReplaceAll(goSource, "pkg", "path"),
+			mainFile: strings.Replace(mainSource, "pkg: pkg", "path: pkg", 1),
+		},
+	}, {
+		name:    "effect in as and @",
+		ident:   identAt(goMod, "digest", 1),
+		newName: "out",
+		want: map[string]string{
+			goFile:   strings.ReplaceAll(goSource, "digest", "out"),
+			mainFile: strings.Replace(mainSource, "@digest", "@out", 1),
+		},
+	}, {
+		name:    "loop variable",
+		ident:   identAt(mod, "region", 2),
+		newName: "r",
+		want: map[string]string{
+			mainFile: strings.NewReplacer("(region ", "(r ", "(region,", "(r,").Replace(mainSource),
+		},
+	}, {
+		name:    "collision",
+		ident:   identAt(mod, "test", 1),
+		newName: "regions",
+		err:     "conflicts with func regions",
+	}, {
+		name:    "shadowed",
+		ident:   identAt(mod, "pkg", 1),
+		newName: "region",
+		err:     "would shadow",
+	}, {
+		name:    "capture",
+		ident:   identAt(mod, "pkg", 1),
+		newName: "regions",
+		err:     "would capture the reference",
+	}, {
+		name:    "builtin",
+		ident:   identAt(mod, "run", 1),
+		newName: "exec",
+		err:     "cannot rename builtin",
+	}, {
+		name:    "keyword",
+		ident:   identAt(mod, "regions", 2),
+		newName: "with",
+		err:     "not a valid name",
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			// The other modules of the directory are the importers of
+			// main.hlb, as the rename command resolves them.
+			info, importers := tc.info, tc.importers
+			if info == nil {
+				info, importers = mainInfo, []*resolve.Info{goInfo}
+			}
+			edits, err := Rename(info, tc.ident, tc.newName, importers)
+			if tc.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("got error %v, want %q", err, tc.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(edits) != len(tc.want) {
+				t.Errorf("got edits to %d files, want %d", len(edits), len(tc.want))
+			}
+			for filename, want := range tc.want {
+				src := map[string]string{goFile: goSource, mainFile: mainSource}[filename]
+				out, err := fix.Apply([]byte(src), edits[filename])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(out) != want {
+					t.Errorf("%s: got\n%s\nwant\n%s", filename, out, want)
+				}
+			}
+		})
+	}
+}
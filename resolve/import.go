@@ -0,0 +1,114 @@
+package resolve
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+// ErrNotLocal is returned by an Importer for imports that are not local
+// files, such as modules published as images.
+var ErrNotLocal = errors.New("import is not a local file")
+
+// Importer loads imported modules.
+type Importer interface {
+	// Import loads the module imported by decl in the module parsed from
+	// filename, returning the filename of the imported module.
+	Import(filename string, decl *ast.ImportDecl) (string, *ast.Module, error)
+}
+
+// SourceKind is the kind of location a module is imported from.
+type SourceKind int
+
+const (
+	LocalSource SourceKind = iota // a path relative to the importing module
+	ImageSource                   // a module published as an image
+)
+
+// Source is the location a module is imported from.
+type Source struct {
+	Kind SourceKind
+	Ref  string
+
+	// Lit is the string literal holding Ref.
+	Lit *ast.StringLit
+}
+
+// ImportSource returns the source of an import declared as a string literal
+// path, local("path") or image("ref"), or nil if it can't be determined
+// statically.
+func ImportSource(decl *ast.ImportDecl) *Source {
+	if lit := stringLit(decl.Expr); lit != nil {
+		return newSource(LocalSource, lit)
+	}
+
+	var src *Source
+	ast.Inspect(decl.Expr, func(node ast.Node) bool {
+		ref, ok := node.(*ast.Ref)
+		if !ok || src != nil {
+			return src == nil
+		}
+		if ref.Terminal.Ident == nil || ref.Next == nil || ref.Next.Call == nil {
+			return true
+		}
+		args := ref.Next.Call.Args
+		if args == nil || len(args.Exprs) == 0 {
+			return true
+		}
+		lit := stringLit(args.Exprs[0].Expr)
+		if lit == nil {
+			return true
+		}
+		switch ref.Terminal.Ident.Text {
+		case "local":
+			src = newSource(LocalSource, lit)
+		case "image":
+			src = newSource(ImageSource, lit)
+		}
+		return src == nil
+	})
+	return src
+}
+
+func newSource(kind SourceKind, lit *ast.StringLit) *Source {
+	ref, ok := lit.Unquoted()
+	if !ok {
+		return nil
+	}
+	return &Source{Kind: kind, Ref: ref, Lit: lit}
+}
+
+func stringLit(expr *ast.Expr) *ast.StringLit {
+	if expr == nil || expr.Unary == nil || expr.Unary.Ref.Next != nil {
+		return nil
+	}
+	if lit := expr.Unary.Ref.Terminal.Lit; lit != nil {
+		return lit.String
+	}
+	return nil
+}
+
+// FileImporter loads modules imported from local paths by parsing them from
+// the filesystem.
+type FileImporter struct{}
+
+func (FileImporter) Import(filename string, decl *ast.ImportDecl) (string, *ast.Module, error) {
+	src := ImportSource(decl)
+	if src == nil || src.Kind != LocalSource {
+		return "", nil, ErrNotLocal
+	}
+
+	path := src.Ref
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(filename), path)
+	}
+	f, err := ast.ParseFile(path, ast.Options{Comments: true, Features: ast.AllFeatures})
+	if err != nil {
+		return "", nil, err
+	}
+	if err := f.Err(); err != nil {
+		return "", nil, err
+	}
+	return path, f.Module, nil
+}
@@ -0,0 +1,491 @@
+// Package resolve binds identifiers to the functions, imports, parameters,
+// effects and loop variables they refer to.
+//
+// Scopes nest from the universe of builtins, to the module, to a function's
+// parameters and effects, to the variables of each for loop. Inside a with
+// clause, builtins whose return type is the option type of the called function
+// are preferred, so dir in `run(...) with { dir("/in") }` resolves to the
+// option::run builtin.
+package resolve
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/codes"
+)
+
+// Module is a module loaded by the resolver.
+type Module struct {
+	Filename string
+	AST      *ast.Module
+	Scope    *Scope
+
+	// Imports maps import names to the modules that could be loaded.
+	Imports map[string]*Module
+}
+
+// Info holds the result of resolving a module and the modules it imports.
+type Info struct {
+	// Modules are the loaded modules, with the resolved module first.
+	Modules []*Module
+
+	// Defs maps identifiers to the objects they declare.
+	Defs map[*ast.Ident]*Object
+
+	// Uses maps identifiers to the objects they refer to.
+	Uses map[*ast.Ident]*Object
+
+	// Scopes maps *ast.Module, *ast.FuncDecl and *ast.ForStmt nodes to the
+	// scopes they declare.
+	Scopes map[ast.Node]*Scope
+
+	// Errors are the identifiers that could not be resolved and the
+	// declarations that conflict.
+	Errors []*Error
+}
+
+// ObjectOf returns the object declared or referred to by ident, or nil if
+// ident is unresolved.
+func (info *Info) ObjectOf(ident *ast.Ident) *Object {
+	if obj, ok := info.Defs[ident]; ok {
+		return obj
+	}
+	if obj, ok := info.Uses[ident]; ok {
+		return obj
+	}
+	return universe.defs[ident]
+}
+
+// ModuleOf returns the loaded module for filename.
+func (info *Info) ModuleOf(filename string) *Module {
+	for _, mod := range info.Modules {
+		if mod.Filename == filename {
+			return mod
+		}
+	}
+	return nil
+}
+
+// Error is a resolution error.
+type Error struct {
+	Pos  lexer.Position
+	End  lexer.Position
+	Code string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s [%s]", e.Pos, e.Msg, e.Code)
+}
+
+// Resolve resolves mod, which was parsed from filename. Modules imported from
+// local paths are loaded with imp and resolved too, unless imp is nil.
+func Resolve(filename string, mod *ast.Module, imp Importer) *Info {
+	r := &resolver{
+		info: &Info{
+			Defs:   make(map[*ast.Ident]*Object),
+			Uses:   make(map[*ast.Ident]*Object),
+			Scopes: make(map[ast.Node]*Scope),
+		},
+		imp:    imp,
+		loaded: make(map[string]*Module),
+	}
+	r.load(filename, mod)
+
+	// Bodies are resolved once every module is declared, so modules that
+	// import each other see all the functions of the other.
+	for _, mod := range r.info.Modules {
+		r.resolve(mod)
+	}
+	return r.info
+}
+
+type resolver struct {
+	info   *Info
+	imp    Importer
+	loaded map[string]*Module
+
+	// mod is the module whose bodies are being resolved.
+	mod *Module
+}
+
+func (r *resolver) errorf(node ast.Node, code, format string, a ...interface{}) {
+	r.info.Errors = append(r.info.Errors, &Error{
+		Pos:  node.Position(),
+		End:  node.EndPosition(),
+		Code: code,
+		Msg:  fmt.Sprintf(format, a...),
+	})
+}
+
+// load declares the functions and imports of the module node, loading the
+// modules it imports.
+func (r *resolver) load(filename string, node *ast.Module) *Module {
+	if mod, ok := r.loaded[filename]; ok {
+		return mod
+	}
+	mod := &Module{
+		Filename: filename,
+		AST:      node,
+		Scope:    NewScope(nil, node),
+		Imports:  make(map[string]*Module),
+	}
+	r.loaded[filename] = mod
+	r.info.Modules = append(r.info.Modules, mod)
+	r.info.Scopes[node] = mod.Scope
+
+	for _, decl := range node.Decls {
+		switch {
+		case decl.Import != nil:
+			r.declareImport(mod, decl.Import)
+		case decl.Func != nil:
+			r.declareFunc(mod, decl.Func)
+		}
+	}
+	return mod
+}
+
+// resolve resolves the import expressions and function bodies of mod.
+func (r *resolver) resolve(mod *Module) {
+	r.mod = mod
+	for _, decl := range mod.AST.Decls {
+		switch {
+		case decl.Import != nil:
+			r.expr(mod.Scope, decl.Import.Expr, "")
+		case decl.Func != nil:
+			r.funcDecl(mod, decl.Func)
+		}
+	}
+}
+
+func (r *resolver) declare(scope *Scope, obj *Object) {
+	if alt := scope.Insert(obj); alt != nil {
+		r.errorf(obj.Ident, codes.Redeclared, "%s redeclared in this block", obj.Name)
+	}
+	r.info.Defs[obj.Ident] = obj
+}
+
+func (r *resolver) declareImport(mod *Module, decl *ast.ImportDecl) {
+	obj := &Object{
+		Kind:  Import,
+		Name:  decl.Name.Text,
+		Ident: decl.Name,
+		Decl:  decl,
+	}
+	r.declare(mod.Scope, obj)
+
+	if r.imp == nil {
+		return
+	}
+	filename, node, err := r.imp.Import(mod.Filename, decl)
+	if err != nil {
+		if !errors.Is(err, ErrNotLocal) {
+			r.errorf(decl.Expr, codes.ImportFailed, "could not import %s: %s", decl.Name.Text, err)
+		}
+		return
+	}
+	obj.Module = r.load(filename, node)
+	mod.Imports[obj.Name] = obj.Module
+}
+
+func (r *resolver) declareFunc(mod *Module, fun *ast.FuncDecl) {
+	r.declare(mod.Scope, &Object{
+		Kind:   Func,
+		Name:   fun.Name.Text,
+		Ident:  fun.Name,
+		Decl:   fun,
+		Module: mod,
+	})
+
+	scope := NewScope(mod.Scope, fun)
+	r.info.Scopes[fun] = scope
+	for _, field := range Fields(fun.Params) {
+		r.declare(scope, &Object{
+			Kind:   Param,
+			Name:   field.Name.Text,
+			Ident:  field.Name,
+			Decl:   field,
+			Func:   fun,
+			Module: mod,
+		})
+	}
+	for _, field := range Fields(fun.Effects) {
+		r.declare(scope, &Object{
+			Kind:   Effect,
+			Name:   field.Name.Text,
+			Ident:  field.Name,
+			Decl:   field,
+			Func:   fun,
+			Module: mod,
+		})
+	}
+}
+
+func (r *resolver) funcDecl(mod *Module, fun *ast.FuncDecl) {
+	for _, field := range Fields(fun.Params) {
+		if field.Default != nil {
+			r.ref(mod.Scope, field.Default.Unary.Ref, "")
+		}
+	}
+	if fun.Body != nil {
+		r.stmts(r.info.Scopes[fun], fun.Body, "")
+	}
+}
+
+// stmts resolves a list of statements. When typ is not empty, the statements
+// are elements of a block of that type.
+func (r *resolver) stmts(scope *Scope, list *ast.StmtList, typ string) {
+	for _, stmt := range list.Stmts {
+		switch {
+		case stmt.If != nil:
+			r.expr(scope, stmt.If.Condition.Expr, "")
+			r.stmts(scope, stmt.If.Body, typ)
+			for _, elseIf := range stmt.If.ElseIfs {
+				r.expr(scope, elseIf.Condition.Expr, "")
+				r.stmts(scope, elseIf.Body, typ)
+			}
+			if stmt.If.Else != nil {
+				r.stmts(scope, stmt.If.Else.Body, typ)
+			}
+		case stmt.For != nil:
+			r.forStmt(scope, stmt.For, typ)
+		case stmt.Entry != nil:
+			r.expr(scope, stmt.Entry.Value, "")
+		case stmt.Expr != nil:
+			r.expr(scope, stmt.Expr, typ)
+		}
+	}
+}
+
+func (r *resolver) forStmt(scope *Scope, stmt *ast.ForStmt, typ string) {
+	header := stmt.Header
+	r.expr(scope, header.Iterable, "")
+
+	inner := NewScope(scope, stmt)
+	r.info.Scopes[stmt] = inner
+	for _, ident := range []*ast.Ident{header.Counter, header.Var} {
+		if ident == nil {
+			continue
+		}
+		r.declare(inner, &Object{
+			Kind:   Var,
+			Name:   ident.Text,
+			Ident:  ident,
+			Decl:   header,
+			Module: r.mod,
+		})
+	}
+	r.stmts(inner, stmt.Body, typ)
+}
+
+func (r *resolver) expr(scope *Scope, expr *ast.Expr, typ string) {
+	switch {
+	case expr == nil:
+	case expr.Unary != nil:
+		r.ref(scope, expr.Unary.Ref, typ)
+	default:
+		r.expr(scope, expr.Left, typ)
+		r.expr(scope, expr.Right, typ)
+	}
+}
+
+func (r *resolver) ref(scope *Scope, ref *ast.Ref, typ string) {
+	var (
+		name   string
+		callee *Object
+	)
+	switch term := ref.Terminal; {
+	case term.Group != nil:
+		r.expr(scope, term.Group.Expr, typ)
+	case term.Lit != nil:
+		r.literal(scope, term.Lit, typ)
+	case term.Ident != nil:
+		name = term.Ident.Text
+		callee = r.use(scope, term.Ident, typ)
+	}
+
+	for next := ref.Next; next != nil; next = next.Next {
+		switch {
+		case next.Subscript != nil:
+			r.expr(scope, next.Subscript.LeftExpr, "")
+			r.expr(scope, next.Subscript.RightExpr, "")
+			name, callee = "", nil
+		case next.Selector != nil:
+			ident := next.Selector.Ident
+			if callee != nil && callee.Kind == Import {
+				name = callee.Name + "." + ident.Text
+				callee = r.selectImport(callee, ident)
+			} else {
+				name, callee = "", nil
+			}
+		case next.Call != nil:
+			r.call(scope, name, callee, next.Call)
+			name, callee = "", nil
+		}
+	}
+}
+
+func (r *resolver) literal(scope *Scope, lit *ast.Literal, typ string) {
+	switch {
+	case lit.Block != nil:
+		if lit.Block.Type != nil {
+			typ = strings.TrimPrefix(lit.Block.Type.String(), "[]")
+		}
+		r.stmts(scope, lit.Block.Block, typ)
+	case lit.String != nil:
+		for _, interp := range Interpolations(lit.String) {
+			r.expr(scope, interp.Expr, "")
+		}
+	}
+}
+
+// use resolves an identifier that refers to an object. Builtins returning typ
+// are preferred over others of the same name.
+func (r *resolver) use(scope *Scope, ident *ast.Ident, typ string) *Object {
+	if ident.Text == "_" {
+		return nil
+	}
+	obj := scope.Lookup(ident.Text)
+	if obj == nil {
+		obj = universe.lookup(ident.Text, typ)
+	}
+	if obj == nil {
+		r.errorf(ident, codes.Undefined, "undefined: %s", ident.Text)
+		return nil
+	}
+	r.info.Uses[ident] = obj
+	return obj
+}
+
+func (r *resolver) selectImport(imp *Object, ident *ast.Ident) *Object {
+	if imp.Module == nil {
+		return nil
+	}
+	obj := imp.Module.Scope.Objects[ident.Text]
+	if obj == nil || obj.Kind != Func {
+		r.errorf(ident, codes.Undefined, "undefined: %s.%s", imp.Name, ident.Text)
+		return nil
+	}
+	if !IsPublic(obj.FuncDecl()) {
+		r.errorf(ident, codes.NotPublic, "%s is not public in %s", ident.Text, imp.Name)
+	}
+	r.info.Uses[ident] = obj
+	return obj
+}
+
+func (r *resolver) call(scope *Scope, name string, callee *Object, call *ast.Call) {
+	var fun *ast.FuncDecl
+	if callee != nil {
+		fun = callee.FuncDecl()
+	}
+
+	if call.Args != nil {
+		for _, arg := range call.Args.Exprs {
+			switch {
+			case arg.Entry != nil:
+				// The first key of an entry names a parameter of the callee,
+				// the rest are fields of its value.
+				if fun != nil {
+					key := arg.Entry.Keys[0]
+					if param := r.field(fun.Params, key.Text); param != nil {
+						r.info.Uses[key] = param
+					} else {
+						r.errorf(key, codes.UnknownParameter, "unknown parameter %s in call to %s", key.Text, name)
+					}
+				}
+				r.expr(scope, arg.Entry.Value, "")
+			case arg.Expr != nil:
+				r.expr(scope, arg.Expr, "")
+			}
+		}
+	}
+
+	if call.At != nil && fun != nil {
+		ident := call.At.Effect
+		if effect := r.field(fun.Effects, ident.Text); effect != nil {
+			r.info.Uses[ident] = effect
+		} else {
+			r.errorf(ident, codes.UnknownEffect, "%s has no effect %s", name, ident.Text)
+		}
+	}
+
+	if call.With != nil {
+		typ := ""
+		if name != "" {
+			typ = "option::" + name[strings.LastIndex(name, ".")+1:]
+		}
+		r.expr(scope, call.With.Expr, typ)
+	}
+
+	if call.As != nil {
+		// Binding to the special return register or to an effect of the
+		// enclosing function.
+		ident := call.As.Effect.Terminal.Ident
+		if ident != nil && ident.Text != "return" {
+			if obj := r.use(scope, ident, ""); obj != nil && obj.Kind != Effect {
+				r.errorf(ident, codes.BindToNonEffect, "cannot bind to %s: not an effect", ident.Text)
+			}
+		}
+	}
+}
+
+// field returns the object declared by the field named name in list.
+func (r *resolver) field(list *ast.FieldList, name string) *Object {
+	for _, field := range Fields(list) {
+		if field.Name.Text == name {
+			if obj, ok := r.info.Defs[field.Name]; ok {
+				return obj
+			}
+			return universe.defs[field.Name]
+		}
+	}
+	return nil
+}
+
+// Fields returns the fields of a parameter or effect list, skipping comments.
+func Fields(list *ast.FieldList) []*ast.Field {
+	if list == nil {
+		return nil
+	}
+	var fields []*ast.Field
+	for _, stmt := range list.Fields {
+		if stmt.Field != nil {
+			fields = append(fields, stmt.Field)
+		}
+	}
+	return fields
+}
+
+// IsPublic reports whether fun has the pub modifier.
+func IsPublic(fun *ast.FuncDecl) bool {
+	for _, mod := range fun.Modifiers {
+		if mod.Public != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Interpolations returns the interpolated expressions of a string literal.
+func Interpolations(lit *ast.StringLit) []*ast.Interpolated {
+	var interps []*ast.Interpolated
+	switch {
+	case lit.String != nil:
+		for _, f := range lit.String.Fragments {
+			if f.Interpolated != nil {
+				interps = append(interps, f.Interpolated)
+			}
+		}
+	case lit.Heredoc != nil:
+		for _, f := range lit.Heredoc.Fragments {
+			if f.Interpolated != nil {
+				interps = append(interps, f.Interpolated)
+			}
+		}
+	}
+	return interps
+}
@@ -0,0 +1,271 @@
+package resolve
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+// mapImporter imports the modules of local paths from sources by path.
+type mapImporter map[string]string
+
+func (m mapImporter) Import(filename string, decl *ast.ImportDecl) (string, *ast.Module, error) {
+	src := ImportSource(decl)
+	if src == nil || src.Kind != LocalSource {
+		return "", nil, ErrNotLocal
+	}
+	text, ok := m[src.Ref]
+	if !ok {
+		return "", nil, fmt.Errorf("no such file %s", src.Ref)
+	}
+	mod, err := parse(src.Ref, text)
+	if err != nil {
+		return "", nil, err
+	}
+	return src.Ref, mod, nil
+}
+
+func parse(filename, src string) (*ast.Module, error) {
+	f := ast.ParseSource(filename, []byte(src), ast.Options{Features: ast.ForLoops})
+	return f.Module, f.Err()
+}
+
+// use is an identifier expected to resolve to an object of kind. Identifiers
+// are written with their occurrence among the identifiers of the same name
+// in their module, like "pkg#2", and prefixed by the filename of modules
+// other than test.hlb.
+type use struct {
+	ident string
+	kind  Kind
+
+	// decl is the identifier declaring the object, or the return type of a
+	// builtin.
+	decl string
+}
+
+func TestResolve(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		src    string
+		files  map[string]string
+		uses   []use
+		errors []string
+	}{{
+		name: "scopes",
+		src: `fun build(string pkg, string... pkgs) fs {
+	image(pkg)
+	for (pkg in pkgs) {
+		run(pkg)
+	}
+	run(pkg)
+}
+
+fun pkg() string {
+	"pkg"
+}
+
+fun test() fs {
+	run(pkg)
+	build(pkg: "a")
+}
+`,
+		uses: []use{
+			{"pkg#2", Param, "pkg#1"},
+			{"pkgs#2", Param, "pkgs#1"},
+			{"pkg#4", Var, "pkg#3"},
+			{"pkg#5", Param, "pkg#1"},
+			{"pkg#7", Func, "pkg#6"},
+			{"build#2", Func, "build#1"},
+			{"pkg#8", Param, "pkg#1"},
+		},
+	}, {
+		name: "redeclared",
+		src: `fun build(string a, string a) fs {}
+
+fun build() fs {}
+`,
+		errors: []string{
+			"a redeclared in this block",
+			"build redeclared in this block",
+		},
+	}, {
+		name: "effects",
+		src: `fun build() fs (fs out) {
+	run("make") with {
+		mount(scratch, "/out") as out
+	}
+	build@out
+	run("make") as build
+}
+`,
+		uses: []use{
+			{"out#2", Effect, "out#1"},
+			{"out#3", Effect, "out#1"},
+		},
+		errors: []string{
+			"cannot bind to build: not an effect",
+		},
+	}, {
+		name: "universe",
+		src: `fun build() fs {
+	image("alpine")
+	run("make") with {
+		dir("/src")
+	}
+	dir("/out")
+	missing()
+}
+`,
+		uses: []use{
+			{"image#1", Builtin, "fs"},
+			{"dir#1", Builtin, "option::run"},
+			{"dir#2", Builtin, "fs"},
+		},
+		errors: []string{
+			"undefined: missing",
+		},
+	}, {
+		name: "module shadows universe",
+		src: `fun image(string ref) fs {
+	scratch
+}
+
+fun build() fs {
+	image("alpine")
+}
+`,
+		uses: []use{
+			{"image#2", Func, "image#1"},
+			{"scratch#1", Builtin, "fs"},
+		},
+	}, {
+		name: "imports",
+		src: `import lib from "lib.hlb"
+import remote from image("openllb/remote.hlb")
+import gone from "gone.hlb"
+
+fun build() fs {
+	lib.base()
+	lib.hidden()
+	lib.missing()
+	remote.build()
+}
+`,
+		files: map[string]string{
+			"lib.hlb": `pub fun base() fs {
+	image("alpine")
+}
+
+fun hidden() fs {
+	base()
+}
+`,
+		},
+		uses: []use{
+			{"lib#2", Import, "lib#1"},
+			{"base#1", Func, "lib.hlb:base#1"},
+			{"lib.hlb:base#2", Func, "lib.hlb:base#1"},
+			{"remote#2", Import, "remote#1"},
+		},
+		errors: []string{
+			"could not import gone: no such file gone.hlb",
+			"hidden is not public in lib",
+			"undefined: lib.missing",
+		},
+	}, {
+		name: "import cycle",
+		src: `import b from "b.hlb"
+
+pub fun a() fs {
+	b.b()
+}
+`,
+		files: map[string]string{
+			"b.hlb": `import a from "test.hlb"
+
+pub fun b() fs {
+	a.a()
+}
+`,
+		},
+		uses: []use{
+			{"b#3", Func, "b.hlb:b#1"},
+			{"b.hlb:a#3", Func, "a#1"},
+		},
+	}} {
+		t.Run(tc.name, func(t *testing.T) {
+			files := map[string]string{"test.hlb": tc.src}
+			for name, src := range tc.files {
+				files[name] = src
+			}
+			mod, err := parse("test.hlb", tc.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info := Resolve("test.hlb", mod, mapImporter(files))
+
+			var errs []string
+			for _, err := range info.Errors {
+				errs = append(errs, err.Msg)
+			}
+			if got, want := strings.Join(errs, "\n"), strings.Join(tc.errors, "\n"); got != want {
+				t.Errorf("got errors:\n%s\nwant:\n%s", got, want)
+			}
+
+			for _, u := range tc.uses {
+				obj := info.ObjectOf(findIdent(t, info, u.ident))
+				if obj == nil {
+					t.Errorf("%s is unresolved", u.ident)
+					continue
+				}
+				if obj.Kind != u.kind {
+					t.Errorf("%s resolves to a %s, want a %s", u.ident, obj.Kind, u.kind)
+				}
+				if obj.Kind == Builtin {
+					if got := obj.FuncDecl().Type.String(); got != u.decl {
+						t.Errorf("%s resolves to the builtin returning %s, want %s", u.ident, got, u.decl)
+					}
+				} else if decl := findIdent(t, info, u.decl); obj.Ident != decl {
+					t.Errorf("%s resolves to %s at %s, want %s", u.ident, obj.Name, obj.Ident.Pos, u.decl)
+				}
+			}
+		})
+	}
+}
+
+// findIdent returns the identifier written as in use.
+func findIdent(t *testing.T, info *Info, ident string) *ast.Ident {
+	t.Helper()
+	filename := "test.hlb"
+	if i := strings.Index(ident, ":"); i >= 0 {
+		filename, ident = ident[:i], ident[i+1:]
+	}
+	i := strings.Index(ident, "#")
+	name := ident[:i]
+	n, err := strconv.Atoi(ident[i+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mod := info.ModuleOf(filename)
+	if mod == nil {
+		t.Fatalf("%s isn't loaded", filename)
+	}
+	var found *ast.Ident
+	ast.Inspect(mod.AST, func(node ast.Node) bool {
+		if id, ok := node.(*ast.Ident); ok && id.Text == name {
+			n--
+			if n == 0 {
+				found = id
+			}
+		}
+		return found == nil
+	})
+	if found == nil {
+		t.Fatalf("%s not found in %s", ident, filename)
+	}
+	return found
+}
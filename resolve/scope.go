@@ -0,0 +1,95 @@
+package resolve
+
+import (
+	"github.com/hinshun/hlb-parser/ast"
+)
+
+// Kind describes what an identifier refers to.
+type Kind int
+
+const (
+	Bad     Kind = iota // unresolved
+	Builtin             // builtin function
+	Func                // function declared in a module
+	Import              // imported module
+	Param               // function parameter
+	Effect              // function effect
+	Var                 // for loop variable or counter
+)
+
+var kindNames = [...]string{
+	Bad:     "bad",
+	Builtin: "builtin",
+	Func:    "func",
+	Import:  "import",
+	Param:   "param",
+	Effect:  "effect",
+	Var:     "var",
+}
+
+func (k Kind) String() string {
+	return kindNames[k]
+}
+
+// Object is a named entity that identifiers can refer to.
+type Object struct {
+	Kind Kind
+	Name string
+
+	// Ident is the identifier that declares the object.
+	Ident *ast.Ident
+
+	// Decl is the declaring node: *ast.FuncDecl for Builtin and Func,
+	// *ast.ImportDecl for Import, *ast.Field for Param and Effect, and
+	// *ast.ForHeader for Var.
+	Decl ast.Node
+
+	// Func is the function that declares a Param or Effect.
+	Func *ast.FuncDecl
+
+	// Module is the module the object is declared in, or for an Import, the
+	// imported module if it could be loaded.
+	Module *Module
+}
+
+// FuncDecl returns the declaration of a Builtin or Func object.
+func (o *Object) FuncDecl() *ast.FuncDecl {
+	fun, _ := o.Decl.(*ast.FuncDecl)
+	return fun
+}
+
+// Scope maps names to the objects declared in a block.
+type Scope struct {
+	Parent  *Scope
+	Node    ast.Node
+	Objects map[string]*Object
+}
+
+// NewScope creates a scope nested in parent.
+func NewScope(parent *Scope, node ast.Node) *Scope {
+	return &Scope{
+		Parent:  parent,
+		Node:    node,
+		Objects: make(map[string]*Object),
+	}
+}
+
+// Lookup returns the object named name in the scope or its parents.
+func (s *Scope) Lookup(name string) *Object {
+	for ; s != nil; s = s.Parent {
+		if obj, ok := s.Objects[name]; ok {
+			return obj
+		}
+	}
+	return nil
+}
+
+// Insert adds obj to the scope. If an object of the same name already exists
+// in the scope, it is returned and obj is not inserted.
+func (s *Scope) Insert(obj *Object) *Object {
+	if alt, ok := s.Objects[obj.Name]; ok {
+		return alt
+	}
+	s.Objects[obj.Name] = obj
+	return nil
+}
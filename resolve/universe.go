@@ -0,0 +1,59 @@
+package resolve
+
+import (
+	"github.com/hinshun/hlb-parser/ast"
+	"github.com/hinshun/hlb-parser/builtin"
+)
+
+// Universe is the module of builtin declarations.
+var Universe = &Module{
+	Filename: builtin.Filename,
+	AST:      builtin.Module,
+}
+
+var universe = newUniverseScope()
+
+type universeScope struct {
+	funcs map[*ast.FuncDecl]*Object
+	defs  map[*ast.Ident]*Object
+}
+
+func newUniverseScope() *universeScope {
+	u := &universeScope{
+		funcs: make(map[*ast.FuncDecl]*Object),
+		defs:  make(map[*ast.Ident]*Object),
+	}
+	for _, fun := range builtin.Funcs("") {
+		obj := &Object{
+			Kind:   Builtin,
+			Name:   fun.Name.Text,
+			Ident:  fun.Name,
+			Decl:   fun,
+			Module: Universe,
+		}
+		u.funcs[fun] = obj
+		u.defs[fun.Name] = obj
+
+		for _, field := range Fields(fun.Params) {
+			u.defs[field.Name] = &Object{Kind: Param, Name: field.Name.Text, Ident: field.Name, Decl: field, Func: fun, Module: Universe}
+		}
+		for _, field := range Fields(fun.Effects) {
+			u.defs[field.Name] = &Object{Kind: Effect, Name: field.Name.Text, Ident: field.Name, Decl: field, Func: fun, Module: Universe}
+		}
+	}
+	return u
+}
+
+func (u *universeScope) lookup(name, typ string) *Object {
+	fun := builtin.Lookup(name, typ)
+	if fun == nil {
+		return nil
+	}
+	return u.funcs[fun]
+}
+
+// LookupBuiltin returns the builtin object named name, preferring the one
+// returning typ.
+func LookupBuiltin(name, typ string) *Object {
+	return universe.lookup(name, typ)
+}